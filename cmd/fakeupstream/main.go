@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/Takenobou/thamestracker/internal/fakeupstream"
+	"github.com/Takenobou/thamestracker/internal/helpers/logger"
+)
+
+func main() {
+	addr := flag.String("addr", ":8081", "listen address")
+	var opts fakeupstream.Options
+	flag.Int64Var(&opts.Seed, "seed", 0, "seed for the generated schedules (0 picks one at random)")
+	flag.IntVar(&opts.Days, "days", 7, "days of bridge lifts to generate")
+	flag.IntVar(&opts.LiftsPerDay, "lifts-per-day", 4, "most bridge lifts on one day")
+	flag.IntVar(&opts.PageSize, "page-size", 10, "bridge lifts per page")
+	flag.IntVar(&opts.Vessels, "vessels", 20, "vessels in each PLA list")
+	var faults fakeupstream.Faults
+	flag.IntVar(&faults.Status, "status", 0, "answer every request with this HTTP status")
+	flag.Float64Var(&faults.FailRate, "fail-rate", 0, "fraction of requests answered with 500")
+	flag.IntVar(&faults.DelayMS, "delay-ms", 0, "milliseconds to wait before answering")
+	flag.BoolVar(&faults.MalformedJSON, "malformed-json", false, "truncate the PLA JSON")
+	flag.BoolVar(&faults.ChangedMarkup, "changed-markup", false, "serve the lift times in unfamiliar markup")
+	flag.StringVar(&faults.Only, "only", "", `apply faults to "bridge" or "vessels" only`)
+	flag.Parse()
+	logger.InitLogger()
+
+	fake := fakeupstream.New(opts)
+	fake.SetFaults(faults)
+	srv := &http.Server{Addr: *addr, Handler: fake.Handler(), ReadHeaderTimeout: 10 * time.Second}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+
+	logger.Logger.Infof("Fake upstream running, address: %s, bridge: %s, vessels: %s?url=ships/lists, faults: %s, lifts: %d",
+		*addr, fakeupstream.BridgePath, fakeupstream.VesselsPath, fakeupstream.FaultsPath, fake.Lifts())
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.Logger.Errorf("Failed to start fake upstream: %v", err)
+		os.Exit(1)
+	}
+}
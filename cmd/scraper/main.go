@@ -1,46 +1,109 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"io"
+	"net/http"
 	"os"
+	"os/signal"
 
 	"github.com/Takenobou/thamestracker/internal/config"
+	"github.com/Takenobou/thamestracker/internal/fixtures"
 	"github.com/Takenobou/thamestracker/internal/helpers/cache"
 	"github.com/Takenobou/thamestracker/internal/helpers/httpclient"
 	"github.com/Takenobou/thamestracker/internal/helpers/logger"
 
 	"github.com/Takenobou/thamestracker/internal/service"
+	"github.com/Takenobou/thamestracker/internal/sources"
 	"github.com/joho/godotenv"
 
 	bridgeScraper "github.com/Takenobou/thamestracker/internal/scraper/bridge"
+	"github.com/Takenobou/thamestracker/internal/scraper/selectors"
 	vesselScraper "github.com/Takenobou/thamestracker/internal/scraper/vessels"
 )
 
 func main() {
 	_ = godotenv.Load()
+	configFile := flag.String("config", os.Getenv("CONFIG_FILE"), "YAML or TOML config file, overridden by environment variables")
+	flag.Parse()
+	args := flag.Args()
 	logger.InitLogger()
-	config.LoadConfig()
+	if len(args) >= 2 && args[0] == "config" && args[1] == "print" {
+		if err := config.Print(os.Stdout, *configFile); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	if err := config.LoadConfig(*configFile); err != nil {
+		logger.Logger.Errorf("Failed to load configuration: %v", err)
+		os.Exit(1)
+	}
+	logCfg := config.Get().Log
+	if err := logger.Configure(logCfg.Level, logCfg.RowSampleFirst, logCfg.RowSampleEvery); err != nil {
+		logger.Logger.Errorf("Failed to configure logging: %v", err)
+		os.Exit(1)
+	}
+	// Ctrl-C cancels an in-flight scrape
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
 
 	// initialize service layer
-	cacheClient := cache.NewRedisCache(config.AppConfig.Redis.Address)
+	cacheClient := cache.NewRedisCache(config.Get().Redis.Address)
+	// upstream requests go to the network, or to fixtures when configured
+	upstream := httpclient.DefaultClient
+	transport, err := upstreamTransport()
+	if err != nil {
+		logger.Logger.Errorf("Failed to set up fixtures: %v", err)
+		os.Exit(1)
+	}
+	if transport != nil {
+		upstream = httpclient.NewClient(transport)
+	}
+	if config.Get().UserAgent != "" {
+		upstream = httpclient.WithUserAgent(upstream, config.Get().UserAgent)
+	}
+	bridge := bridgeScraper.BridgeScraperImpl{Client: upstream, URL: config.Get().URLs.TowerBridge}
+	if config.Get().TowerBridgeDefinition != "" {
+		def, err := selectors.Load(config.Get().TowerBridgeDefinition)
+		if err != nil {
+			logger.Logger.Errorf("Failed to load Tower Bridge definition: %v", err)
+			os.Exit(1)
+		}
+		bridge.Definition = &def
+	}
 	svc := service.NewService(
 		cacheClient,
-		bridgeScraper.BridgeScraperImpl{},
-		vesselScraper.VesselScraperImpl{Client: httpclient.DefaultClient},
+		bridge,
+		vesselScraper.VesselScraperImpl{Client: upstream},
 	)
+	svc.Lifetime = ctx
+	if config.Get().SourcesFile != "" {
+		registry, err := sources.Load(config.Get().SourcesFile, upstream, sources.Options{
+			TimeoutSeconds: config.Get().Timeouts.VesselsSeconds,
+			MaxFailures:    config.Get().CircuitBreaker.MaxFailures,
+			CoolOffSeconds: config.Get().CircuitBreaker.CoolOffSeconds,
+		})
+		if err != nil {
+			logger.Logger.Errorf("Failed to load sources: %v", err)
+			os.Exit(1)
+		}
+		svc.Sources = registry
+	}
 
-	if len(os.Args) < 2 {
-		logger.Logger.Errorf("Usage error. Usage: %s [bridge-lifts | vessels | arrivals | departures | forecast | ics | bridge-ics | vessels-ics]", os.Args[0])
+	if len(args) < 1 {
+		logger.Logger.Errorf("Usage error. Usage: %s [bridge-lifts | vessels | arrivals | departures | forecast | sources | source <name> | ics | bridge-ics | vessels-ics | config print]", os.Args[0])
 		os.Exit(1)
 	}
 
-	switch os.Args[1] {
+	switch args[0] {
 	case "ics":
 		// Fetch combined calendar ICS feed from local server
-		url := fmt.Sprintf("http://localhost:%d/calendar.ics", config.AppConfig.Server.Port)
-		resp, err := httpclient.DefaultClient.Get(url)
+		url := fmt.Sprintf("http://localhost:%d/calendar.ics", config.Get().Server.Port)
+		resp, err := httpclient.Get(ctx, httpclient.DefaultClient, url)
 		if err != nil {
 			logger.Logger.Errorf("Failed to fetch calendar ICS: %v", err)
 			os.Exit(1)
@@ -52,8 +115,8 @@ func main() {
 
 	case "bridge-ics":
 		// Fetch bridge-lifts calendar ICS feed
-		url := fmt.Sprintf("http://localhost:%d/bridge-lifts/calendar.ics", config.AppConfig.Server.Port)
-		resp, err := httpclient.DefaultClient.Get(url)
+		url := fmt.Sprintf("http://localhost:%d/bridge-lifts/calendar.ics", config.Get().Server.Port)
+		resp, err := httpclient.Get(ctx, httpclient.DefaultClient, url)
 		if err != nil {
 			logger.Logger.Errorf("Failed to fetch bridge-lifts calendar ICS: %v", err)
 			os.Exit(1)
@@ -65,8 +128,8 @@ func main() {
 
 	case "vessels-ics":
 		// Fetch vessels calendar ICS feed
-		url := fmt.Sprintf("http://localhost:%d/vessels/calendar.ics", config.AppConfig.Server.Port)
-		resp, err := httpclient.DefaultClient.Get(url)
+		url := fmt.Sprintf("http://localhost:%d/vessels/calendar.ics", config.Get().Server.Port)
+		resp, err := httpclient.Get(ctx, httpclient.DefaultClient, url)
 		if err != nil {
 			logger.Logger.Errorf("Failed to fetch vessels calendar ICS: %v", err)
 			os.Exit(1)
@@ -77,7 +140,7 @@ func main() {
 		return
 
 	case "bridge-lifts":
-		lifts, err := svc.GetBridgeLifts()
+		lifts, err := svc.GetBridgeLifts(ctx)
 		if err != nil {
 			logger.Logger.Errorf("Failed to scrape bridge lifts: %v", err)
 			os.Exit(1)
@@ -85,7 +148,7 @@ func main() {
 		printJSON(lifts)
 
 	case "vessels":
-		vesselList, err := svc.GetVessels("inport")
+		vesselList, err := svc.GetVessels(ctx, "inport")
 		if err != nil {
 			logger.Logger.Errorf("Failed to scrape vessels in port: %v", err)
 			os.Exit(1)
@@ -93,7 +156,7 @@ func main() {
 		printJSON(vesselList)
 
 	case "arrivals":
-		arrivalList, err := svc.GetVessels("arrivals")
+		arrivalList, err := svc.GetVessels(ctx, "arrivals")
 		if err != nil {
 			logger.Logger.Errorf("Failed to scrape vessel arrivals: %v", err)
 			os.Exit(1)
@@ -101,7 +164,7 @@ func main() {
 		printJSON(arrivalList)
 
 	case "departures":
-		departureList, err := svc.GetVessels("departures")
+		departureList, err := svc.GetVessels(ctx, "departures")
 		if err != nil {
 			logger.Logger.Errorf("Failed to scrape vessel departures: %v", err)
 			os.Exit(1)
@@ -109,20 +172,48 @@ func main() {
 		printJSON(departureList)
 
 	case "forecast":
-		forecastList, err := svc.GetVessels("forecast")
+		forecastList, err := svc.GetVessels(ctx, "forecast")
 		if err != nil {
 			logger.Logger.Errorf("Failed to scrape vessel forecasts: %v", err)
 			os.Exit(1)
 		}
 		printJSON(forecastList)
 
+	case "sources":
+		printJSON(svc.ListSources())
+
+	case "source":
+		if len(args) < 2 {
+			logger.Logger.Errorf("Usage error. Usage: %s source <name>", os.Args[0])
+			os.Exit(1)
+		}
+		events, err := svc.GetSourceEvents(ctx, args[1])
+		if err != nil {
+			logger.Logger.Errorf("Failed to scrape source %s: %v", args[1], err)
+			os.Exit(1)
+		}
+		printJSON(events)
+
 	default:
-		logger.Logger.Errorf("Unknown command: %s. Usage: Use 'bridge-lifts', 'vessels', 'arrivals', 'departures', 'forecast', 'ics', 'bridge-ics', or 'vessels-ics'", os.Args[1])
+		logger.Logger.Errorf("Unknown command: %s. Usage: Use 'bridge-lifts', 'vessels', 'arrivals', 'departures', 'forecast', 'sources', 'source <name>', 'ics', 'bridge-ics', 'vessels-ics', or 'config print'", args[0])
 		os.Exit(1)
 	}
 }
 
+// printConfig writes the effective configuration, redacted, to stdout and
+// any problems with it to stderr, returning the exit code.
 func printJSON(data interface{}) {
 	output, _ := json.MarshalIndent(data, "", "  ")
 	fmt.Println(string(output))
 }
+
+// upstreamTransport returns the transport for upstream requests when
+// FIXTURES_MODE is set, or nil to use the network as normal.
+func upstreamTransport() (http.RoundTripper, error) {
+	f := config.Get().Fixtures
+	if f.Mode == "" {
+		return nil, nil
+	}
+	logger.Logger.Infof("Using upstream fixtures, mode: %s, dir: %s, time shift: %t", f.Mode, f.Dir, f.TimeShift)
+	return fixtures.NewTransport(f.Mode, f.Dir, f.TimeShift, http.DefaultTransport)
+}
@@ -2,65 +2,77 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
-	"github.com/Takenobou/thamestracker/internal/api"
 	"github.com/Takenobou/thamestracker/internal/config"
-	"github.com/Takenobou/thamestracker/internal/helpers/cache"
-	"github.com/Takenobou/thamestracker/internal/helpers/httpclient"
 	"github.com/Takenobou/thamestracker/internal/helpers/logger"
-	bridgeScraper "github.com/Takenobou/thamestracker/internal/scraper/bridge"
-	vesselScraper "github.com/Takenobou/thamestracker/internal/scraper/vessels"
-	"github.com/Takenobou/thamestracker/internal/service"
-	"github.com/Takenobou/thamestracker/internal/storage"
-	"github.com/gofiber/fiber/v2"
-	"github.com/gofiber/fiber/v2/middleware/limiter"
+	"github.com/Takenobou/thamestracker/internal/helpers/tracing"
+	"github.com/Takenobou/thamestracker/internal/server"
 	"github.com/joho/godotenv"
 )
 
 func main() {
 	_ = godotenv.Load()
+	configFile := flag.String("config", os.Getenv("CONFIG_FILE"), "YAML or TOML config file, overridden by environment variables")
+	flag.Parse()
 	logger.InitLogger()
-	config.LoadConfig()
-	// initialize storage cache client with loaded config
-	storage.CacheClient = cache.NewRedisCache(config.AppConfig.Redis.Address)
+	if args := flag.Args(); len(args) >= 2 && args[0] == "config" && args[1] == "print" {
+		if err := config.Print(os.Stdout, *configFile); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	if err := config.LoadConfig(*configFile); err != nil {
+		logger.Logger.Errorf("Failed to load configuration: %v", err)
+		os.Exit(1)
+	}
+	logCfg := config.Get().Log
+	if err := logger.Configure(logCfg.Level, logCfg.RowSampleFirst, logCfg.RowSampleEvery); err != nil {
+		logger.Logger.Errorf("Failed to configure logging: %v", err)
+		os.Exit(1)
+	}
+	if fields, err := config.Get().Fields(); err == nil {
+		logger.Logger.Infow("Effective configuration", "file", *configFile, "config", fields)
+	}
 
-	cacheClient := cache.NewRedisCache(config.AppConfig.Redis.Address)
-	// wrap HTTP client in circuit breaker
-	breakerClient := httpclient.NewBreakerClient(httpclient.DefaultClient,
-		config.AppConfig.CircuitBreaker.MaxFailures,
-		config.AppConfig.CircuitBreaker.CoolOffSeconds)
-	svc := service.NewService(
-		cacheClient,
-		bridgeScraper.BridgeScraperImpl{},
-		vesselScraper.VesselScraperImpl{Client: breakerClient},
-	)
-	handler := api.NewAPIHandler(svc)
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Options{
+		Exporter:    config.Get().Tracing.Exporter,
+		Endpoint:    config.Get().Tracing.OTLPEndpoint,
+		SampleRatio: config.Get().Tracing.SampleRatio,
+	})
+	if err != nil {
+		logger.Logger.Errorf("Failed to set up tracing: %v", err)
+		os.Exit(1)
+	}
 
-	app := fiber.New()
-	// per-IP rate limiter middleware
-	app.Use(limiter.New(limiter.Config{
-		Max:        config.AppConfig.RequestsPerMin,
-		Expiration: time.Minute,
-		KeyGenerator: func(c *fiber.Ctx) string {
-			return c.IP()
-		},
-		LimitReached: func(c *fiber.Ctx) error {
-			return c.Status(fiber.StatusTooManyRequests).
-				JSON(fiber.Map{"error": "Rate limit exceeded"})
-		},
-	}))
-	// structured request logging middleware
-	app.Use(logger.RequestLogger())
-	api.SetupRoutes(app, handler)
+	srv, err := server.New()
+	if err != nil {
+		logger.Logger.Errorf("Failed to set up server: %v", err)
+		os.Exit(1)
+	}
 
-	serverAddr := fmt.Sprintf(":%d", config.AppConfig.Server.Port)
+	serverAddr := fmt.Sprintf(":%d", config.Get().Server.Port)
 	logger.Logger.Infof("Server running, address: %s", serverAddr)
 
+	// reload the configuration on SIGHUP and when the config file changes
+	reloadCh := make(chan os.Signal, 1)
+	signal.Notify(reloadCh, syscall.SIGHUP)
+	go func() {
+		for range reloadCh {
+			logger.Logger.Infof("SIGHUP received, reloading configuration")
+			_ = srv.Reload(*configFile)
+		}
+	}()
+	if *configFile != "" {
+		srv.WatchConfig(*configFile, 5*time.Second)
+	}
+
 	shutdownCh := make(chan os.Signal, 1)
 	signal.Notify(shutdownCh, os.Interrupt, syscall.SIGTERM)
 
@@ -69,15 +81,16 @@ func main() {
 		logger.Logger.Infof("Shutdown signal received, shutting down gracefully...")
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
-		// use ShutdownWithContext to respect timeout and exit promptly
-		if err := app.ShutdownWithContext(ctx); err != nil {
-			logger.Logger.Errorf("Error during server shutdown: %v", err)
+		_ = srv.Shutdown(ctx)
+		// flush buffered spans
+		if err := shutdownTracing(ctx); err != nil {
+			logger.Logger.Errorf("Error flushing traces: %v", err)
 		}
 		logger.Logger.Infof("Server has been shut down.")
 		os.Exit(0)
 	}()
 
-	if err := app.Listen(serverAddr); err != nil {
+	if err := srv.Listen(serverAddr); err != nil {
 		logger.Logger.Errorf("Failed to start server: %v", err)
 		os.Exit(1)
 	}
@@ -2,16 +2,24 @@ package api
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/Takenobou/thamestracker/internal/changes"
 	importedLogger "github.com/Takenobou/thamestracker/internal/helpers/logger"
 	"github.com/Takenobou/thamestracker/internal/models"
+	"github.com/Takenobou/thamestracker/internal/scheduler"
 	"github.com/Takenobou/thamestracker/internal/service"
+	"github.com/Takenobou/thamestracker/internal/sources"
+	"github.com/Takenobou/thamestracker/internal/validation"
 	"github.com/gofiber/fiber/v2"
 	"github.com/sony/gobreaker"
 	"github.com/stretchr/testify/assert"
@@ -20,7 +28,7 @@ import (
 // fakeService implements the ServiceInterface for testing.
 type fakeService struct{}
 
-func (f fakeService) GetBridgeLifts() ([]models.Event, error) {
+func (f fakeService) GetBridgeLifts(ctx context.Context) ([]models.Event, error) {
 	return []models.Event{
 		{
 			Timestamp:  time.Date(2025, 4, 5, 17, 45, 0, 0, time.UTC),
@@ -32,7 +40,7 @@ func (f fakeService) GetBridgeLifts() ([]models.Event, error) {
 	}, nil
 }
 
-func (f fakeService) GetVessels(vesselType string) ([]models.Event, error) {
+func (f fakeService) GetVessels(ctx context.Context, vesselType string) ([]models.Event, error) {
 	return []models.Event{
 		{
 			Timestamp:   time.Date(2025, 1, 25, 20, 33, 0, 0, time.UTC),
@@ -45,35 +53,131 @@ func (f fakeService) GetVessels(vesselType string) ([]models.Event, error) {
 	}, nil
 }
 
+func (f fakeService) GetHistory(after, before time.Time) ([]models.Event, error) {
+	events := []models.Event{
+		{Timestamp: time.Date(2024, 7, 1, 10, 0, 0, 0, time.UTC), VesselName: "Paddle Steamer Dixie Queen", Category: "bridge", Direction: "Up river"},
+		{Timestamp: time.Date(2024, 7, 2, 11, 0, 0, 0, time.UTC), VesselName: "Paddle Steamer Dixie Queen", Category: "bridge", Direction: "Down river"},
+		{Timestamp: time.Date(2024, 7, 2, 12, 0, 0, 0, time.UTC), VesselName: "Balclutha", Category: "bridge", Direction: "Up river"},
+		{Timestamp: time.Date(2024, 7, 3, 9, 0, 0, 0, time.UTC), VesselName: "SILVER STURGEON", Category: "inport", VoyageNo: "S7670", Nationality: "GBR", Location: "WOODS QUAY"},
+		{Timestamp: time.Date(2024, 7, 4, 8, 0, 0, 0, time.UTC), VesselName: "Barrier closed", Category: "barrier", Source: "thames-barrier"},
+		{Timestamp: time.Date(2024, 7, 4, 9, 0, 0, 0, time.UTC), VesselName: "Dixie Queen at Hammersmith", Category: "bridge", Source: "hammersmith-bridge"},
+	}
+	var out []models.Event
+	for _, e := range events {
+		if (after.IsZero() || !e.Timestamp.Before(after)) && (before.IsZero() || !e.Timestamp.After(before)) {
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
+
+func (f fakeService) GetChanges(cursor uint64, limit int) changes.Page {
+	feed := changes.NewFeed(10)
+	feed.Append([]changes.Change{
+		{Type: changes.Added, Event: models.Event{VesselName: "New Lift", Category: "bridge"}},
+		{Type: changes.Removed, Event: models.Event{VesselName: "Cancelled Lift", Category: "bridge"}},
+	})
+	return feed.Since(cursor, limit)
+}
+
+// SubscribeChanges emits one bridge and one vessel change, then ends the stream.
+func (f fakeService) SubscribeChanges() (<-chan changes.Change, func()) {
+	ch := make(chan changes.Change, 2)
+	ch <- changes.Change{ID: 3, Type: changes.Added, Event: models.Event{VesselName: "Dixie Queen", Category: "bridge"}}
+	ch <- changes.Change{ID: 4, Type: changes.Added, Event: models.Event{VesselName: "ADELINE", Category: "arrivals", To: "TILBURY DOCK"}}
+	close(ch)
+	return ch, func() {}
+}
+
+func (f fakeService) DataFreshness(string) service.Freshness { return service.Freshness{} }
+
 func (f fakeService) HealthCheck(ctx context.Context) error { return nil }
-func (f fakeService) ReadyCheck(ctx context.Context) error  { return nil }
+func (f fakeService) Readiness(ctx context.Context) service.Readiness {
+	return service.Readiness{Status: service.StatusOK, Checks: []service.Check{}}
+}
 
-func (f fakeService) ListLocations() ([]service.LocationStats, error) {
+func (f fakeService) ListLocations(ctx context.Context) ([]service.LocationStats, error) {
 	return []service.LocationStats{
 		{Name: "PortA", Code: "", Inport: 1, Arrivals: 2, Departures: 3, Total: 6},
 		{Name: "PortB", Code: "", Inport: 0, Arrivals: 1, Departures: 0, Total: 1},
 	}, nil
 }
 
-func (f fakeService) GetFilteredVessels(vesselType, location string) ([]models.Event, error) {
-	return f.GetVessels(vesselType)
+func (f fakeService) GetFilteredVessels(ctx context.Context, vesselType, location string) ([]models.Event, error) {
+	return f.GetVessels(ctx, vesselType)
+}
+
+func (f fakeService) ListSources() []sources.Info {
+	return []sources.Info{{Name: "thames-barrier", Type: "json", Category: "barrier", TTLSeconds: 3600}}
+}
+
+func (f fakeService) GetSourceEvents(ctx context.Context, name string) ([]models.Event, error) {
+	if name != "" && name != "thames-barrier" {
+		return nil, fmt.Errorf("%w: %s", sources.ErrUnknownSource, name)
+	}
+	return []models.Event{
+		{
+			Timestamp:  time.Date(2025, 4, 6, 9, 0, 0, 0, time.UTC),
+			VesselName: "Barrier closed",
+			Category:   "barrier",
+			Location:   "Thames Barrier",
+			Source:     "thames-barrier",
+		},
+		{
+			Timestamp:  time.Date(2025, 4, 6, 18, 0, 0, 0, time.UTC),
+			VesselName: "Barrier open",
+			Category:   "barrier",
+			Location:   "Thames Barrier",
+			Source:     "thames-barrier",
+		},
+	}, nil
 }
 
 // Error fakes for testing
 
 type errorService struct {
-	bridgeErr error
-	vesselErr error
+	bridgeErr  error
+	vesselErr  error
+	historyErr error
+	sourceErr  error
 }
 
-func (e errorService) GetBridgeLifts() ([]models.Event, error)   { return nil, e.bridgeErr }
-func (e errorService) GetVessels(string) ([]models.Event, error) { return nil, e.vesselErr }
-func (e errorService) GetFilteredVessels(string, string) ([]models.Event, error) {
+func (e errorService) GetBridgeLifts(context.Context) ([]models.Event, error) {
+	return nil, e.bridgeErr
+}
+func (e errorService) GetVessels(context.Context, string) ([]models.Event, error) {
+	return nil, e.vesselErr
+}
+func (e errorService) GetFilteredVessels(context.Context, string, string) ([]models.Event, error) {
 	return nil, e.vesselErr
 }
-func (e errorService) HealthCheck(ctx context.Context) error           { return nil }
-func (e errorService) ReadyCheck(ctx context.Context) error            { return nil }
-func (e errorService) ListLocations() ([]service.LocationStats, error) { return nil, nil }
+func (e errorService) GetHistory(time.Time, time.Time) ([]models.Event, error) {
+	return nil, e.historyErr
+}
+func (e errorService) GetChanges(cursor uint64, limit int) changes.Page {
+	return changes.Page{Changes: []changes.Change{}, NextCursor: cursor}
+}
+func (e errorService) SubscribeChanges() (<-chan changes.Change, func()) {
+	ch := make(chan changes.Change)
+	close(ch)
+	return ch, func() {}
+}
+func (e errorService) DataFreshness(string) service.Freshness { return service.Freshness{} }
+func (e errorService) HealthCheck(ctx context.Context) error  { return nil }
+func (e errorService) Readiness(ctx context.Context) service.Readiness {
+	return service.Readiness{Status: service.StatusDown, Checks: []service.Check{
+		{Name: "redis", Status: service.StatusDown, Critical: true, Error: "redis ping failed"},
+	}}
+}
+func (e errorService) ListLocations(context.Context) ([]service.LocationStats, error) {
+	return nil, nil
+}
+func (e errorService) ListSources() []sources.Info {
+	return []sources.Info{{Name: "thames-barrier", CoolOffSeconds: 90}}
+}
+func (e errorService) GetSourceEvents(context.Context, string) ([]models.Event, error) {
+	return nil, e.sourceErr
+}
 
 func setupTestApp(svc ServiceInterface) *fiber.App {
 	h := NewAPIHandler(svc)
@@ -84,6 +188,13 @@ func setupTestApp(svc ServiceInterface) *fiber.App {
 	app.Get("/vessels/calendar.ics", h.VesselsCalendarHandler)
 	app.Get("/healthz", h.Healthz)
 	app.Get("/readyz", h.Readyz)
+	app.Get("/history/bridge-lifts", h.GetBridgeLiftHistory)
+	app.Get("/history/vessels", h.GetVesselHistory)
+	app.Get("/changes", h.GetChanges)
+	app.Get("/stream", h.Stream)
+	app.Get("/sources", h.ListSources)
+	app.Get("/events", h.GetEvents)
+	app.Get("/events/calendar.ics", h.EventsCalendarHandler)
 	return app
 }
 
@@ -192,6 +303,158 @@ func TestReadyz_OK(t *testing.T) {
 	assert.Equal(t, 200, resp.StatusCode)
 }
 
+func TestReadyz_Down503(t *testing.T) {
+	app := setupTestApp(errorService{})
+	resp, _ := app.Test(httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, 503, resp.StatusCode)
+	var report service.Readiness
+	assert.NoError(t, decodeJSON(resp, &report))
+	assert.Equal(t, service.StatusDown, report.Status)
+	assert.Equal(t, "redis", report.Checks[0].Name)
+}
+
+func decodeEvents(t *testing.T, resp *http.Response) []models.Event {
+	t.Helper()
+	var events []models.Event
+	assert.NoError(t, decodeJSON(resp, &events))
+	return events
+}
+
+func decodeJSON(resp *http.Response, dest interface{}) error {
+	return json.NewDecoder(resp.Body).Decode(dest)
+}
+
+func TestBridgeLiftHistory_FiltersByNameAndRange(t *testing.T) {
+	app := setupTestApp(fakeService{})
+	r := httptest.NewRequest(http.MethodGet, "/history/bridge-lifts?name=dixie&after=2024-06-01T00:00:00Z&before=2024-08-31T23:59:59Z", nil)
+	resp, _ := app.Test(r)
+	assert.Equal(t, 200, resp.StatusCode)
+	events := decodeEvents(t, resp)
+	assert.Len(t, events, 2)
+	for _, e := range events {
+		assert.Equal(t, "bridge", e.Category)
+	}
+}
+
+func TestVesselHistory_ExcludesBridgeLifts(t *testing.T) {
+	app := setupTestApp(fakeService{})
+	r := httptest.NewRequest(http.MethodGet, "/history/vessels?nationality=gbr", nil)
+	resp, _ := app.Test(r)
+	assert.Equal(t, 200, resp.StatusCode)
+	events := decodeEvents(t, resp)
+	assert.Len(t, events, 1)
+	assert.Equal(t, "SILVER STURGEON", events[0].VesselName)
+}
+
+func TestHistory_ExcludesSourceEvents(t *testing.T) {
+	app := setupTestApp(fakeService{})
+	resp, _ := app.Test(httptest.NewRequest(http.MethodGet, "/history/vessels", nil))
+	assert.Equal(t, 200, resp.StatusCode)
+	events := decodeEvents(t, resp)
+	assert.Len(t, events, 1)
+	assert.Equal(t, "SILVER STURGEON", events[0].VesselName)
+
+	resp, _ = app.Test(httptest.NewRequest(http.MethodGet, "/history/bridge-lifts", nil))
+	assert.Equal(t, 200, resp.StatusCode)
+	for _, e := range decodeEvents(t, resp) {
+		assert.Empty(t, e.Source)
+	}
+}
+
+func TestHistory_EmptyResultIsArray(t *testing.T) {
+	app := setupTestApp(fakeService{})
+	r := httptest.NewRequest(http.MethodGet, "/history/bridge-lifts?after=2030-01-01T00:00:00Z", nil)
+	resp, _ := app.Test(r)
+	assert.Equal(t, 200, resp.StatusCode)
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, "[]", string(body))
+}
+
+func TestHistory_InvalidBefore400(t *testing.T) {
+	app := setupTestApp(fakeService{})
+	r := httptest.NewRequest(http.MethodGet, "/history/vessels?before=yesterday", nil)
+	resp, _ := app.Test(r)
+	assert.Equal(t, 400, resp.StatusCode)
+}
+
+func TestHistory_Disabled503(t *testing.T) {
+	app := setupTestApp(errorService{historyErr: service.ErrHistoryDisabled})
+	r := httptest.NewRequest(http.MethodGet, "/history/bridge-lifts", nil)
+	resp, _ := app.Test(r)
+	assert.Equal(t, 503, resp.StatusCode)
+}
+
+func TestHistory_StoreError500(t *testing.T) {
+	app := setupTestApp(errorService{historyErr: errors.New("fail")})
+	r := httptest.NewRequest(http.MethodGet, "/history/vessels", nil)
+	resp, _ := app.Test(r)
+	assert.Equal(t, 500, resp.StatusCode)
+}
+
+func TestChanges_Cursor(t *testing.T) {
+	app := setupTestApp(fakeService{})
+	r := httptest.NewRequest(http.MethodGet, "/changes?cursor=1", nil)
+	resp, _ := app.Test(r)
+	assert.Equal(t, 200, resp.StatusCode)
+	var page changes.Page
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&page))
+	assert.Len(t, page.Changes, 1)
+	assert.Equal(t, changes.Removed, page.Changes[0].Type)
+	assert.Equal(t, uint64(2), page.NextCursor)
+}
+
+func TestChanges_InvalidCursor400(t *testing.T) {
+	app := setupTestApp(fakeService{})
+	r := httptest.NewRequest(http.MethodGet, "/changes?cursor=-1", nil)
+	resp, _ := app.Test(r)
+	assert.Equal(t, 400, resp.StatusCode)
+}
+
+func TestStream_FiltersEvents(t *testing.T) {
+	app := setupTestApp(fakeService{})
+	r := httptest.NewRequest(http.MethodGet, "/stream?category=arrivals&location=tilbury", nil)
+	resp, err := app.Test(r)
+	assert.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	body, _ := io.ReadAll(resp.Body)
+	assert.Contains(t, string(body), "id: 4\nevent: added\n")
+	assert.Contains(t, string(body), "ADELINE")
+	assert.NotContains(t, string(body), "Dixie Queen")
+}
+
+func TestStream_ResumesFromLastEventID(t *testing.T) {
+	app := setupTestApp(fakeService{})
+	r := httptest.NewRequest(http.MethodGet, "/stream", nil)
+	r.Header.Set("Last-Event-ID", "1")
+	resp, err := app.Test(r)
+	assert.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	// backlog change 2 is replayed once, followed by live changes 3 and 4
+	assert.Equal(t, 1, strings.Count(string(body), "Cancelled Lift"))
+	assert.Contains(t, string(body), "id: 3\n")
+	assert.Contains(t, string(body), "id: 4\n")
+}
+
+func TestStream_SourceCategory(t *testing.T) {
+	app := setupTestApp(fakeService{})
+	for _, category := range []string{"barrier", "thames-barrier"} {
+		r := httptest.NewRequest(http.MethodGet, "/stream?category="+category, nil)
+		resp, err := app.Test(r)
+		assert.NoError(t, err)
+		assert.Equal(t, 200, resp.StatusCode, category)
+		body, _ := io.ReadAll(resp.Body)
+		assert.NotContains(t, string(body), "Dixie Queen")
+	}
+}
+
+func TestStream_InvalidCategory400(t *testing.T) {
+	app := setupTestApp(fakeService{})
+	r := httptest.NewRequest(http.MethodGet, "/stream?category=ferries", nil)
+	resp, _ := app.Test(r)
+	assert.Equal(t, 400, resp.StatusCode)
+}
+
 func TestAPI_PackageLoads(t *testing.T) {
 	assert.True(t, true)
 }
@@ -201,3 +464,130 @@ func TestMain(m *testing.M) {
 	importedLogger.InitLogger()
 	os.Exit(m.Run())
 }
+
+type fakeScrapeStatus []scheduler.JobStatus
+
+func (f fakeScrapeStatus) Status() []scheduler.JobStatus { return f }
+
+func TestScrapeStatus(t *testing.T) {
+	h := NewAPIHandler(fakeService{})
+	app := fiber.New()
+	app.Get("/status/scrapes", h.GetScrapeStatus)
+
+	// no scheduler attached
+	resp, _ := app.Test(httptest.NewRequest(http.MethodGet, "/status/scrapes", nil))
+	assert.Equal(t, 200, resp.StatusCode)
+	var body struct {
+		Jobs []scheduler.JobStatus `json:"jobs"`
+	}
+	assert.NoError(t, decodeJSON(resp, &body))
+	assert.Empty(t, body.Jobs)
+
+	next := time.Date(2025, 4, 5, 17, 0, 0, 0, time.UTC)
+	h.SetScrapeStatus(fakeScrapeStatus{{Name: "bridge", IntervalSeconds: 600, NextRun: &next, Runs: 2}})
+	resp, _ = app.Test(httptest.NewRequest(http.MethodGet, "/status/scrapes", nil))
+	assert.NoError(t, decodeJSON(resp, &body))
+	assert.Len(t, body.Jobs, 1)
+	assert.Equal(t, "bridge", body.Jobs[0].Name)
+	assert.True(t, next.Equal(*body.Jobs[0].NextRun))
+}
+
+// staleService serves bridge lifts from a last known good copy scraped 31 minutes ago.
+type staleService struct{ fakeService }
+
+func (s staleService) GetBridgeLifts(ctx context.Context) ([]models.Event, error) {
+	events, _ := s.fakeService.GetBridgeLifts(ctx)
+	for i := range events {
+		events[i].Stale = true
+	}
+	return events, nil
+}
+
+func (s staleService) DataFreshness(source string) service.Freshness {
+	if source == "bridge" {
+		return service.Freshness{UpdatedAt: time.Now().Add(-31 * time.Minute), Stale: true}
+	}
+	return service.Freshness{UpdatedAt: time.Now().Add(-2 * time.Minute)}
+}
+
+func TestBridgeLifts_StaleHeaders(t *testing.T) {
+	app := setupTestApp(staleService{})
+	resp, _ := app.Test(httptest.NewRequest(http.MethodGet, "/bridge-lifts", nil))
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "1860", resp.Header.Get("X-Data-Age"))
+	assert.Contains(t, resp.Header.Get("Warning"), "110")
+	events := decodeEvents(t, resp)
+	assert.True(t, events[0].Stale)
+
+	resp, _ = app.Test(httptest.NewRequest(http.MethodGet, "/vessels?type=inport", nil))
+	assert.Equal(t, "120", resp.Header.Get("X-Data-Age"))
+	assert.Empty(t, resp.Header.Get("Warning"))
+
+	resp, _ = app.Test(httptest.NewRequest(http.MethodGet, "/vessels/calendar.ics?type=inport", nil))
+	updated, err := time.Parse(time.RFC3339, resp.Header.Get("X-Data-Last-Updated"))
+	assert.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(-2*time.Minute), updated, 2*time.Second)
+}
+
+func TestBridgeLifts_NoFreshnessHeadersWhenUnknown(t *testing.T) {
+	app := setupTestApp(fakeService{})
+	resp, _ := app.Test(httptest.NewRequest(http.MethodGet, "/bridge-lifts", nil))
+	assert.Empty(t, resp.Header.Get("X-Data-Age"))
+	assert.Empty(t, resp.Header.Get("X-Data-Last-Updated"))
+	assert.Empty(t, resp.Header.Get("Warning"))
+}
+
+// ctxService records the context its bridge lifts were requested with.
+type ctxService struct {
+	fakeService
+	got chan context.Context
+}
+
+func (s ctxService) GetBridgeLifts(ctx context.Context) ([]models.Event, error) {
+	s.got <- ctx
+	return s.fakeService.GetBridgeLifts(ctx)
+}
+
+func TestRequestContext_DerivesFromBase(t *testing.T) {
+	svc := ctxService{got: make(chan context.Context, 1)}
+	base, stop := context.WithCancel(context.Background())
+	app := fiber.New()
+	app.Use(RequestContext(base))
+	app.Get("/bridge-lifts", NewAPIHandler(svc).GetBridgeLifts)
+
+	resp, _ := app.Test(httptest.NewRequest(http.MethodGet, "/bridge-lifts", nil))
+	assert.Equal(t, 200, resp.StatusCode)
+	ctx := <-svc.got
+	assert.ErrorIs(t, ctx.Err(), context.Canceled, "request context ends with the request")
+
+	stop()
+	app.Test(httptest.NewRequest(http.MethodGet, "/bridge-lifts", nil))
+	assert.ErrorIs(t, (<-svc.got).Err(), context.Canceled, "shutdown cancels new requests")
+}
+
+func TestSourceStatus(t *testing.T) {
+	h := NewAPIHandler(fakeService{})
+	app := fiber.New()
+	app.Get("/status/sources", h.GetSourceStatus)
+
+	resp, _ := app.Test(httptest.NewRequest(http.MethodGet, "/status/sources", nil))
+	assert.Equal(t, 200, resp.StatusCode)
+	var body struct {
+		Degraded bool                `json:"degraded"`
+		Sources  []validation.Status `json:"sources"`
+	}
+	assert.NoError(t, decodeJSON(resp, &body))
+	assert.False(t, body.Degraded)
+	assert.Empty(t, body.Sources)
+
+	tracker := validation.NewTracker(10)
+	tracker.Record("bridge", validation.Stats{RowsSeen: 10, Events: 10})
+	tracker.Record("bridge", validation.Stats{RowsSeen: 10, Events: 0, Skipped: map[string]int{"invalid_timestamp": 10}})
+	h.SetSourceStatus(tracker)
+	resp, _ = app.Test(httptest.NewRequest(http.MethodGet, "/status/sources", nil))
+	assert.NoError(t, decodeJSON(resp, &body))
+	assert.True(t, body.Degraded)
+	assert.Len(t, body.Sources, 1)
+	assert.Equal(t, "bridge", body.Sources[0].Source)
+	assert.Equal(t, 10, body.Sources[0].Last.Skipped["invalid_timestamp"])
+}
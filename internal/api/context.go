@@ -0,0 +1,66 @@
+package api
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/Takenobou/thamestracker/internal/helpers/logger"
+	"github.com/gofiber/fiber/v2"
+)
+
+// baseContextKey is the fiber.Ctx local holding the application's base context.
+const baseContextKey = "baseContext"
+
+// disconnectPoll is how often a running request checks whether its client
+// has gone away.
+const disconnectPoll = 250 * time.Millisecond
+
+// RequestContext gives every request a user context derived from base and
+// cancelled when the handler returns or the client disconnects, so cancelling
+// base (on shutdown) or hanging up aborts in-flight scrapes, cache calls and
+// retries. Handlers pass c.UserContext() down to the service.
+func RequestContext(base context.Context) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx, cancel := context.WithCancel(base)
+		defer cancel()
+		// fasthttp does not notice a client hanging up, so watch the socket
+		go watchDisconnect(ctx, c.Context().Conn(), cancel)
+		c.SetUserContext(ctx)
+		// WebSocket handlers outlive this middleware and derive their own context
+		c.Locals(baseContextKey, base)
+		return c.Next()
+	}
+}
+
+// appContext returns the base context stored by RequestContext, carrying the
+// request's logger, for work that continues after the handler returns.
+func appContext(c *fiber.Ctx) context.Context {
+	base, ok := c.Locals(baseContextKey).(context.Context)
+	if !ok {
+		base = context.Background()
+	}
+	return logger.WithContext(base, logger.FromContext(c.UserContext()))
+}
+
+// watchDisconnect calls cancel if the client on conn goes away before ctx is
+// done. It gives up quietly if conn cannot be checked.
+func watchDisconnect(ctx context.Context, conn net.Conn, cancel context.CancelFunc) {
+	tick := time.NewTicker(disconnectPoll)
+	defer tick.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+			closed, ok := peerClosed(conn)
+			if !ok {
+				return
+			}
+			if closed {
+				cancel()
+				return
+			}
+		}
+	}
+}
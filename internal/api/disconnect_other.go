@@ -0,0 +1,11 @@
+//go:build !unix
+
+package api
+
+import "net"
+
+// peerClosed cannot check the socket on this platform, so requests are only
+// cancelled when their handler returns or the server shuts down.
+func peerClosed(net.Conn) (closed, ok bool) {
+	return false, false
+}
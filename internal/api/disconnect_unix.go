@@ -0,0 +1,39 @@
+//go:build unix
+
+package api
+
+import (
+	"net"
+	"syscall"
+)
+
+// peerClosed reports whether the client at the other end of conn has closed
+// or reset the connection, by peeking at the socket without consuming
+// anything. ok is false when conn is not a socket that can be checked.
+func peerClosed(conn net.Conn) (closed, ok bool) {
+	if tc, isTLS := conn.(interface{ NetConn() net.Conn }); isTLS {
+		conn = tc.NetConn()
+	}
+	sc, isSocket := conn.(syscall.Conn)
+	if !isSocket {
+		return false, false
+	}
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return false, false
+	}
+	var buf [1]byte
+	err = raw.Control(func(fd uintptr) {
+		n, _, rerr := syscall.Recvfrom(int(fd), buf[:], syscall.MSG_PEEK|syscall.MSG_DONTWAIT)
+		switch rerr {
+		case nil:
+			// a zero-byte read is an orderly close; pending bytes are a
+			// pipelined request
+			closed = n == 0
+		case syscall.EAGAIN, syscall.EINTR:
+		default:
+			closed = true
+		}
+	})
+	return closed, err == nil
+}
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	calendar "github.com/Takenobou/thamestracker/internal/calendar"
+	"github.com/Takenobou/thamestracker/internal/changes"
 	"github.com/Takenobou/thamestracker/internal/config"
 	"github.com/Takenobou/thamestracker/internal/helpers/logger"
 	"github.com/Takenobou/thamestracker/internal/helpers/metrics"
@@ -17,19 +18,34 @@ import (
 	"github.com/Takenobou/thamestracker/internal/service"
 	ics "github.com/arran4/golang-ical"
 	"github.com/gofiber/fiber/v2"
-	"github.com/prometheus/client_golang/prometheus"
 	"github.com/sony/gobreaker"
 )
 
 // BridgeSvc defines interface for bridge lift methods.
 type BridgeSvc interface {
-	GetBridgeLifts() ([]models.Event, error)
+	GetBridgeLifts(ctx context.Context) ([]models.Event, error)
 }
 
 // VesselSvc defines interface for vessel methods.
 type VesselSvc interface {
-	GetVessels(vesselType string) ([]models.Event, error)
-	GetFilteredVessels(vesselType, location string) ([]models.Event, error)
+	GetVessels(ctx context.Context, vesselType string) ([]models.Event, error)
+	GetFilteredVessels(ctx context.Context, vesselType, location string) ([]models.Event, error)
+}
+
+// HistorySvc defines interface for historical event queries.
+type HistorySvc interface {
+	GetHistory(after, before time.Time) ([]models.Event, error)
+}
+
+// ChangesSvc defines interface for the scrape change feed.
+type ChangesSvc interface {
+	GetChanges(cursor uint64, limit int) changes.Page
+	SubscribeChanges() (<-chan changes.Change, func())
+}
+
+// FreshnessSvc reports how fresh the data served for a source is.
+type FreshnessSvc interface {
+	DataFreshness(source string) service.Freshness
 }
 
 // HealthSvc defines interface for health check.
@@ -39,35 +55,45 @@ type HealthSvc interface {
 
 // ReadinessSvc defines interface for readiness check.
 type ReadinessSvc interface {
-	ReadyCheck(ctx context.Context) error
+	Readiness(ctx context.Context) service.Readiness
 }
 
 // LocationSvc defines interface for location stats.
 type LocationSvc interface {
-	ListLocations() ([]service.LocationStats, error)
+	ListLocations(ctx context.Context) ([]service.LocationStats, error)
 }
 
 // ServiceInterface combines all service interfaces (for backwards compatibility).
 type ServiceInterface interface {
 	BridgeSvc
 	VesselSvc
+	HistorySvc
+	ChangesSvc
+	FreshnessSvc
 	HealthSvc
 	ReadinessSvc
 	LocationSvc
+	SourceSvc
 }
 
 // APIHandler holds separate service interfaces.
 type APIHandler struct {
 	bridge    BridgeSvc
 	vessel    VesselSvc
+	history   HistorySvc
+	changes   ChangesSvc
+	freshness FreshnessSvc
 	health    HealthSvc
 	readiness ReadinessSvc
 	location  LocationSvc
+	sources   SourceSvc
+	scrapes   ScrapeStatusSvc // optional; set with SetScrapeStatus
+	drift     SourceStatusSvc // optional; set with SetSourceStatus
 }
 
 // NewAPIHandler creates APIHandler from a combined service.
 func NewAPIHandler(svc ServiceInterface) *APIHandler {
-	return &APIHandler{bridge: svc, vessel: svc, health: svc, readiness: svc, location: svc}
+	return &APIHandler{bridge: svc, vessel: svc, history: svc, changes: svc, freshness: svc, health: svc, readiness: svc, location: svc, sources: svc}
 }
 
 func (h *APIHandler) GetBridgeLifts(c *fiber.Ctx) error {
@@ -79,15 +105,17 @@ func (h *APIHandler) GetBridgeLifts(c *fiber.Ctx) error {
 		opts.Category = "bridge"
 	}
 
-	events, err := h.bridge.GetBridgeLifts()
+	events, err := h.bridge.GetBridgeLifts(c.UserContext())
 	if err != nil {
 		if errors.Is(err, gobreaker.ErrOpenState) {
-			c.Set("Retry-After", strconv.Itoa(config.AppConfig.CircuitBreaker.CoolOffSeconds))
+			c.Set("Retry-After", strconv.Itoa(config.Get().CircuitBreaker.CoolOffSeconds))
 			return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": "Service temporarily unavailable"})
 		}
-		logger.Logger.Errorf("Error fetching bridge lifts: %v", err)
+		logger.FromContext(c.UserContext()).Errorf("Error fetching bridge lifts: %v", err)
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to retrieve bridge lift data"})
 	}
+	h.setFreshnessHeaders(c, "bridge", events)
+	cfg := config.Get()
 	filtered := utils.FilterEvents(events, utils.FilterOptions{
 		Name:                   opts.Name,
 		Category:               opts.Category,
@@ -95,8 +123,8 @@ func (h *APIHandler) GetBridgeLifts(c *fiber.Ctx) error {
 		Before:                 opts.Before,
 		Unique:                 opts.Unique,
 		Location:               opts.Location,
-		BridgeFilterPercentile: config.AppConfig.BridgeFilterPercentile,
-		BridgeFilterMaxCount:   config.AppConfig.BridgeFilterMaxCount,
+		BridgeFilterPercentile: cfg.BridgeFilterPercentile,
+		BridgeFilterMaxCount:   cfg.BridgeFilterMaxCount,
 	})
 	return c.JSON(filtered)
 }
@@ -107,18 +135,19 @@ func (h *APIHandler) GetVessels(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
 	}
 
-	events, err := h.vessel.GetVessels(opts.Category)
+	events, err := h.vessel.GetVessels(c.UserContext(), opts.Category)
 	if err != nil {
 		if errors.Is(err, gobreaker.ErrOpenState) {
-			c.Set("Retry-After", strconv.Itoa(config.AppConfig.CircuitBreaker.CoolOffSeconds))
+			c.Set("Retry-After", strconv.Itoa(config.Get().CircuitBreaker.CoolOffSeconds))
 			return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": "Service temporarily unavailable"})
 		}
 		if strings.HasPrefix(err.Error(), "invalid vesselType") {
 			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
 		}
-		logger.Logger.Errorf("Error fetching vessel data: %v", err)
+		logger.FromContext(c.UserContext()).Errorf("Error fetching vessel data: %v", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to retrieve vessel data"})
 	}
+	h.setFreshnessHeaders(c, opts.Category, events)
 	filtered := utils.FilterEvents(events, utils.FilterOptions{
 		Name:        opts.Name,
 		Category:    opts.Category,
@@ -141,15 +170,17 @@ func (h *APIHandler) BridgeCalendarHandler(c *fiber.Ctx) error {
 		opts.Category = "bridge"
 	}
 
-	events, err := h.bridge.GetBridgeLifts()
+	events, err := h.bridge.GetBridgeLifts(c.UserContext())
 	if err != nil {
 		if errors.Is(err, gobreaker.ErrOpenState) {
-			c.Set("Retry-After", strconv.Itoa(config.AppConfig.CircuitBreaker.CoolOffSeconds))
+			c.Set("Retry-After", strconv.Itoa(config.Get().CircuitBreaker.CoolOffSeconds))
 			return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": "Service temporarily unavailable"})
 		}
-		logger.Logger.Errorf("Error fetching bridge lifts: %v", err)
+		logger.FromContext(c.UserContext()).Errorf("Error fetching bridge lifts: %v", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to retrieve bridge lift data"})
 	}
+	h.setFreshnessHeaders(c, "bridge", events)
+	cfg := config.Get()
 	filtered := utils.FilterEvents(events, utils.FilterOptions{
 		Name:                   opts.Name,
 		Category:               opts.Category,
@@ -157,8 +188,8 @@ func (h *APIHandler) BridgeCalendarHandler(c *fiber.Ctx) error {
 		Before:                 opts.Before,
 		Unique:                 opts.Unique,
 		Location:               opts.Location,
-		BridgeFilterPercentile: config.AppConfig.BridgeFilterPercentile,
-		BridgeFilterMaxCount:   config.AppConfig.BridgeFilterMaxCount,
+		BridgeFilterPercentile: cfg.BridgeFilterPercentile,
+		BridgeFilterMaxCount:   cfg.BridgeFilterMaxCount,
 	})
 	cal := ics.NewCalendar()
 	cal.SetMethod(ics.MethodPublish)
@@ -180,15 +211,16 @@ func (h *APIHandler) VesselsCalendarHandler(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
 	}
 
-	events, err := h.vessel.GetVessels(opts.Category)
+	events, err := h.vessel.GetVessels(c.UserContext(), opts.Category)
 	if err != nil {
 		if errors.Is(err, gobreaker.ErrOpenState) {
-			c.Set("Retry-After", strconv.Itoa(config.AppConfig.CircuitBreaker.CoolOffSeconds))
+			c.Set("Retry-After", strconv.Itoa(config.Get().CircuitBreaker.CoolOffSeconds))
 			return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": "Service temporarily unavailable"})
 		}
-		logger.Logger.Errorf("Error fetching vessel data: %v", err)
+		logger.FromContext(c.UserContext()).Errorf("Error fetching vessel data: %v", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to retrieve vessel data"})
 	}
+	h.setFreshnessHeaders(c, opts.Category, events)
 	filtered := utils.FilterEvents(events, utils.FilterOptions{
 		Name:        opts.Name,
 		Category:    opts.Category,
@@ -211,6 +243,125 @@ func (h *APIHandler) VesselsCalendarHandler(c *fiber.Ctx) error {
 	return c.SendString(cal.Serialize())
 }
 
+// GetBridgeLiftHistory returns stored bridge lifts for an arbitrary past range.
+func (h *APIHandler) GetBridgeLiftHistory(c *fiber.Ctx) error {
+	opts := ParseQueryOptions(c, "bridge")
+	if err := validateBridgeQueryOptions(opts); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	opts.Category = "bridge"
+	events, err := h.historyEvents(opts)
+	if err != nil {
+		return historyError(c, err)
+	}
+	lifts := make([]models.Event, 0, len(events))
+	for _, e := range events {
+		// configured sources share the history store but have their own feeds
+		if e.Source == "" {
+			lifts = append(lifts, e)
+		}
+	}
+	cfg := config.Get()
+	filtered := utils.FilterEvents(lifts, utils.FilterOptions{
+		Name:                   opts.Name,
+		Category:               opts.Category,
+		After:                  opts.After,
+		Before:                 opts.Before,
+		Unique:                 opts.Unique,
+		Location:               opts.Location,
+		BridgeFilterPercentile: cfg.BridgeFilterPercentile,
+		BridgeFilterMaxCount:   cfg.BridgeFilterMaxCount,
+	})
+	return c.JSON(nonNil(filtered))
+}
+
+// GetVesselHistory returns stored vessel movements for an arbitrary past range.
+func (h *APIHandler) GetVesselHistory(c *fiber.Ctx) error {
+	opts := ParseQueryOptions(c, "all")
+	if err := validateVesselQueryOptions(opts); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	events, err := h.historyEvents(opts)
+	if err != nil {
+		return historyError(c, err)
+	}
+	vessels := make([]models.Event, 0, len(events))
+	for _, e := range events {
+		// configured sources share the history store but have their own feeds
+		if e.Category != "bridge" && e.Source == "" {
+			vessels = append(vessels, e)
+		}
+	}
+	filtered := utils.FilterEvents(vessels, utils.FilterOptions{
+		Name:        opts.Name,
+		Category:    opts.Category,
+		Nationality: opts.Nationality,
+		After:       opts.After,
+		Before:      opts.Before,
+		Unique:      opts.Unique,
+		Location:    opts.Location,
+	})
+	return c.JSON(nonNil(filtered))
+}
+
+// GetChanges returns events added, removed or rescheduled between scrapes,
+// starting after the given cursor.
+func (h *APIHandler) GetChanges(c *fiber.Ctx) error {
+	cursor, err := strconv.ParseUint(c.Query("cursor", "0"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid cursor"})
+	}
+	limit, err := strconv.Atoi(c.Query("limit", "100"))
+	if err != nil || limit < 1 || limit > 1000 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid limit"})
+	}
+	return c.JSON(h.changes.GetChanges(cursor, limit))
+}
+
+// historyEvents loads stored events bounded by the already-validated after/before options.
+func (h *APIHandler) historyEvents(opts QueryOptions) ([]models.Event, error) {
+	after, before, err := parseTimeRange(opts.After, opts.Before)
+	if err != nil {
+		return nil, err
+	}
+	return h.history.GetHistory(after, before)
+}
+
+func historyError(c *fiber.Ctx, err error) error {
+	if errors.Is(err, service.ErrHistoryDisabled) {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": "History is not enabled"})
+	}
+	logger.FromContext(c.UserContext()).Errorf("Error querying event history: %v", err)
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to retrieve history"})
+}
+
+// nonNil ensures empty results encode as [] rather than null.
+func nonNil(events []models.Event) []models.Event {
+	if events == nil {
+		return []models.Event{}
+	}
+	return events
+}
+
+// setFreshnessHeaders reports when the served data was scraped in
+// X-Data-Last-Updated and its age in X-Data-Age (seconds), and adds a Warning
+// header when it is a stale copy kept through an upstream failure.
+func (h *APIHandler) setFreshnessHeaders(c *fiber.Ctx, source string, events []models.Event) {
+	f := h.freshness.DataFreshness(source)
+	if !f.UpdatedAt.IsZero() {
+		age := time.Since(f.UpdatedAt)
+		if age < 0 {
+			age = 0
+		}
+		metrics.DataAgeSeconds.WithLabelValues(source).Observe(age.Seconds())
+		c.Set("X-Data-Last-Updated", f.UpdatedAt.UTC().Format(time.RFC3339))
+		c.Set("X-Data-Age", strconv.Itoa(int(age.Seconds())))
+	}
+	if f.Stale || (len(events) > 0 && events[0].Stale) {
+		c.Set("Warning", `110 - "Response is Stale"`)
+	}
+}
+
 // Healthz returns 200 OK if dependencies are healthy, 503 otherwise.
 func (h *APIHandler) Healthz(c *fiber.Ctx) error {
 	if err := h.health.HealthCheck(c.UserContext()); err != nil {
@@ -219,21 +370,18 @@ func (h *APIHandler) Healthz(c *fiber.Ctx) error {
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{"status": "ok"})
 }
 
-// Readyz returns 200 OK if dependencies are ready, 503 otherwise.
+// Readyz reports the status of each dependency, returning 200 OK when the
+// server is ready or degraded and 503 when it is down.
 func (h *APIHandler) Readyz(c *fiber.Ctx) error {
-	if err := h.readiness.ReadyCheck(c.UserContext()); err != nil {
-		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"status": "fail", "error": err.Error()})
+	report := h.readiness.Readiness(c.UserContext())
+	if report.Status == service.StatusDown {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(report)
 	}
-	return c.Status(fiber.StatusOK).JSON(fiber.Map{"status": "ok"})
+	return c.Status(fiber.StatusOK).JSON(report)
 }
 
 // GetLocations handles GET /locations endpoint.
 func (h *APIHandler) GetLocations(c *fiber.Ctx) error {
-	// metrics
-	timer := prometheus.NewTimer(metrics.LocationsRequestDuration)
-	defer timer.ObserveDuration()
-	metrics.LocationsRequests.Inc()
-
 	// parse query params
 	minTotal, err := strconv.Atoi(c.Query("minTotal", "0"))
 	if err != nil {
@@ -241,11 +389,12 @@ func (h *APIHandler) GetLocations(c *fiber.Ctx) error {
 	}
 	q := strings.ToLower(c.Query("q", ""))
 	// get aggregated stats
-	stats, err := h.location.ListLocations()
+	stats, err := h.location.ListLocations(c.UserContext())
 	if err != nil {
-		logger.Logger.Errorf("Error listing locations: %v", err)
+		logger.FromContext(c.UserContext()).Errorf("Error listing locations: %v", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to retrieve location data"})
 	}
+	h.setFreshnessHeaders(c, "all", nil)
 	// filter and return
 	var out []service.LocationStats
 	for _, s := range stats {
@@ -258,7 +407,7 @@ func (h *APIHandler) GetLocations(c *fiber.Ctx) error {
 		out = append(out, s)
 	}
 	// log at most one structured line
-	logger.Logger.Infof("path=/locations hits=%d", len(out))
+	logger.FromContext(c.UserContext()).Infof("path=/locations hits=%d", len(out))
 	return c.JSON(out)
 }
 
@@ -278,6 +427,12 @@ func validateBridgeQueryOptions(opts QueryOptions) error {
 }
 
 func validateTimeRange(after string, before string) error {
+	_, _, err := parseTimeRange(after, before)
+	return err
+}
+
+// parseTimeRange parses optional RFC3339 after/before bounds; unset bounds are zero.
+func parseTimeRange(after string, before string) (time.Time, time.Time, error) {
 	var afterTS time.Time
 	var beforeTS time.Time
 	var err error
@@ -285,17 +440,17 @@ func validateTimeRange(after string, before string) error {
 	if after != "" {
 		afterTS, err = time.Parse(time.RFC3339, after)
 		if err != nil {
-			return fmt.Errorf("invalid after parameter")
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid after parameter")
 		}
 	}
 	if before != "" {
 		beforeTS, err = time.Parse(time.RFC3339, before)
 		if err != nil {
-			return fmt.Errorf("invalid before parameter")
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid before parameter")
 		}
 	}
 	if !afterTS.IsZero() && !beforeTS.IsZero() && afterTS.After(beforeTS) {
-		return fmt.Errorf("after must be before or equal to before")
+		return time.Time{}, time.Time{}, fmt.Errorf("after must be before or equal to before")
 	}
-	return nil
+	return afterTS, beforeTS, nil
 }
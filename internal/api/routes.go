@@ -20,8 +20,19 @@ func SetupRoutes(app *fiber.App, handler *APIHandler) {
 	app.Get("/healthz", handler.Healthz)
 	app.Get("/readyz", handler.Readyz)
 	app.Get("/locations", handler.GetLocations)
+	app.Get("/history/bridge-lifts", handler.GetBridgeLiftHistory)
+	app.Get("/history/vessels", handler.GetVesselHistory)
+	app.Get("/changes", handler.GetChanges)
+	app.Get("/stream", handler.Stream)
+	app.Get("/sources", handler.ListSources)
+	app.Get("/events", handler.GetEvents)
+	app.Get("/events/calendar.ics", handler.EventsCalendarHandler)
+	app.Get("/status/scrapes", handler.GetScrapeStatus)
+	app.Get("/status/sources", handler.GetSourceStatus)
+	app.Use("/ws", WebSocketUpgrade)
+	app.Get("/ws", handler.WebSocket())
 	// Prometheus metrics endpoint (registered only when public)
-	if config.AppConfig.MetricsPublic {
+	if config.Get().MetricsPublic {
 		app.Get("/metrics", func(c *fiber.Ctx) error {
 			mfs, err := prometheus.DefaultGatherer.Gather()
 			if err != nil {
@@ -46,3 +57,14 @@ func SetupRoutes(app *fiber.App, handler *APIHandler) {
 		return c.Send(data)
 	})
 }
+
+// SetupAdminRoutes registers the token-protected admin API.
+func SetupAdminRoutes(app *fiber.App, webhooks *WebhookHandler, token string) {
+	admin := app.Group("/admin", AdminAuth(token))
+	admin.Get("/webhooks", webhooks.ListWebhooks)
+	admin.Post("/webhooks", webhooks.CreateWebhook)
+	admin.Get("/webhooks/deliveries", webhooks.ListDeliveries)
+	admin.Get("/webhooks/dead-letters", webhooks.ListDeadLetters)
+	admin.Post("/webhooks/dead-letters/:id/retry", webhooks.RetryDeadLetter)
+	admin.Delete("/webhooks/:id", webhooks.DeleteWebhook)
+}
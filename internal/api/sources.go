@@ -0,0 +1,125 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+
+	calendar "github.com/Takenobou/thamestracker/internal/calendar"
+	"github.com/Takenobou/thamestracker/internal/config"
+	"github.com/Takenobou/thamestracker/internal/helpers/logger"
+	"github.com/Takenobou/thamestracker/internal/helpers/utils"
+	"github.com/Takenobou/thamestracker/internal/models"
+	"github.com/Takenobou/thamestracker/internal/sources"
+	ics "github.com/arran4/golang-ical"
+	"github.com/gofiber/fiber/v2"
+	"github.com/sony/gobreaker"
+)
+
+// SourceSvc defines interface for the configured event sources.
+type SourceSvc interface {
+	ListSources() []sources.Info
+	GetSourceEvents(ctx context.Context, name string) ([]models.Event, error)
+}
+
+// ListSources handles GET /sources, describing each configured source.
+func (h *APIHandler) ListSources(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{"sources": h.sources.ListSources()})
+}
+
+// GetEvents handles GET /events, returning the events of one configured
+// source, or of all of them when no source is given.
+func (h *APIHandler) GetEvents(c *fiber.Ctx) error {
+	filtered, ok, err := h.sourceEvents(c)
+	if !ok {
+		return err
+	}
+	return c.JSON(filtered)
+}
+
+// EventsCalendarHandler returns iCalendar feed with configured source events.
+func (h *APIHandler) EventsCalendarHandler(c *fiber.Ctx) error {
+	filtered, ok, err := h.sourceEvents(c)
+	if !ok {
+		return err
+	}
+	cal := ics.NewCalendar()
+	cal.SetMethod(ics.MethodPublish)
+	cal.SetProductId("-//ThamesTracker//EN")
+	cal.SetRefreshInterval("PT1H")
+	cal.SetXWRTimezone("Europe/London")
+	cal.AddVTimezone(ics.NewTimezone("Europe/London"))
+	for _, e := range filtered {
+		calendar.BuildEvent(cal, e)
+	}
+	c.Set("Content-Type", "text/calendar")
+	return c.SendString(cal.Serialize())
+}
+
+// isSourceCategory reports whether category is the name or category of a
+// configured source, which the change streams accept as a category filter.
+func (h *APIHandler) isSourceCategory(category string) bool {
+	if category == "" {
+		return false
+	}
+	for _, info := range h.sources.ListSources() {
+		if strings.EqualFold(info.Name, category) || strings.EqualFold(info.Category, category) {
+			return true
+		}
+	}
+	return false
+}
+
+// sourceCoolOff returns the breaker cool-off of the named source, or the
+// default one if there is no such source.
+func (h *APIHandler) sourceCoolOff(name string) int {
+	for _, info := range h.sources.ListSources() {
+		if info.Name == name && info.CoolOffSeconds > 0 {
+			return info.CoolOffSeconds
+		}
+	}
+	return config.Get().CircuitBreaker.CoolOffSeconds
+}
+
+// sourceEvents loads and filters the events requested by c. When the request
+// fails it writes the error response and returns ok false.
+func (h *APIHandler) sourceEvents(c *fiber.Ctx) (events []models.Event, ok bool, err error) {
+	opts := ParseQueryOptions(c, "all")
+	if err := validateTimeRange(opts.After, opts.Before); err != nil {
+		return nil, false, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	name := strings.ToLower(c.Query("source", ""))
+
+	events, err = h.sources.GetSourceEvents(c.UserContext(), name)
+	if err != nil {
+		if errors.Is(err, sources.ErrUnknownSource) {
+			return nil, false, c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Unknown source: " + name})
+		}
+		if errors.Is(err, gobreaker.ErrOpenState) {
+			c.Set("Retry-After", strconv.Itoa(h.sourceCoolOff(name)))
+			return nil, false, c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": "Service temporarily unavailable"})
+		}
+		logger.FromContext(c.UserContext()).Errorf("Error fetching source events: %v", err)
+		return nil, false, c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to retrieve event data"})
+	}
+	if name != "" {
+		h.setFreshnessHeaders(c, name, events)
+	} else {
+		for _, e := range events {
+			if e.Stale {
+				c.Set("Warning", `110 - "Response is Stale"`)
+				break
+			}
+		}
+	}
+	return nonNil(utils.FilterEvents(events, utils.FilterOptions{
+		Name:        opts.Name,
+		Category:    opts.Category,
+		Nationality: opts.Nationality,
+		After:       opts.After,
+		Before:      opts.Before,
+		Unique:      opts.Unique,
+		Location:    opts.Location,
+	})), true, nil
+}
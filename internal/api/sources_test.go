@@ -0,0 +1,66 @@
+package api
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Takenobou/thamestracker/internal/sources"
+	"github.com/sony/gobreaker"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListSources(t *testing.T) {
+	app := setupTestApp(fakeService{})
+	resp, _ := app.Test(httptest.NewRequest(http.MethodGet, "/sources", nil))
+	assert.Equal(t, 200, resp.StatusCode)
+	var body struct {
+		Sources []sources.Info `json:"sources"`
+	}
+	assert.NoError(t, decodeJSON(resp, &body))
+	assert.Len(t, body.Sources, 1)
+	assert.Equal(t, "thames-barrier", body.Sources[0].Name)
+	assert.Equal(t, 3600, body.Sources[0].TTLSeconds)
+}
+
+func TestEvents_BySource(t *testing.T) {
+	app := setupTestApp(fakeService{})
+	resp, _ := app.Test(httptest.NewRequest(http.MethodGet, "/events?source=thames-barrier&name=closed", nil))
+	assert.Equal(t, 200, resp.StatusCode)
+	events := decodeEvents(t, resp)
+	assert.Len(t, events, 1)
+	assert.Equal(t, "thames-barrier", events[0].Source)
+
+	resp, _ = app.Test(httptest.NewRequest(http.MethodGet, "/events", nil))
+	assert.Len(t, decodeEvents(t, resp), 2)
+
+	resp, _ = app.Test(httptest.NewRequest(http.MethodGet, "/events?source=nope", nil))
+	assert.Equal(t, 404, resp.StatusCode)
+
+	resp, _ = app.Test(httptest.NewRequest(http.MethodGet, "/events?after=yesterday", nil))
+	assert.Equal(t, 400, resp.StatusCode)
+}
+
+func TestEvents_Errors(t *testing.T) {
+	app := setupTestApp(errorService{sourceErr: gobreaker.ErrOpenState})
+	resp, _ := app.Test(httptest.NewRequest(http.MethodGet, "/events?source=thames-barrier", nil))
+	assert.Equal(t, 503, resp.StatusCode)
+	assert.Equal(t, "90", resp.Header.Get("Retry-After"), "the source's own cool-off")
+
+	app = setupTestApp(errorService{sourceErr: errors.New("fail")})
+	resp, _ = app.Test(httptest.NewRequest(http.MethodGet, "/events?source=thames-barrier", nil))
+	assert.Equal(t, 500, resp.StatusCode)
+}
+
+func TestEventsCalendar(t *testing.T) {
+	app := setupTestApp(fakeService{})
+	resp, _ := app.Test(httptest.NewRequest(http.MethodGet, "/events/calendar.ics?source=thames-barrier", nil))
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "text/calendar", resp.Header.Get("Content-Type"))
+	body, _ := io.ReadAll(resp.Body)
+	assert.Contains(t, string(body), "SUMMARY:Barrier closed")
+	assert.Contains(t, string(body), "CATEGORIES:BARRIER")
+	assert.Contains(t, string(body), "LOCATION:Thames Barrier")
+}
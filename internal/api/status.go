@@ -0,0 +1,51 @@
+package api
+
+import (
+	"github.com/Takenobou/thamestracker/internal/scheduler"
+	"github.com/Takenobou/thamestracker/internal/validation"
+	"github.com/gofiber/fiber/v2"
+)
+
+// ScrapeStatusSvc reports the state of background scrape jobs.
+type ScrapeStatusSvc interface {
+	Status() []scheduler.JobStatus
+}
+
+// SetScrapeStatus attaches the background scheduler reported by /status/scrapes.
+func (h *APIHandler) SetScrapeStatus(s ScrapeStatusSvc) {
+	h.scrapes = s
+}
+
+// GetScrapeStatus handles GET /status/scrapes, listing the last and next run of
+// each background refresh job.
+func (h *APIHandler) GetScrapeStatus(c *fiber.Ctx) error {
+	jobs := []scheduler.JobStatus{}
+	if h.scrapes != nil {
+		jobs = h.scrapes.Status()
+	}
+	return c.JSON(fiber.Map{"jobs": jobs})
+}
+
+// SourceStatusSvc reports how each source's latest scrape compares with its baseline.
+type SourceStatusSvc interface {
+	Status() []validation.Status
+}
+
+// SetSourceStatus attaches the scrape validation tracker reported by /status/sources.
+func (h *APIHandler) SetSourceStatus(s SourceStatusSvc) {
+	h.drift = s
+}
+
+// GetSourceStatus handles GET /status/sources, listing the statistics of each
+// source's latest scrape, its baseline and whether it is degraded.
+func (h *APIHandler) GetSourceStatus(c *fiber.Ctx) error {
+	sources := []validation.Status{}
+	if h.drift != nil {
+		sources = h.drift.Status()
+	}
+	degraded := false
+	for _, s := range sources {
+		degraded = degraded || s.Degraded
+	}
+	return c.JSON(fiber.Map{"degraded": degraded, "sources": sources})
+}
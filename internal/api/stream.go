@@ -0,0 +1,122 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Takenobou/thamestracker/internal/changes"
+	"github.com/Takenobou/thamestracker/internal/helpers/logger"
+	"github.com/Takenobou/thamestracker/internal/helpers/utils"
+	"github.com/Takenobou/thamestracker/internal/models"
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+)
+
+// streamHeartbeat is how often an idle stream sends a keep-alive comment.
+const streamHeartbeat = 15 * time.Second
+
+// Stream pushes added, removed and rescheduled events as Server-Sent Events.
+// It accepts the same filters as /vessels plus category=bridge or a configured
+// source's name or category, and resumes
+// from the Last-Event-ID header (or cursor query) when reconnecting.
+func (h *APIHandler) Stream(c *fiber.Ctx) error {
+	opts := ParseQueryOptions(c, "all")
+	if err := h.validateStreamQueryOptions(opts); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	lastID := c.Get("Last-Event-ID", c.Query("cursor", ""))
+	var cursor uint64
+	resume := lastID != ""
+	if resume {
+		var err error
+		if cursor, err = strconv.ParseUint(lastID, 10, 64); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid cursor"})
+		}
+	}
+
+	updates, cancel := h.changes.SubscribeChanges()
+	var backlog []changes.Change
+	if resume {
+		backlog = h.changes.GetChanges(cursor, 1000).Changes
+	}
+
+	c.Set("Content-Type", "text/event-stream")
+	c.Set("Cache-Control", "no-cache")
+	c.Set("Connection", "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+	log := logger.FromContext(c.UserContext())
+	c.Context().SetBodyStreamWriter(fasthttp.StreamWriter(func(w *bufio.Writer) {
+		defer cancel()
+		// tell the client how long to wait before reconnecting
+		fmt.Fprintf(w, "retry: %d\n\n", (5 * time.Second).Milliseconds())
+		for _, change := range backlog {
+			if change.ID <= cursor {
+				continue
+			}
+			if matchesQuery(change.Event, opts) {
+				writeSSE(w, change)
+			}
+			cursor = change.ID
+		}
+		if err := w.Flush(); err != nil {
+			return
+		}
+		heartbeat := time.NewTicker(streamHeartbeat)
+		defer heartbeat.Stop()
+		for {
+			select {
+			case change, ok := <-updates:
+				if !ok {
+					return
+				}
+				if change.ID <= cursor || !matchesQuery(change.Event, opts) {
+					continue
+				}
+				writeSSE(w, change)
+			case <-heartbeat.C:
+				fmt.Fprint(w, ": keep-alive\n\n")
+			}
+			if err := w.Flush(); err != nil {
+				log.Infof("SSE client disconnected: %v", err)
+				return
+			}
+		}
+	}))
+	return nil
+}
+
+func writeSSE(w *bufio.Writer, change changes.Change) {
+	data, err := json.Marshal(change)
+	if err != nil {
+		logger.Logger.Errorf("Failed to encode change %d: %v", change.ID, err)
+		return
+	}
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", change.ID, change.Type, data)
+}
+
+// matchesQuery reports whether a single event passes the query filters. A
+// configured source's events can be picked by its name as well as its category.
+func matchesQuery(e models.Event, opts QueryOptions) bool {
+	if e.Source != "" && strings.EqualFold(e.Source, opts.Category) {
+		opts.Category = ""
+	}
+	return len(utils.FilterEvents([]models.Event{e}, utils.FilterOptions{
+		Name:        opts.Name,
+		Category:    opts.Category,
+		Nationality: opts.Nationality,
+		After:       opts.After,
+		Before:      opts.Before,
+		Location:    opts.Location,
+	})) > 0
+}
+
+func (h *APIHandler) validateStreamQueryOptions(opts QueryOptions) error {
+	if opts.Category == "bridge" || h.isSourceCategory(opts.Category) {
+		return validateTimeRange(opts.After, opts.Before)
+	}
+	return validateVesselQueryOptions(opts)
+}
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"github.com/Takenobou/thamestracker/internal/helpers/tracing"
+	"github.com/gofiber/fiber/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tracing starts a server span for every request, continuing any trace given
+// in an incoming traceparent header, and carries it in the user context so the
+// service, cache and scraper spans nest under it. It must run after
+// RequestContext, and after the request logger so the span records the
+// request's X-Request-ID.
+func Tracing() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		carrier := propagation.MapCarrier{}
+		if tp := c.Get("traceparent"); tp != "" {
+			carrier.Set("traceparent", tp)
+		}
+		ctx := otel.GetTextMapPropagator().Extract(c.UserContext(), carrier)
+		ctx, span := tracing.Tracer().Start(ctx, "HTTP "+c.Method(),
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", c.Method()),
+				attribute.String("url.path", c.Path()),
+				attribute.String("request.id", c.GetRespHeader("X-Request-ID")),
+			))
+		defer span.End()
+		c.SetUserContext(ctx)
+
+		err := c.Next()
+		// the matched route is only known once the handler has run
+		route := c.Route().Path
+		status := c.Response().StatusCode()
+		span.SetName(c.Method() + " " + route)
+		span.SetAttributes(
+			attribute.String("http.route", route),
+			attribute.Int("http.response.status_code", status),
+		)
+		if err != nil {
+			span.RecordError(err)
+		}
+		if err != nil || status >= fiber.StatusInternalServerError {
+			span.SetStatus(codes.Error, "")
+		}
+		return err
+	}
+}
@@ -0,0 +1,61 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Takenobou/thamestracker/internal/helpers/tracing"
+	"github.com/Takenobou/thamestracker/internal/models"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// tracedService starts a span of its own, as the real service does.
+type tracedService struct{ fakeService }
+
+func (s tracedService) GetBridgeLifts(ctx context.Context) ([]models.Event, error) {
+	_, span := tracing.Start(ctx, "Service.GetBridgeLifts")
+	defer span.End()
+	return s.fakeService.GetBridgeLifts(ctx)
+}
+
+func TestTracing_SpansNestUnderRequest(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	oldProvider, oldPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(oldProvider)
+		otel.SetTextMapPropagator(oldPropagator)
+	})
+
+	h := NewAPIHandler(tracedService{})
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		c.Set("X-Request-ID", "req-1")
+		return c.Next()
+	})
+	app.Use(Tracing())
+	app.Get("/bridge-lifts", h.GetBridgeLifts)
+
+	req := httptest.NewRequest(http.MethodGet, "/bridge-lifts", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	resp, _ := app.Test(req)
+	assert.Equal(t, 200, resp.StatusCode)
+
+	spans := recorder.Ended()
+	assert.Len(t, spans, 2)
+	svc, server := spans[0], spans[1]
+	assert.Equal(t, "GET /bridge-lifts", server.Name())
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", server.SpanContext().TraceID().String(), "the incoming trace is continued")
+	assert.Contains(t, server.Attributes(), attribute.String("request.id", "req-1"))
+	assert.Contains(t, server.Attributes(), attribute.Int("http.response.status_code", 200))
+	assert.Equal(t, server.SpanContext().SpanID(), svc.Parent().SpanID())
+}
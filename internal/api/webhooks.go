@@ -0,0 +1,92 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"strings"
+
+	"github.com/Takenobou/thamestracker/internal/helpers/logger"
+	"github.com/Takenobou/thamestracker/internal/webhooks"
+	"github.com/gofiber/fiber/v2"
+)
+
+// WebhookSvc defines interface for webhook administration.
+type WebhookSvc interface {
+	List() []webhooks.Hook
+	Create(h webhooks.Hook) (webhooks.Hook, error)
+	Delete(id string) error
+	Deliveries() []webhooks.Delivery
+	DeadLetters() []webhooks.Delivery
+	RetryDeadLetter(ctx context.Context, id string) error
+}
+
+// WebhookHandler serves the webhook admin API.
+type WebhookHandler struct {
+	hooks WebhookSvc
+}
+
+// NewWebhookHandler creates a WebhookHandler.
+func NewWebhookHandler(svc WebhookSvc) *WebhookHandler {
+	return &WebhookHandler{hooks: svc}
+}
+
+// AdminAuth requires "Authorization: Bearer <token>" on admin routes.
+func AdminAuth(token string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		got := strings.TrimPrefix(c.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+		}
+		return c.Next()
+	}
+}
+
+// ListWebhooks handles GET /admin/webhooks.
+func (h *WebhookHandler) ListWebhooks(c *fiber.Ctx) error {
+	return c.JSON(h.hooks.List())
+}
+
+// CreateWebhook handles POST /admin/webhooks.
+func (h *WebhookHandler) CreateWebhook(c *fiber.Ctx) error {
+	var hook webhooks.Hook
+	if err := c.BodyParser(&hook); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid JSON body"})
+	}
+	created, err := h.hooks.Create(hook)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusCreated).JSON(created)
+}
+
+// DeleteWebhook handles DELETE /admin/webhooks/:id.
+func (h *WebhookHandler) DeleteWebhook(c *fiber.Ctx) error {
+	if err := h.hooks.Delete(c.Params("id")); err != nil {
+		if errors.Is(err, webhooks.ErrNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+		}
+		logger.FromContext(c.UserContext()).Errorf("Error deleting webhook: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to delete webhook"})
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// ListDeliveries handles GET /admin/webhooks/deliveries.
+func (h *WebhookHandler) ListDeliveries(c *fiber.Ctx) error {
+	return c.JSON(h.hooks.Deliveries())
+}
+
+// ListDeadLetters handles GET /admin/webhooks/dead-letters.
+func (h *WebhookHandler) ListDeadLetters(c *fiber.Ctx) error {
+	return c.JSON(h.hooks.DeadLetters())
+}
+
+// RetryDeadLetter handles POST /admin/webhooks/dead-letters/:id/retry. The
+// delivery outlives the request, so it is bound to the server's lifetime.
+func (h *WebhookHandler) RetryDeadLetter(c *fiber.Ctx) error {
+	if err := h.hooks.RetryDeadLetter(appContext(c), c.Params("id")); err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.SendStatus(fiber.StatusAccepted)
+}
@@ -0,0 +1,100 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Takenobou/thamestracker/internal/webhooks"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupAdminApp(t *testing.T) *fiber.App {
+	t.Helper()
+	m, err := webhooks.NewManager(filepath.Join(t.TempDir(), "webhooks.json"), webhooks.Options{})
+	assert.NoError(t, err)
+	app := fiber.New()
+	SetupAdminRoutes(app, NewWebhookHandler(m), "token")
+	return app
+}
+
+func TestAdmin_RequiresToken(t *testing.T) {
+	app := setupAdminApp(t)
+	r := httptest.NewRequest(http.MethodGet, "/admin/webhooks", nil)
+	resp, _ := app.Test(r)
+	assert.Equal(t, 401, resp.StatusCode)
+
+	r.Header.Set("Authorization", "Bearer wrong")
+	resp, _ = app.Test(r)
+	assert.Equal(t, 401, resp.StatusCode)
+}
+
+func TestAdmin_CreateListDeleteWebhook(t *testing.T) {
+	app := setupAdminApp(t)
+	body := `{"url":"https://hooks.example.com/x","secret":"s","filter":"category=bridge","lead_minutes":15}`
+	r := httptest.NewRequest(http.MethodPost, "/admin/webhooks", strings.NewReader(body))
+	r.Header.Set("Authorization", "Bearer token")
+	r.Header.Set("Content-Type", "application/json")
+	resp, _ := app.Test(r)
+	assert.Equal(t, 201, resp.StatusCode)
+
+	r = httptest.NewRequest(http.MethodGet, "/admin/webhooks", nil)
+	r.Header.Set("Authorization", "Bearer token")
+	resp, _ = app.Test(r)
+	assert.Equal(t, 200, resp.StatusCode)
+	var hooks []webhooks.Hook
+	assert.NoError(t, decodeJSON(resp, &hooks))
+	assert.Len(t, hooks, 1)
+	assert.Equal(t, "********", hooks[0].Secret)
+
+	r = httptest.NewRequest(http.MethodDelete, "/admin/webhooks/"+hooks[0].ID, nil)
+	r.Header.Set("Authorization", "Bearer token")
+	resp, _ = app.Test(r)
+	assert.Equal(t, 204, resp.StatusCode)
+
+	resp, _ = app.Test(r)
+	assert.Equal(t, 404, resp.StatusCode)
+}
+
+func TestAdmin_CreateInvalidWebhook400(t *testing.T) {
+	app := setupAdminApp(t)
+	r := httptest.NewRequest(http.MethodPost, "/admin/webhooks", strings.NewReader(`{"url":"not a url"}`))
+	r.Header.Set("Authorization", "Bearer token")
+	r.Header.Set("Content-Type", "application/json")
+	resp, _ := app.Test(r)
+	assert.Equal(t, 400, resp.StatusCode)
+}
+
+// retryRecorder records the context a dead letter is retried with.
+type retryRecorder struct {
+	*webhooks.Manager
+	ctx context.Context
+}
+
+func (r *retryRecorder) RetryDeadLetter(ctx context.Context, id string) error {
+	r.ctx = ctx
+	return nil
+}
+
+func TestAdmin_RetryDeadLetterOutlivesRequest(t *testing.T) {
+	base, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	rec := &retryRecorder{}
+	app := fiber.New()
+	app.Use(RequestContext(base))
+	SetupAdminRoutes(app, NewWebhookHandler(rec), "token")
+
+	r := httptest.NewRequest(http.MethodPost, "/admin/webhooks/dead-letters/d1/retry", nil)
+	r.Header.Set("Authorization", "Bearer token")
+	resp, _ := app.Test(r)
+	assert.Equal(t, 202, resp.StatusCode)
+
+	// the delivery continues after the response, until the server shuts down
+	assert.NoError(t, rec.ctx.Err())
+	cancel()
+	assert.Error(t, rec.ctx.Err())
+}
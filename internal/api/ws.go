@@ -0,0 +1,282 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/Takenobou/thamestracker/internal/changes"
+	"github.com/Takenobou/thamestracker/internal/helpers/logger"
+	"github.com/Takenobou/thamestracker/internal/models"
+	"github.com/gofiber/contrib/websocket"
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+)
+
+// wsHeartbeat is how often the server sends a heartbeat message.
+const wsHeartbeat = 30 * time.Second
+
+// wsClientMessage is a subscribe/unsubscribe request sent by a WebSocket client.
+type wsClientMessage struct {
+	Action        string   `json:"action"` // "subscribe", "unsubscribe" or "ping"
+	Names         []string `json:"names,omitempty"`
+	Categories    []string `json:"categories,omitempty"`
+	Locations     []string `json:"locations,omitempty"`
+	Nationalities []string `json:"nationalities,omitempty"`
+}
+
+// wsServerMessage is sent to WebSocket clients; Type selects which fields are set.
+type wsServerMessage struct {
+	Type   string          `json:"type"` // "subscribed", "snapshot", "change", "heartbeat", "pong" or "error"
+	Filter *wsFilter       `json:"filter,omitempty"`
+	Events []models.Event  `json:"events,omitempty"` // omitted when a snapshot matches nothing
+	Change *changes.Change `json:"change,omitempty"`
+	Error  string          `json:"error,omitempty"`
+	Time   *time.Time      `json:"time,omitempty"`
+}
+
+// wsFilter is the per-connection subscription. Values within a field are
+// OR-ed, fields are AND-ed, using the same matching rules as QueryOptions.
+type wsFilter struct {
+	Names         []string `json:"names"`
+	Categories    []string `json:"categories"`
+	Locations     []string `json:"locations"`
+	Nationalities []string `json:"nationalities"`
+	active        bool
+}
+
+// wsBuiltinCategories are the categories of the bridge and vessel feeds; the
+// names and categories of configured sources are accepted as well.
+var wsBuiltinCategories = map[string]bool{"all": true, "bridge": true, "inport": true, "arrivals": true, "departures": true, "forecast": true}
+
+func (f *wsFilter) add(m wsClientMessage) {
+	f.Names = addValues(f.Names, m.Names)
+	f.Categories = addValues(f.Categories, m.Categories)
+	f.Locations = addValues(f.Locations, m.Locations)
+	f.Nationalities = addValues(f.Nationalities, m.Nationalities)
+	f.active = true
+}
+
+// remove drops the given values; an unsubscribe without values (or one that
+// leaves every field empty) ends the subscription.
+func (f *wsFilter) remove(m wsClientMessage) {
+	if len(m.Names)+len(m.Categories)+len(m.Locations)+len(m.Nationalities) == 0 {
+		*f = wsFilter{}
+		return
+	}
+	f.Names = removeValues(f.Names, m.Names)
+	f.Categories = removeValues(f.Categories, m.Categories)
+	f.Locations = removeValues(f.Locations, m.Locations)
+	f.Nationalities = removeValues(f.Nationalities, m.Nationalities)
+	if len(f.Names)+len(f.Categories)+len(f.Locations)+len(f.Nationalities) == 0 {
+		*f = wsFilter{}
+	}
+}
+
+func (f *wsFilter) matches(e models.Event) bool {
+	if !f.active {
+		return false
+	}
+	return matchesAny(f.Names, e, func(v string) QueryOptions { return QueryOptions{Name: v} }) &&
+		matchesAny(f.Categories, e, func(v string) QueryOptions { return QueryOptions{Category: v} }) &&
+		matchesAny(f.Locations, e, func(v string) QueryOptions { return QueryOptions{Location: v} }) &&
+		matchesAny(f.Nationalities, e, func(v string) QueryOptions { return QueryOptions{Nationality: v} })
+}
+
+func matchesAny(values []string, e models.Event, opts func(string) QueryOptions) bool {
+	if len(values) == 0 {
+		return true
+	}
+	for _, v := range values {
+		if matchesQuery(e, opts(v)) {
+			return true
+		}
+	}
+	return false
+}
+
+func addValues(existing, values []string) []string {
+	for _, v := range values {
+		v = strings.ToLower(strings.TrimSpace(v))
+		if v == "" || containsValue(existing, v) {
+			continue
+		}
+		existing = append(existing, v)
+	}
+	return existing
+}
+
+func removeValues(existing, values []string) []string {
+	out := existing[:0]
+	for _, v := range existing {
+		if !containsValue(lowerAll(values), v) {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func containsValue(values []string, v string) bool {
+	for _, x := range values {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+func lowerAll(values []string) []string {
+	out := make([]string, len(values))
+	for i, v := range values {
+		out[i] = strings.ToLower(strings.TrimSpace(v))
+	}
+	return out
+}
+
+// WebSocketUpgrade rejects non-WebSocket requests to the /ws endpoint.
+func WebSocketUpgrade(c *fiber.Ctx) error {
+	if websocket.IsWebSocketUpgrade(c) {
+		return c.Next()
+	}
+	return c.Status(fiber.StatusUpgradeRequired).JSON(fiber.Map{"error": "WebSocket upgrade required"})
+}
+
+// WebSocket returns the /ws handler: clients send subscribe/unsubscribe
+// messages and receive a snapshot of matching events, then live changes.
+func (h *APIHandler) WebSocket() fiber.Handler {
+	return websocket.New(h.serveWebSocket)
+}
+
+func (h *APIHandler) serveWebSocket(conn *websocket.Conn) {
+	updates, cancel := h.changes.SubscribeChanges()
+	defer cancel()
+	// cancels snapshot scrapes once the connection ends
+	ctx, cancelCtx := context.WithCancel(connBaseContext(conn))
+	defer cancelCtx()
+
+	// only this goroutine writes; the reader forwards client messages
+	incoming := make(chan wsClientMessage)
+	done := make(chan struct{})
+	quit := make(chan struct{})
+	defer close(quit)
+	go func() {
+		defer close(done)
+		for {
+			var msg wsClientMessage
+			if err := conn.ReadJSON(&msg); err != nil {
+				var syntaxErr *json.SyntaxError
+				var typeErr *json.UnmarshalTypeError
+				if !errors.As(err, &syntaxErr) && !errors.As(err, &typeErr) {
+					return
+				}
+				msg = wsClientMessage{Action: "invalid"}
+			}
+			select {
+			case incoming <- msg:
+			case <-quit:
+				return
+			}
+		}
+	}()
+
+	var filter wsFilter
+	heartbeat := time.NewTicker(wsHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		var out []wsServerMessage
+		select {
+		case <-done:
+			return
+		case msg := <-incoming:
+			out = h.handleWSMessage(ctx, &filter, msg)
+		case change, ok := <-updates:
+			if !ok {
+				_ = conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down"))
+				return
+			}
+			if filter.matches(change.Event) {
+				c := change
+				out = []wsServerMessage{{Type: "change", Change: &c}}
+			}
+		case t := <-heartbeat.C:
+			out = []wsServerMessage{{Type: "heartbeat", Time: &t}}
+		}
+		for _, m := range out {
+			if err := conn.WriteJSON(m); err != nil {
+				logger.FromContext(ctx).Infof("WebSocket client disconnected: %v", err)
+				return
+			}
+		}
+	}
+}
+
+// connBaseContext returns the base context stored by RequestContext, so that
+// server shutdown also cancels scrapes started by a WebSocket connection. It
+// carries the upgrade request's logger, so the connection's logs share its ID.
+func connBaseContext(conn *websocket.Conn) context.Context {
+	ctx, ok := conn.Locals(baseContextKey).(context.Context)
+	if !ok {
+		ctx = context.Background()
+	}
+	if l, ok := conn.Locals(logger.LocalsKey).(*zap.SugaredLogger); ok {
+		ctx = logger.WithContext(ctx, l)
+	}
+	return ctx
+}
+
+func (h *APIHandler) handleWSMessage(ctx context.Context, filter *wsFilter, msg wsClientMessage) []wsServerMessage {
+	switch strings.ToLower(msg.Action) {
+	case "subscribe":
+		for _, cat := range msg.Categories {
+			if !wsBuiltinCategories[strings.ToLower(cat)] && !h.isSourceCategory(cat) {
+				return []wsServerMessage{{Type: "error", Error: "invalid category: " + cat}}
+			}
+		}
+		filter.add(msg)
+		f := *filter
+		return []wsServerMessage{{Type: "subscribed", Filter: &f}, h.wsSnapshot(ctx, filter)}
+	case "unsubscribe":
+		filter.remove(msg)
+		f := *filter
+		return []wsServerMessage{{Type: "subscribed", Filter: &f}}
+	case "ping":
+		now := time.Now()
+		return []wsServerMessage{{Type: "pong", Time: &now}}
+	case "invalid":
+		return []wsServerMessage{{Type: "error", Error: "invalid JSON message"}}
+	default:
+		return []wsServerMessage{{Type: "error", Error: "unknown action: " + msg.Action}}
+	}
+}
+
+// wsSnapshot returns the current events matching the subscription.
+func (h *APIHandler) wsSnapshot(ctx context.Context, filter *wsFilter) wsServerMessage {
+	var all []models.Event
+	lifts, err := h.bridge.GetBridgeLifts(ctx)
+	if err != nil {
+		logger.FromContext(ctx).Errorf("Error fetching bridge lifts for snapshot: %v", err)
+		return wsServerMessage{Type: "error", Error: "Failed to retrieve snapshot"}
+	}
+	all = append(all, lifts...)
+	vessels, err := h.vessel.GetVessels(ctx, "all")
+	if err != nil {
+		logger.FromContext(ctx).Errorf("Error fetching vessels for snapshot: %v", err)
+		return wsServerMessage{Type: "error", Error: "Failed to retrieve snapshot"}
+	}
+	all = append(all, vessels...)
+	// configured sources are optional, so one failing only leaves its events out
+	extra, err := h.sources.GetSourceEvents(ctx, "")
+	if err != nil {
+		logger.FromContext(ctx).Warnf("Leaving source events out of snapshot: %v", err)
+	}
+	all = append(all, extra...)
+	events := make([]models.Event, 0)
+	for _, e := range all {
+		if filter.matches(e) {
+			events = append(events, e)
+		}
+	}
+	return wsServerMessage{Type: "snapshot", Events: events}
+}
@@ -0,0 +1,130 @@
+package api
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Takenobou/thamestracker/internal/changes"
+	"github.com/Takenobou/thamestracker/internal/models"
+	"github.com/fasthttp/websocket"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+// wsService feeds changes from a channel the test controls.
+type wsService struct {
+	fakeService
+	updates chan changes.Change
+}
+
+func (s wsService) SubscribeChanges() (<-chan changes.Change, func()) {
+	return s.updates, func() {}
+}
+
+func startWSServer(t *testing.T, svc ServiceInterface) string {
+	t.Helper()
+	h := NewAPIHandler(svc)
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	app.Use("/ws", WebSocketUpgrade)
+	app.Get("/ws", h.WebSocket())
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	go app.Listener(ln)
+	t.Cleanup(func() { app.Shutdown() })
+	return "ws://" + ln.Addr().String() + "/ws"
+}
+
+func readWS(t *testing.T, conn *websocket.Conn) wsServerMessage {
+	t.Helper()
+	var msg wsServerMessage
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	assert.NoError(t, conn.ReadJSON(&msg))
+	return msg
+}
+
+func TestWebSocket_SubscribeSnapshotAndChanges(t *testing.T) {
+	updates := make(chan changes.Change, 4)
+	url := startWSServer(t, wsService{updates: updates})
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	assert.NoError(t, conn.WriteJSON(wsClientMessage{Action: "subscribe", Categories: []string{"bridge"}}))
+	ack := readWS(t, conn)
+	assert.Equal(t, "subscribed", ack.Type)
+	assert.Equal(t, []string{"bridge"}, ack.Filter.Categories)
+	snap := readWS(t, conn)
+	assert.Equal(t, "snapshot", snap.Type)
+	assert.Len(t, snap.Events, 1)
+	assert.Equal(t, "bridge", snap.Events[0].Category)
+
+	updates <- changes.Change{ID: 1, Type: changes.Added, Event: models.Event{VesselName: "Skipped", Category: "arrivals"}}
+	updates <- changes.Change{ID: 2, Type: changes.Removed, Event: models.Event{VesselName: "Dixie Queen", Category: "bridge"}}
+	msg := readWS(t, conn)
+	assert.Equal(t, "change", msg.Type)
+	assert.Equal(t, uint64(2), msg.Change.ID)
+
+	// switching subscription without reconnecting
+	assert.NoError(t, conn.WriteJSON(wsClientMessage{Action: "unsubscribe"}))
+	assert.Equal(t, "subscribed", readWS(t, conn).Type)
+	assert.NoError(t, conn.WriteJSON(wsClientMessage{Action: "subscribe", Names: []string{"skipped"}}))
+	assert.Equal(t, "subscribed", readWS(t, conn).Type)
+	assert.Equal(t, "snapshot", readWS(t, conn).Type)
+	updates <- changes.Change{ID: 3, Type: changes.Added, Event: models.Event{VesselName: "Dixie Queen", Category: "bridge"}}
+	updates <- changes.Change{ID: 4, Type: changes.Added, Event: models.Event{VesselName: "Skipped", Category: "arrivals"}}
+	msg = readWS(t, conn)
+	assert.Equal(t, uint64(4), msg.Change.ID)
+}
+
+func TestWebSocket_SubscribeToSource(t *testing.T) {
+	updates := make(chan changes.Change, 2)
+	url := startWSServer(t, wsService{updates: updates})
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	// a configured source can be picked by its category or its name
+	assert.NoError(t, conn.WriteJSON(wsClientMessage{Action: "subscribe", Categories: []string{"barrier"}}))
+	assert.Equal(t, "subscribed", readWS(t, conn).Type)
+	snap := readWS(t, conn)
+	assert.Equal(t, "snapshot", snap.Type)
+	assert.Len(t, snap.Events, 2)
+	assert.Equal(t, "thames-barrier", snap.Events[0].Source)
+
+	assert.NoError(t, conn.WriteJSON(wsClientMessage{Action: "unsubscribe"}))
+	assert.Equal(t, "subscribed", readWS(t, conn).Type)
+	assert.NoError(t, conn.WriteJSON(wsClientMessage{Action: "subscribe", Categories: []string{"Thames-Barrier"}}))
+	assert.Equal(t, "subscribed", readWS(t, conn).Type)
+	assert.Len(t, readWS(t, conn).Events, 2)
+
+	updates <- changes.Change{ID: 1, Type: changes.Added, Event: models.Event{VesselName: "Dixie Queen", Category: "bridge"}}
+	updates <- changes.Change{ID: 2, Type: changes.Added, Event: models.Event{VesselName: "Barrier closed", Category: "barrier", Source: "thames-barrier"}}
+	msg := readWS(t, conn)
+	assert.Equal(t, "change", msg.Type)
+	assert.Equal(t, uint64(2), msg.Change.ID)
+}
+
+func TestWebSocket_InvalidMessages(t *testing.T) {
+	url := startWSServer(t, wsService{updates: make(chan changes.Change)})
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	assert.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte("not json")))
+	assert.Equal(t, "error", readWS(t, conn).Type)
+	assert.NoError(t, conn.WriteJSON(wsClientMessage{Action: "subscribe", Categories: []string{"ferries"}}))
+	assert.Equal(t, "error", readWS(t, conn).Type)
+	assert.NoError(t, conn.WriteJSON(wsClientMessage{Action: "ping"}))
+	assert.Equal(t, "pong", readWS(t, conn).Type)
+}
+
+func TestWebSocket_RequiresUpgrade(t *testing.T) {
+	app := fiber.New()
+	app.Use("/ws", WebSocketUpgrade)
+	app.Get("/ws", NewAPIHandler(fakeService{}).WebSocket())
+	resp, _ := app.Test(httptest.NewRequest(http.MethodGet, "/ws", nil))
+	assert.Equal(t, fiber.StatusUpgradeRequired, resp.StatusCode)
+}
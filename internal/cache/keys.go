@@ -22,3 +22,14 @@ func KeyVessels(vesselType string) string {
 func KeyVesselsByLoc(vesselType, location string) string {
 	return fmt.Sprintf("v3_vessels_%s_location_%s", vesselType, location)
 }
+
+// KeySnapshot returns the cache key for the last scraped snapshot of a category,
+// used for change detection between scrapes.
+func KeySnapshot(category string) string {
+	return fmt.Sprintf("v3_snapshot_%s", category)
+}
+
+// KeySource returns the cache key for the events of a configured source.
+func KeySource(name string) string {
+	return fmt.Sprintf("v3_source_%s", name)
+}
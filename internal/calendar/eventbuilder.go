@@ -24,7 +24,11 @@ func MakeUID(eventType, name string, start time.Time) string {
 
 // BuildEvent constructs and configures a VEVENT for a generic Event.
 func BuildEvent(cal *ics.Calendar, e models.Event) {
-	eid := MakeUID(e.Category, e.VesselName, e.Timestamp)
+	eventType := e.Category
+	if e.Source != "" {
+		eventType = e.Source + "/" + e.Category
+	}
+	eid := MakeUID(eventType, e.VesselName, e.Timestamp)
 	event := cal.AddEvent(eid)
 	now := time.Now()
 	event.SetCreatedTime(now)
@@ -39,8 +43,22 @@ func BuildEvent(cal *ics.Calendar, e models.Event) {
 	summary := ""
 	location := ""
 
-	switch strings.ToLower(e.Category) {
-	case "bridge":
+	switch category := strings.ToLower(e.Category); {
+	case e.Source != "":
+		// configured sources describe their own events
+		summary = e.VesselName
+		location = e.Location
+		var lines []string
+		if e.Direction != "" {
+			lines = append(lines, fmt.Sprintf("Direction: %s", e.Direction))
+		}
+		if e.From != "" || e.To != "" {
+			lines = append(lines, fmt.Sprintf("Voyage: %s → %s", e.From, e.To))
+		}
+		lines = append(lines, fmt.Sprintf("Source: %s", e.Source))
+		description = strings.Join(lines, "\n")
+		event.SetProperty("CATEGORIES", strings.ToUpper(e.Category))
+	case category == "bridge":
 		summary = fmt.Sprintf("Tower Bridge Lift - %s", e.VesselName)
 		location = "222 Tower Bridge Road, London, SE1 2UP"
 		description = fmt.Sprintf("Direction: %s", e.Direction)
@@ -48,7 +66,7 @@ func BuildEvent(cal *ics.Calendar, e models.Event) {
 		event.SetProperty("CATEGORIES", "BRIDGE")
 		event.SetProperty("GEO", "51.505507;-0.075402")
 		event.SetProperty("X-APPLE-STRUCTURED-LOCATION;VALUE=URI;X-APPLE-RADIUS=70;X-TITLE=Tower Bridge", "geo:51.505507,-0.075402")
-	case "inport":
+	case category == "inport":
 		event.SetAllDayStartAt(start)
 		event.SetAllDayEndAt(start.Add(24 * time.Hour))
 		summary = fmt.Sprintf("Vessel - %s", e.VesselName)
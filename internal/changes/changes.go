@@ -0,0 +1,308 @@
+package changes
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Takenobou/thamestracker/internal/helpers/metrics"
+	"github.com/Takenobou/thamestracker/internal/models"
+)
+
+// Type classifies a change between two consecutive scrapes.
+type Type string
+
+const (
+	Added       Type = "added"
+	Removed     Type = "removed"
+	Rescheduled Type = "rescheduled"
+)
+
+// Change describes a single event difference between scrapes.
+type Change struct {
+	ID         uint64        `json:"id"`
+	Type       Type          `json:"type"`
+	Event      models.Event  `json:"event"`
+	Previous   *models.Event `json:"previous,omitempty"` // set for rescheduled events
+	DetectedAt time.Time     `json:"detected_at"`
+}
+
+// matchKey identifies "the same lift or voyage" regardless of its timestamp.
+func matchKey(e models.Event) string {
+	ref := e.VoyageNo
+	if strings.EqualFold(e.Category, "bridge") || ref == "" {
+		ref = e.Direction
+	}
+	return strings.ToLower(e.Category + "|" + strings.TrimSpace(e.VesselName) + "|" + ref)
+}
+
+// Diff compares two snapshots and classifies each difference as added, removed
+// or rescheduled. Events that disappear after their scheduled time has passed
+// simply aged off the upstream page, so they are not reported as removed.
+func Diff(prev, curr []models.Event, now time.Time) []Change {
+	prevByKey := make(map[string]models.Event, len(prev))
+	for _, e := range prev {
+		prevByKey[e.Key()] = e
+	}
+	var result []Change
+	var added []models.Event
+	seen := make(map[string]bool, len(curr))
+	for _, e := range curr {
+		key := e.Key()
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		old, ok := prevByKey[key]
+		if !ok {
+			added = append(added, e)
+			continue
+		}
+		// in-port timestamps are last-report times, not schedules
+		if !old.Timestamp.Equal(e.Timestamp) && !strings.EqualFold(e.Category, "inport") {
+			o := old
+			result = append(result, Change{Type: Rescheduled, Event: e, Previous: &o})
+		}
+	}
+	// past events have aged off the page; leaving them out here also keeps
+	// them from being paired with a new lift as a reschedule
+	var removed []models.Event
+	for key, e := range prevByKey {
+		if !seen[key] && !e.Timestamp.Before(now) {
+			removed = append(removed, e)
+		}
+	}
+
+	// Pair leftover removals and additions of the same lift/voyage as reschedules.
+	byTime := func(events []models.Event) {
+		sort.Slice(events, func(i, j int) bool {
+			if !events[i].Timestamp.Equal(events[j].Timestamp) {
+				return events[i].Timestamp.Before(events[j].Timestamp)
+			}
+			return events[i].Key() < events[j].Key()
+		})
+	}
+	byTime(added)
+	byTime(removed)
+	pending := make(map[string][]models.Event)
+	for _, e := range removed {
+		mk := matchKey(e)
+		pending[mk] = append(pending[mk], e)
+	}
+	paired := make(map[string]bool)
+	for _, e := range added {
+		mk := matchKey(e)
+		if olds := pending[mk]; len(olds) > 0 {
+			o := olds[0]
+			pending[mk] = olds[1:]
+			paired[o.Key()] = true
+			result = append(result, Change{Type: Rescheduled, Event: e, Previous: &o})
+			continue
+		}
+		result = append(result, Change{Type: Added, Event: e})
+	}
+	for _, e := range removed {
+		if paired[e.Key()] {
+			continue
+		}
+		result = append(result, Change{Type: Removed, Event: e})
+	}
+	return result
+}
+
+// Page is a slice of the change feed returned to clients.
+type Page struct {
+	Changes    []Change `json:"changes"`
+	NextCursor uint64   `json:"next_cursor"`
+	// Reset is true when the cursor was not recognised (e.g. after a restart)
+	// or older changes have been discarded, so the client may have missed some.
+	Reset bool `json:"reset,omitempty"`
+}
+
+// Feed is a bounded, in-memory log of changes addressable by cursor, which
+// also fans new changes out to live subscribers.
+type Feed struct {
+	mu       sync.Mutex
+	capacity int
+	lastID   uint64
+	items    []Change
+	subs     map[chan Change]struct{}
+	wakes    map[chan struct{}]struct{}
+	closed   bool
+}
+
+// NewFeed creates a Feed keeping at most capacity changes.
+func NewFeed(capacity int) *Feed {
+	if capacity <= 0 {
+		capacity = 1000
+	}
+	return &Feed{capacity: capacity}
+}
+
+// Append assigns IDs and timestamps to changes, stores them and returns them.
+func (f *Feed) Append(changes []Change) []Change {
+	if len(changes) == 0 {
+		return nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	now := time.Now().UTC()
+	out := make([]Change, len(changes))
+	for i, c := range changes {
+		f.lastID++
+		c.ID = f.lastID
+		if c.DetectedAt.IsZero() {
+			c.DetectedAt = now
+		}
+		out[i] = c
+	}
+	f.items = append(f.items, out...)
+	if over := len(f.items) - f.capacity; over > 0 {
+		f.items = append([]Change(nil), f.items[over:]...)
+	}
+	for ch := range f.wakes {
+		select {
+		case ch <- struct{}{}:
+		default: // a wake-up is already pending
+		}
+	}
+	for ch := range f.subs {
+		for _, c := range out {
+			select {
+			case ch <- c:
+			default:
+				// slow subscribers miss changes rather than blocking scrapes
+				metrics.ChangeSubscriberDropsTotal.Inc()
+			}
+		}
+	}
+	return out
+}
+
+// Subscribe registers a listener for changes appended from now on. The
+// channel is closed when cancel is called or the feed is closed.
+func (f *Feed) Subscribe(buffer int) (<-chan Change, func()) {
+	ch := make(chan Change, buffer)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		close(ch)
+		return ch, func() {}
+	}
+	if f.subs == nil {
+		f.subs = make(map[chan Change]struct{})
+	}
+	f.subs[ch] = struct{}{}
+	metrics.ChangeSubscribers.Inc()
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			f.mu.Lock()
+			defer f.mu.Unlock()
+			if _, ok := f.subs[ch]; ok {
+				delete(f.subs, ch)
+				close(ch)
+				metrics.ChangeSubscribers.Dec()
+			}
+		})
+	}
+}
+
+// Close ends all subscriptions; used on shutdown so streaming clients disconnect.
+func (f *Feed) Close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.closed = true
+	for ch := range f.subs {
+		delete(f.subs, ch)
+		close(ch)
+		metrics.ChangeSubscribers.Dec()
+	}
+	for ch := range f.wakes {
+		delete(f.wakes, ch)
+		close(ch)
+	}
+}
+
+// Since returns up to limit changes with IDs greater than cursor.
+func (f *Feed) Since(cursor uint64, limit int) Page {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	page := Page{Changes: []Change{}, NextCursor: cursor}
+	if cursor > f.lastID {
+		cursor = 0
+		page.Reset = true
+	}
+	if len(f.items) > 0 && cursor != 0 && cursor+1 < f.items[0].ID {
+		page.Reset = true
+	}
+	for _, c := range f.items {
+		if c.ID <= cursor {
+			continue
+		}
+		if limit > 0 && len(page.Changes) >= limit {
+			break
+		}
+		page.Changes = append(page.Changes, c)
+	}
+	if n := len(page.Changes); n > 0 {
+		page.NextCursor = page.Changes[n-1].ID
+	} else if page.Reset {
+		page.NextCursor = f.lastID
+	}
+	return page
+}
+
+// Follower reads the feed in order from the point it was created. Unlike a
+// subscription it never drops changes; only changes discarded from the feed
+// before they are read are lost, which Next reports with Page.Reset.
+type Follower struct {
+	feed   *Feed
+	cursor uint64
+	wake   chan struct{}
+	once   sync.Once
+}
+
+// Follow returns a Follower of changes appended from now on.
+func (f *Feed) Follow() *Follower {
+	fl := &Follower{feed: f, wake: make(chan struct{}, 1)}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	fl.cursor = f.lastID
+	if f.closed {
+		close(fl.wake)
+		return fl
+	}
+	if f.wakes == nil {
+		f.wakes = make(map[chan struct{}]struct{})
+	}
+	f.wakes[fl.wake] = struct{}{}
+	return fl
+}
+
+// Wake receives a value when changes have been appended since the last call
+// to Next, and is closed when the follower or the feed is closed.
+func (fl *Follower) Wake() <-chan struct{} {
+	return fl.wake
+}
+
+// Next returns up to limit changes after the last one returned.
+func (fl *Follower) Next(limit int) Page {
+	page := fl.feed.Since(fl.cursor, limit)
+	fl.cursor = page.NextCursor
+	return page
+}
+
+// Close stops the follower's wake-ups and closes Wake.
+func (fl *Follower) Close() {
+	fl.once.Do(func() {
+		f := fl.feed
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if _, ok := f.wakes[fl.wake]; ok {
+			delete(f.wakes, fl.wake)
+			close(fl.wake)
+		}
+	})
+}
@@ -0,0 +1,158 @@
+package changes
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Takenobou/thamestracker/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+var now = time.Date(2025, 4, 5, 12, 0, 0, 0, time.UTC)
+
+func lift(name, direction string, at time.Time) models.Event {
+	return models.Event{Timestamp: at, VesselName: name, Category: "bridge", Direction: direction}
+}
+
+func byType(changes []Change) map[Type][]Change {
+	out := make(map[Type][]Change)
+	for _, c := range changes {
+		out[c.Type] = append(out[c.Type], c)
+	}
+	return out
+}
+
+func TestDiff_AddedAndRemoved(t *testing.T) {
+	prev := []models.Event{lift("Dixie Queen", "Up river", now.Add(time.Hour))}
+	curr := []models.Event{lift("Balclutha", "Down river", now.Add(2*time.Hour))}
+	got := byType(Diff(prev, curr, now))
+	assert.Len(t, got[Added], 1)
+	assert.Equal(t, "Balclutha", got[Added][0].Event.VesselName)
+	assert.Len(t, got[Removed], 1)
+	assert.Equal(t, "Dixie Queen", got[Removed][0].Event.VesselName)
+}
+
+func TestDiff_PastEventsAgeOutSilently(t *testing.T) {
+	prev := []models.Event{lift("Dixie Queen", "Up river", now.Add(-time.Hour))}
+	assert.Empty(t, Diff(prev, nil, now))
+}
+
+func TestDiff_AgedOffLiftIsNotPairedWithNewOne(t *testing.T) {
+	prev := []models.Event{
+		lift("Dixie Queen", "Up river", now.Add(-2*time.Hour)),
+		lift("Dixie Queen", "Up river", now.Add(time.Hour)),
+	}
+	curr := []models.Event{
+		lift("Dixie Queen", "Up river", now.Add(time.Hour)),
+		lift("Dixie Queen", "Up river", now.Add(48*time.Hour)),
+	}
+	got := Diff(prev, curr, now)
+	assert.Len(t, got, 1)
+	assert.Equal(t, Added, got[0].Type)
+	assert.True(t, now.Add(48*time.Hour).Equal(got[0].Event.Timestamp))
+}
+
+func TestDiff_BridgeLiftRescheduled(t *testing.T) {
+	prev := []models.Event{lift("Dixie Queen", "Up river", now.Add(time.Hour))}
+	curr := []models.Event{lift("Dixie Queen", "Up river", now.Add(90*time.Minute))}
+	got := Diff(prev, curr, now)
+	assert.Len(t, got, 1)
+	assert.Equal(t, Rescheduled, got[0].Type)
+	assert.True(t, now.Add(time.Hour).Equal(got[0].Previous.Timestamp))
+}
+
+func TestDiff_VoyageRescheduled(t *testing.T) {
+	prev := []models.Event{{Timestamp: now.Add(time.Hour), VesselName: "ADELINE", Category: "forecast", VoyageNo: "A9999"}}
+	curr := []models.Event{{Timestamp: now.Add(3 * time.Hour), VesselName: "ADELINE", Category: "forecast", VoyageNo: "A9999"}}
+	got := Diff(prev, curr, now)
+	assert.Len(t, got, 1)
+	assert.Equal(t, Rescheduled, got[0].Type)
+}
+
+func TestDiff_InportReportTimeIsNotReschedule(t *testing.T) {
+	prev := []models.Event{{Timestamp: now.Add(-time.Hour), VesselName: "SILVER STURGEON", Category: "inport", VoyageNo: "S7670"}}
+	curr := []models.Event{{Timestamp: now, VesselName: "SILVER STURGEON", Category: "inport", VoyageNo: "S7670"}}
+	assert.Empty(t, Diff(prev, curr, now))
+}
+
+func TestDiff_Unchanged(t *testing.T) {
+	events := []models.Event{lift("Dixie Queen", "Up river", now.Add(time.Hour))}
+	assert.Empty(t, Diff(events, events, now))
+}
+
+func TestFeed_SinceAndCapacity(t *testing.T) {
+	f := NewFeed(3)
+	for i := 0; i < 5; i++ {
+		f.Append([]Change{{Type: Added}})
+	}
+	page := f.Since(0, 10)
+	assert.Len(t, page.Changes, 3)
+	assert.Equal(t, uint64(3), page.Changes[0].ID)
+	assert.Equal(t, uint64(5), page.NextCursor)
+
+	page = f.Since(1, 10)
+	assert.True(t, page.Reset, "cursor older than buffer should report reset")
+
+	page = f.Since(4, 10)
+	assert.False(t, page.Reset)
+	assert.Len(t, page.Changes, 1)
+
+	page = f.Since(5, 10)
+	assert.Empty(t, page.Changes)
+	assert.Equal(t, uint64(5), page.NextCursor)
+}
+
+func TestFeed_UnknownCursorResets(t *testing.T) {
+	f := NewFeed(10)
+	f.Append([]Change{{Type: Added}})
+	page := f.Since(99, 10)
+	assert.True(t, page.Reset)
+	assert.Len(t, page.Changes, 1)
+}
+
+func TestFeed_SubscribeReceivesAppended(t *testing.T) {
+	f := NewFeed(10)
+	ch, cancel := f.Subscribe(4)
+	f.Append([]Change{{Type: Added, Event: models.Event{VesselName: "A"}}})
+	got := <-ch
+	assert.Equal(t, uint64(1), got.ID)
+	assert.Equal(t, "A", got.Event.VesselName)
+
+	cancel()
+	_, ok := <-ch
+	assert.False(t, ok, "channel should close after cancel")
+	cancel() // idempotent
+}
+
+func TestFeed_FollowNeverDrops(t *testing.T) {
+	f := NewFeed(1000)
+	f.Append([]Change{{Type: Added}}) // before Follow, so not returned
+	fl := f.Follow()
+	for i := 0; i < 3; i++ {
+		f.Append(make([]Change, 100))
+	}
+	<-fl.Wake()
+	page := fl.Next(250)
+	assert.Len(t, page.Changes, 250)
+	assert.Equal(t, uint64(2), page.Changes[0].ID)
+	assert.Len(t, fl.Next(250).Changes, 50)
+	assert.Empty(t, fl.Next(250).Changes)
+
+	fl.Close()
+	_, ok := <-fl.Wake()
+	assert.False(t, ok, "Wake closes with the follower")
+	fl.Close() // idempotent
+}
+
+func TestFeed_CloseEndsSubscriptions(t *testing.T) {
+	f := NewFeed(10)
+	ch, cancel := f.Subscribe(1)
+	defer cancel()
+	f.Close()
+	_, ok := <-ch
+	assert.False(t, ok)
+
+	late, _ := f.Subscribe(1)
+	_, ok = <-late
+	assert.False(t, ok, "subscriptions after close end immediately")
+}
@@ -1,45 +1,128 @@
 package config
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"sync/atomic"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
 )
 
+// Config is the application configuration. The yaml keys are those of the
+// config file, in YAML or TOML.
 type Config struct {
 	Server struct {
-		Port int
-	}
+		Port int `yaml:"port"`
+	} `yaml:"server"`
 	URLs struct {
-		PortOfLondon string
-		TowerBridge  string
-	}
+		PortOfLondon string `yaml:"port_of_london"`
+		TowerBridge  string `yaml:"tower_bridge"`
+	} `yaml:"urls"`
 	Redis struct {
-		Address            string
-		InsecureSkipVerify bool
-	}
+		Address            string `yaml:"address"`
+		InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+	} `yaml:"redis"`
 	CircuitBreaker struct {
-		MaxFailures    int
-		CoolOffSeconds int
-	}
-	FallbackCacheSize       int
-	FallbackCacheTTLSeconds int
-	RequestsPerMin          int
-	MetricsPublic           bool
+		MaxFailures    int `yaml:"max_failures"`
+		CoolOffSeconds int `yaml:"cool_off_seconds"`
+	} `yaml:"circuit_breaker"`
+	FallbackCacheSize       int    `yaml:"fallback_cache_size"`
+	FallbackCacheTTLSeconds int    `yaml:"fallback_cache_ttl_seconds"`
+	RequestsPerMin          int    `yaml:"requests_per_min"`
+	MetricsPublic           bool   `yaml:"metrics_public"`
+	HistoryDBPath           string `yaml:"history_db_path"` // empty disables the event history store
+	ChangesBufferSize       int    `yaml:"changes_buffer_size"`
+	AdminToken              string `yaml:"admin_token"`             // empty disables the /admin API
+	SourcesFile             string `yaml:"sources_file"`            // empty disables configured event sources
+	TowerBridgeDefinition   string `yaml:"tower_bridge_definition"` // selector definition file; empty uses the built-in one
+	UserAgent               string `yaml:"user_agent"`              // sent with every upstream request
+	// Fixtures records upstream responses to Dir, or replays them from there
+	// instead of using the network; an empty Mode uses the network as normal.
+	Fixtures struct {
+		Mode      string `yaml:"mode"` // "record" or "replay"
+		Dir       string `yaml:"dir"`
+		TimeShift bool   `yaml:"time_shift"` // move replayed dates forward so the recording starts today
+	} `yaml:"fixtures"`
+	Webhooks struct {
+		File           string `yaml:"file"` // empty disables webhooks
+		MaxAttempts    int    `yaml:"max_attempts"`
+		InitialBackoff int    `yaml:"initial_backoff_ms"` // milliseconds
+	} `yaml:"webhooks"`
+	// Timeouts bound a single scrape of each source, including retries; 0 means no deadline.
+	Timeouts struct {
+		BridgeSeconds  int `yaml:"bridge_seconds"`
+		VesselsSeconds int `yaml:"vessels_seconds"`
+	} `yaml:"timeouts"`
+	ReadyTimeoutSeconds int `yaml:"ready_timeout_seconds"` // bounds the /readyz dependency checks
+	// Freshness is the maximum age of each source's data before /readyz
+	// reports it degraded; 0 disables the check.
+	Freshness struct {
+		BridgeMaxAgeSeconds  int `yaml:"bridge_max_age_seconds"`
+		VesselsMaxAgeSeconds int `yaml:"vessels_max_age_seconds"`
+	} `yaml:"freshness"`
+	// Tracing exports OpenTelemetry traces to an OTLP/HTTP endpoint or stdout;
+	// an empty Exporter disables it.
+	Tracing struct {
+		Exporter     string  `yaml:"exporter"` // "otlp" or "stdout"
+		OTLPEndpoint string  `yaml:"otlp_endpoint"`
+		SampleRatio  float64 `yaml:"sample_ratio"`
+	} `yaml:"tracing"`
+	// Log sets the minimum log level and the sampling of a scrape's per-row
+	// logs: the first RowSampleFirst are logged, then every RowSampleEvery-th
+	// (0 drops the rest).
+	Log struct {
+		Level          string `yaml:"level"` // debug, info, warn or error
+		RowSampleFirst int    `yaml:"row_sample_first"`
+		RowSampleEvery int    `yaml:"row_sample_every"`
+	} `yaml:"log"`
+	// Scheduler controls background cache refreshes; an interval of 0 disables that source.
+	Scheduler struct {
+		BridgeIntervalSeconds  int `yaml:"bridge_interval_seconds"`
+		VesselsIntervalSeconds int `yaml:"vessels_interval_seconds"`
+		JitterSeconds          int `yaml:"jitter_seconds"`
+	} `yaml:"scheduler"`
 
 	// Bridge filter config
-	BridgeFilterPercentile float64 // e.g. 0.10
-	BridgeFilterMaxCount   int     // e.g. 8
+	BridgeFilterPercentile float64 `yaml:"bridge_filter_percentile"` // e.g. 0.10
+	BridgeFilterMaxCount   int     `yaml:"bridge_filter_max_count"`  // e.g. 8
 }
 
-var AppConfig Config
+// current is the published configuration. A snapshot is never modified once
+// stored, so it can be read without locking while a reload replaces it.
+var current atomic.Pointer[Config]
 
-func NewConfig() Config {
+func init() {
+	current.Store(&Config{})
+}
+
+// Get returns the current configuration, which must be treated as read-only.
+// Code that reads several related settings should call Get once, so they all
+// come from the same snapshot.
+func Get() *Config {
+	return current.Load()
+}
+
+// Set publishes a copy of cfg as the current configuration.
+func Set(cfg Config) {
+	current.Store(&cfg)
+}
+
+// Defaults returns the configuration used when neither the config file nor
+// the environment sets a value.
+func Defaults() Config {
 	var cfg Config
-	// defaults
 	cfg.Server.Port = 8080
 	cfg.URLs.PortOfLondon = "https://pla.co.uk/pla-proxy/five-minute?url=ships/lists"
 	cfg.URLs.TowerBridge = "https://www.towerbridge.org.uk/flat/lift-times"
+	cfg.UserAgent = "thamestracker (+https://github.com/Takenobou/thamestracker)"
 	cfg.Redis.Address = "localhost:6379"
 	cfg.Redis.InsecureSkipVerify = false
 	// circuit breaker defaults
@@ -51,74 +134,357 @@ func NewConfig() Config {
 	cfg.RequestsPerMin = 60
 	// metrics endpoint protection default
 	cfg.MetricsPublic = false
+	// the history store and webhooks stay off until given a path, so nothing
+	// is written relative to whatever the working directory happens to be
+	cfg.ChangesBufferSize = 1000
+	// webhook defaults
+	cfg.Webhooks.MaxAttempts = 5
+	cfg.Webhooks.InitialBackoff = 1000
+	cfg.Fixtures.Dir = "testdata/fixtures"
+	// scrape deadline defaults
+	cfg.Timeouts.BridgeSeconds = 60
+	cfg.Timeouts.VesselsSeconds = 30
+	cfg.ReadyTimeoutSeconds = 5
+	// freshness defaults, a few missed refreshes past the cache TTLs
+	cfg.Freshness.BridgeMaxAgeSeconds = 3600
+	cfg.Freshness.VesselsMaxAgeSeconds = 3600
+	// tracing defaults
+	cfg.Tracing.OTLPEndpoint = "http://localhost:4318/v1/traces"
+	cfg.Tracing.SampleRatio = 1
+	// log defaults
+	cfg.Log.Level = "info"
+	cfg.Log.RowSampleFirst = 5
+	cfg.Log.RowSampleEvery = 20
+	// scheduler defaults, kept below the bridge (15m) and vessels (30m) cache TTLs
+	cfg.Scheduler.BridgeIntervalSeconds = 600
+	cfg.Scheduler.VesselsIntervalSeconds = 300
+	cfg.Scheduler.JitterSeconds = 30
 	// bridge filter defaults
 	cfg.BridgeFilterPercentile = 0.10
 	cfg.BridgeFilterMaxCount = 8
+	return cfg
+}
 
-	// overrides
-	if portStr := os.Getenv("PORT"); portStr != "" {
-		if p, err := strconv.Atoi(portStr); err == nil {
-			cfg.Server.Port = p
+// Load returns the defaults, overridden by the config file at path (if not
+// empty) and then by environment variables. Invalid values are reported
+// together in a *ValidationError.
+func Load(path string) (Config, error) {
+	cfg := Defaults()
+	if path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return cfg, err
 		}
 	}
-	if v := os.Getenv("PORT_OF_LONDON"); v != "" {
-		cfg.URLs.PortOfLondon = v
-	}
-	if v := os.Getenv("TOWER_BRIDGE"); v != "" {
-		cfg.URLs.TowerBridge = v
+	env := envReader{}
+	cfg.applyEnv(&env)
+	cfg.Fixtures.Mode = strings.ToLower(strings.TrimSpace(cfg.Fixtures.Mode))
+	cfg.Tracing.Exporter = strings.ToLower(strings.TrimSpace(cfg.Tracing.Exporter))
+
+	problems := append(env.problems, cfg.problems()...)
+	if len(problems) > 0 {
+		return cfg, &ValidationError{Problems: problems}
 	}
-	if v := os.Getenv("REDIS_ADDRESS"); v != "" {
-		cfg.Redis.Address = v
+	return cfg, nil
+}
+
+// LoadConfig publishes the configuration from the config file at path (if not
+// empty), the environment and defaults. The current configuration is left
+// unchanged if the new one is invalid.
+func LoadConfig(path string) error {
+	cfg, err := Load(path)
+	if err != nil {
+		return err
 	}
-	if v := os.Getenv("REDIS_INSECURE_SKIP_VERIFY"); strings.EqualFold(v, "true") {
-		cfg.Redis.InsecureSkipVerify = true
+	Set(cfg)
+	return nil
+}
+
+// loadFile overrides cfg with the settings of a YAML (.yaml, .yml) or TOML
+// (.toml) file. Unknown keys are rejected, so a misspelt setting is not
+// silently ignored.
+func (cfg *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading config file: %w", err)
 	}
-	if v := os.Getenv("CB_MAX_FAILURES"); v != "" {
-		if i, err := strconv.Atoi(v); err == nil {
-			cfg.CircuitBreaker.MaxFailures = i
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+	case ".toml":
+		// decoded generically and re-encoded, so one set of keys serves both formats
+		var m map[string]interface{}
+		if err := toml.Unmarshal(data, &m); err != nil {
+			return fmt.Errorf("parsing config file %s: %w", path, err)
 		}
-	}
-	if v := os.Getenv("CB_COOL_OFF"); v != "" {
-		if i, err := strconv.Atoi(v); err == nil {
-			cfg.CircuitBreaker.CoolOffSeconds = i
+		if data, err = yaml.Marshal(m); err != nil {
+			return fmt.Errorf("parsing config file %s: %w", path, err)
 		}
+	default:
+		return fmt.Errorf("config file %s: unsupported format, use .yaml, .yml or .toml", path)
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("parsing config file %s: %w", path, err)
 	}
+	return nil
+}
+
+// applyEnv overrides cfg with the environment variables that are set.
+func (cfg *Config) applyEnv(env *envReader) {
+	env.int("PORT", &cfg.Server.Port)
+	env.string("PORT_OF_LONDON", &cfg.URLs.PortOfLondon)
+	env.string("TOWER_BRIDGE", &cfg.URLs.TowerBridge)
+	env.path("TOWER_BRIDGE_DEFINITION", &cfg.TowerBridgeDefinition)
+	env.string("USER_AGENT", &cfg.UserAgent)
+	env.string("REDIS_ADDRESS", &cfg.Redis.Address)
+	env.bool("REDIS_INSECURE_SKIP_VERIFY", &cfg.Redis.InsecureSkipVerify)
+	env.int("CB_MAX_FAILURES", &cfg.CircuitBreaker.MaxFailures)
+	env.int("CB_COOL_OFF", &cfg.CircuitBreaker.CoolOffSeconds)
 	// overrides for fallback cache
-	if v := os.Getenv("CACHE_MAX_ENTRIES"); v != "" {
-		if i, err := strconv.Atoi(v); err == nil {
-			cfg.FallbackCacheSize = i
-		}
+	env.int("CACHE_MAX_ENTRIES", &cfg.FallbackCacheSize)
+	env.int("CACHE_TTL_SECONDS", &cfg.FallbackCacheTTLSeconds)
+	env.int("REQUESTS_PER_MIN", &cfg.RequestsPerMin)
+	env.bool("METRICS_PUBLIC", &cfg.MetricsPublic)
+	env.path("HISTORY_DB_PATH", &cfg.HistoryDBPath)
+	env.int("CHANGES_BUFFER_SIZE", &cfg.ChangesBufferSize)
+	if v, ok := os.LookupEnv("ADMIN_TOKEN"); ok {
+		cfg.AdminToken = v
 	}
-	if v := os.Getenv("CACHE_TTL_SECONDS"); v != "" {
-		if i, err := strconv.Atoi(v); err == nil {
-			cfg.FallbackCacheTTLSeconds = i
-		}
+	env.path("SOURCES_FILE", &cfg.SourcesFile)
+	// webhook overrides
+	env.path("WEBHOOKS_FILE", &cfg.Webhooks.File)
+	env.int("WEBHOOK_MAX_ATTEMPTS", &cfg.Webhooks.MaxAttempts)
+	env.int("WEBHOOK_BACKOFF_MS", &cfg.Webhooks.InitialBackoff)
+	// fixture overrides
+	env.path("FIXTURES_MODE", &cfg.Fixtures.Mode)
+	env.string("FIXTURES_DIR", &cfg.Fixtures.Dir)
+	env.bool("FIXTURES_TIME_SHIFT", &cfg.Fixtures.TimeShift)
+	// scrape deadline overrides
+	env.int("SCRAPE_TIMEOUT_BRIDGE", &cfg.Timeouts.BridgeSeconds)
+	env.int("SCRAPE_TIMEOUT_VESSELS", &cfg.Timeouts.VesselsSeconds)
+	env.int("READY_TIMEOUT", &cfg.ReadyTimeoutSeconds)
+	// freshness overrides
+	env.int("FRESHNESS_MAX_AGE_BRIDGE", &cfg.Freshness.BridgeMaxAgeSeconds)
+	env.int("FRESHNESS_MAX_AGE_VESSELS", &cfg.Freshness.VesselsMaxAgeSeconds)
+	// tracing overrides
+	env.path("TRACING_EXPORTER", &cfg.Tracing.Exporter)
+	env.string("TRACING_OTLP_ENDPOINT", &cfg.Tracing.OTLPEndpoint)
+	env.float("TRACING_SAMPLE_RATIO", &cfg.Tracing.SampleRatio)
+	// log overrides
+	env.string("LOG_LEVEL", &cfg.Log.Level)
+	env.int("LOG_ROW_SAMPLE_FIRST", &cfg.Log.RowSampleFirst)
+	env.int("LOG_ROW_SAMPLE_EVERY", &cfg.Log.RowSampleEvery)
+	// scheduler overrides
+	env.int("SCRAPE_BRIDGE_INTERVAL", &cfg.Scheduler.BridgeIntervalSeconds)
+	env.int("SCRAPE_VESSELS_INTERVAL", &cfg.Scheduler.VesselsIntervalSeconds)
+	env.int("SCRAPE_JITTER", &cfg.Scheduler.JitterSeconds)
+	// bridge filter overrides
+	env.float("BRIDGE_FILTER_PERCENTILE", &cfg.BridgeFilterPercentile)
+	env.int("BRIDGE_FILTER_MAX_COUNT", &cfg.BridgeFilterMaxCount)
+}
+
+// envReader applies environment variables to config fields, collecting the
+// values that cannot be parsed.
+type envReader struct {
+	problems []string
+}
+
+func (r *envReader) int(name string, dst *int) {
+	v := strings.TrimSpace(os.Getenv(name))
+	if v == "" {
+		return
 	}
-	if v := os.Getenv("REQUESTS_PER_MIN"); v != "" {
-		if i, err := strconv.Atoi(v); err == nil {
-			cfg.RequestsPerMin = i
-		}
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		r.problems = append(r.problems, fmt.Sprintf("%s: %q is not a whole number", name, v))
+		return
 	}
-	// optional metrics public flag
-	if mp := os.Getenv("METRICS_PUBLIC"); mp != "" {
-		cfg.MetricsPublic = strings.EqualFold(mp, "true")
+	*dst = i
+}
+
+func (r *envReader) float(name string, dst *float64) {
+	v := strings.TrimSpace(os.Getenv(name))
+	if v == "" {
+		return
 	}
-	// bridge filter overrides
-	if v := os.Getenv("BRIDGE_FILTER_PERCENTILE"); v != "" {
-		if f, err := strconv.ParseFloat(v, 64); err == nil {
-			cfg.BridgeFilterPercentile = f
-		}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		r.problems = append(r.problems, fmt.Sprintf("%s: %q is not a number", name, v))
+		return
+	}
+	*dst = f
+}
+
+func (r *envReader) bool(name string, dst *bool) {
+	v := strings.TrimSpace(os.Getenv(name))
+	if v == "" {
+		return
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		r.problems = append(r.problems, fmt.Sprintf("%s: %q is not true or false", name, v))
+		return
+	}
+	*dst = b
+}
+
+// string sets dst to the variable's value, unless it is empty.
+func (r *envReader) string(name string, dst *string) {
+	if v := strings.TrimSpace(os.Getenv(name)); v != "" {
+		*dst = v
 	}
-	if v := os.Getenv("BRIDGE_FILTER_MAX_COUNT"); v != "" {
-		if i, err := strconv.Atoi(v); err == nil {
-			cfg.BridgeFilterMaxCount = i
+}
+
+// path sets dst to the variable's value whenever it is set, so an empty value
+// can disable a feature that is on by default or in the config file.
+func (r *envReader) path(name string, dst *string) {
+	if v, ok := os.LookupEnv(name); ok {
+		*dst = strings.TrimSpace(v)
+	}
+}
+
+// ValidationError lists every problem found in a configuration.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid configuration:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// Validate reports every invalid setting of cfg in a *ValidationError.
+func (cfg Config) Validate() error {
+	if problems := cfg.problems(); len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+func (cfg Config) problems() []string {
+	var p []string
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			p = append(p, fmt.Sprintf(format, args...))
 		}
 	}
+	checkURL := func(name, v string) {
+		u, err := url.Parse(v)
+		check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "",
+			"%s: %q is not a valid http(s) URL", name, v)
+	}
+	positive := func(name string, v int) { check(v > 0, "%s: must be greater than 0, got %d", name, v) }
+	nonNegative := func(name string, v int) { check(v >= 0, "%s: must not be negative, got %d", name, v) }
 
+	check(cfg.Server.Port > 0 && cfg.Server.Port <= 65535, "PORT: must be between 1 and 65535, got %d", cfg.Server.Port)
+	checkURL("PORT_OF_LONDON", cfg.URLs.PortOfLondon)
+	checkURL("TOWER_BRIDGE", cfg.URLs.TowerBridge)
+	check(cfg.Redis.Address != "", "REDIS_ADDRESS: must not be empty")
+	positive("CB_MAX_FAILURES", cfg.CircuitBreaker.MaxFailures)
+	positive("CB_COOL_OFF", cfg.CircuitBreaker.CoolOffSeconds)
+	positive("CACHE_MAX_ENTRIES", cfg.FallbackCacheSize)
+	positive("CACHE_TTL_SECONDS", cfg.FallbackCacheTTLSeconds)
+	positive("REQUESTS_PER_MIN", cfg.RequestsPerMin)
+	positive("CHANGES_BUFFER_SIZE", cfg.ChangesBufferSize)
+	check(cfg.Fixtures.Mode == "" || cfg.Fixtures.Mode == "record" || cfg.Fixtures.Mode == "replay",
+		"FIXTURES_MODE: must be record or replay, got %q", cfg.Fixtures.Mode)
+	positive("WEBHOOK_MAX_ATTEMPTS", cfg.Webhooks.MaxAttempts)
+	nonNegative("WEBHOOK_BACKOFF_MS", cfg.Webhooks.InitialBackoff)
+	nonNegative("SCRAPE_TIMEOUT_BRIDGE", cfg.Timeouts.BridgeSeconds)
+	nonNegative("SCRAPE_TIMEOUT_VESSELS", cfg.Timeouts.VesselsSeconds)
+	positive("READY_TIMEOUT", cfg.ReadyTimeoutSeconds)
+	nonNegative("FRESHNESS_MAX_AGE_BRIDGE", cfg.Freshness.BridgeMaxAgeSeconds)
+	nonNegative("FRESHNESS_MAX_AGE_VESSELS", cfg.Freshness.VesselsMaxAgeSeconds)
+	switch cfg.Tracing.Exporter {
+	case "", "stdout":
+	case "otlp":
+		checkURL("TRACING_OTLP_ENDPOINT", cfg.Tracing.OTLPEndpoint)
+	default:
+		check(false, "TRACING_EXPORTER: must be otlp or stdout, got %q", cfg.Tracing.Exporter)
+	}
+	check(cfg.Tracing.SampleRatio >= 0 && cfg.Tracing.SampleRatio <= 1,
+		"TRACING_SAMPLE_RATIO: must be between 0 and 1, got %g", cfg.Tracing.SampleRatio)
+	switch strings.ToLower(cfg.Log.Level) {
+	case "debug", "info", "warn", "error":
+	default:
+		check(false, "LOG_LEVEL: must be debug, info, warn or error, got %q", cfg.Log.Level)
+	}
+	nonNegative("LOG_ROW_SAMPLE_FIRST", cfg.Log.RowSampleFirst)
+	nonNegative("LOG_ROW_SAMPLE_EVERY", cfg.Log.RowSampleEvery)
+	nonNegative("SCRAPE_BRIDGE_INTERVAL", cfg.Scheduler.BridgeIntervalSeconds)
+	nonNegative("SCRAPE_VESSELS_INTERVAL", cfg.Scheduler.VesselsIntervalSeconds)
+	nonNegative("SCRAPE_JITTER", cfg.Scheduler.JitterSeconds)
+	check(cfg.BridgeFilterPercentile > 0 && cfg.BridgeFilterPercentile <= 1,
+		"BRIDGE_FILTER_PERCENTILE: must be greater than 0 and at most 1, got %g", cfg.BridgeFilterPercentile)
+	positive("BRIDGE_FILTER_MAX_COUNT", cfg.BridgeFilterMaxCount)
+	return p
+}
+
+// redacted replaces secret values that are set.
+const redacted = "REDACTED"
+
+// Redacted returns a copy of cfg that is safe to log: the admin token and any
+// passwords in URLs are replaced.
+func (cfg Config) Redacted() Config {
+	if cfg.AdminToken != "" {
+		cfg.AdminToken = redacted
+	}
+	cfg.URLs.PortOfLondon = redactURL(cfg.URLs.PortOfLondon)
+	cfg.URLs.TowerBridge = redactURL(cfg.URLs.TowerBridge)
+	cfg.Redis.Address = redactURL(cfg.Redis.Address)
+	cfg.Tracing.OTLPEndpoint = redactURL(cfg.Tracing.OTLPEndpoint)
 	return cfg
 }
 
-// LoadConfig populates the global AppConfig from environment and defaults.
-func LoadConfig() {
-	AppConfig = NewConfig()
+func redactURL(v string) string {
+	u, err := url.Parse(v)
+	if err != nil || u.User == nil {
+		return v
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), redacted)
+	}
+	return u.String()
+}
+
+// YAML returns the redacted configuration in the config file format.
+func (cfg Config) YAML() ([]byte, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(cfg.Redacted()); err != nil {
+		return nil, err
+	}
+	err := enc.Close()
+	return buf.Bytes(), err
+}
+
+// Fields returns the redacted configuration keyed as in the config file, for
+// structured logging.
+func (cfg Config) Fields() (map[string]interface{}, error) {
+	data, err := cfg.YAML()
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]interface{}
+	err = yaml.Unmarshal(data, &m)
+	return m, err
+}
+
+// Print writes the effective configuration from path, redacted, to w in the
+// config file format. It is written even when invalid, in which case the
+// returned *ValidationError lists the problems.
+func Print(w io.Writer, path string) error {
+	cfg, err := Load(path)
+	var invalid *ValidationError
+	if err != nil && !errors.As(err, &invalid) {
+		return err
+	}
+	out, yamlErr := cfg.YAML()
+	if yamlErr != nil {
+		return yamlErr
+	}
+	if _, werr := w.Write(out); werr != nil {
+		return werr
+	}
+	return err
 }
@@ -0,0 +1,409 @@
+// Package fakeupstream serves stand-ins for the Tower Bridge lift times page
+// and the PLA ship lists API, with generated schedules and injectable faults,
+// so the scrapers, retries, circuit breaker and caching can be exercised
+// without touching the real sites.
+package fakeupstream
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Takenobou/thamestracker/internal/helpers/logger"
+	"github.com/Takenobou/thamestracker/internal/helpers/utils"
+)
+
+// Paths served by the fake upstreams. Point TOWER_BRIDGE at BridgePath and
+// PORT_OF_LONDON at VesselsPath+"?url=ships/lists".
+const (
+	BridgePath  = "/flat/lift-times"
+	VesselsPath = "/pla-proxy/five-minute"
+	FaultsPath  = "/_faults"
+)
+
+// Endpoint names, as used by Faults.Only and Requests.
+const (
+	Bridge  = "bridge"
+	Vessels = "vessels"
+)
+
+// Options control the generated schedules.
+type Options struct {
+	Seed        int64 // 0 seeds from the current time
+	Days        int   // days of bridge lifts, starting today; default 7
+	LiftsPerDay int   // most lifts on one day; default 4
+	PageSize    int   // lifts per page; default 10
+	Vessels     int   // vessels per PLA list; default 20
+	Now         func() time.Time
+}
+
+// Faults are injected into upstream responses. The zero value injects none.
+type Faults struct {
+	Status        int     `json:"status,omitempty"`    // answer every request with this status
+	FailRate      float64 `json:"fail_rate,omitempty"` // fraction of requests answered with 500
+	DelayMS       int     `json:"delay_ms,omitempty"`  // wait before answering
+	MalformedJSON bool    `json:"malformed_json,omitempty"`
+	ChangedMarkup bool    `json:"changed_markup,omitempty"`
+	Only          string  `json:"only,omitempty"` // "bridge" or "vessels"; empty applies to both
+}
+
+func (f Faults) applies(endpoint string) bool {
+	return f.Only == "" || f.Only == endpoint
+}
+
+// Server generates one schedule at creation and serves it until discarded.
+type Server struct {
+	opts  Options
+	lifts []lift
+	ships shipLists
+
+	mu     sync.Mutex
+	faults Faults
+	rnd    *rand.Rand
+
+	bridgeHits  atomic.Int64
+	vesselsHits atomic.Int64
+}
+
+type lift struct {
+	At        time.Time
+	Vessel    string
+	Direction string
+}
+
+type ship struct {
+	LocationFrom string `json:"location_from,omitempty"`
+	LocationTo   string `json:"location_to,omitempty"`
+	LocationName string `json:"location_name,omitempty"`
+	VesselName   string `json:"vessel_name"`
+	Visit        string `json:"visit"`
+	Nationality  string `json:"nationality,omitempty"`
+	LastRepDT    string `json:"last_rep_dt,omitempty"`
+	FirstRepDT   string `json:"first_rep_dt,omitempty"`
+	ETADate      string `json:"etad_dt,omitempty"`
+}
+
+type shipLists struct {
+	InPort     []ship `json:"inport"`
+	Arrivals   []ship `json:"arrivals"`
+	Departures []ship `json:"departures"`
+	Forecast   []ship `json:"forecast"`
+}
+
+// New generates a schedule according to opts.
+func New(opts Options) *Server {
+	if opts.Seed == 0 {
+		opts.Seed = time.Now().UnixNano()
+	}
+	if opts.Days <= 0 {
+		opts.Days = 7
+	}
+	if opts.LiftsPerDay <= 0 {
+		opts.LiftsPerDay = 4
+	}
+	if opts.PageSize <= 0 {
+		opts.PageSize = 10
+	}
+	if opts.Vessels <= 0 {
+		opts.Vessels = 20
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	rnd := rand.New(rand.NewSource(opts.Seed))
+	s := &Server{opts: opts, rnd: rnd}
+	s.lifts = generateLifts(rnd, opts)
+	s.ships = generateShips(rnd, opts)
+	return s
+}
+
+// Handler serves the fake upstreams and the fault control endpoint: GET
+// FaultsPath returns the current faults, PUT or POST replaces them with the
+// JSON body and DELETE clears them.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(BridgePath, s.inject(Bridge, s.serveBridge))
+	mux.HandleFunc(VesselsPath, s.inject(Vessels, s.serveVessels))
+	mux.HandleFunc(FaultsPath, s.serveFaults)
+	return mux
+}
+
+// SetFaults replaces the injected faults.
+func (s *Server) SetFaults(f Faults) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = f
+}
+
+// Faults returns the injected faults.
+func (s *Server) Faults() Faults {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.faults
+}
+
+// Requests returns how many requests endpoint ("bridge" or "vessels") has
+// received, including those answered with a fault.
+func (s *Server) Requests(endpoint string) int64 {
+	switch endpoint {
+	case Bridge:
+		return s.bridgeHits.Load()
+	case Vessels:
+		return s.vesselsHits.Load()
+	}
+	return 0
+}
+
+// Lifts returns how many bridge lifts are scheduled.
+func (s *Server) Lifts() int { return len(s.lifts) }
+
+// inject counts requests to endpoint and applies the faults that do not
+// depend on the response format.
+func (s *Server) inject(endpoint string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if endpoint == Bridge {
+			s.bridgeHits.Add(1)
+		} else {
+			s.vesselsHits.Add(1)
+		}
+		f := s.Faults()
+		if !f.applies(endpoint) {
+			next(w, r)
+			return
+		}
+		if f.DelayMS > 0 {
+			select {
+			case <-time.After(time.Duration(f.DelayMS) * time.Millisecond):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		if f.Status != 0 {
+			http.Error(w, http.StatusText(f.Status), f.Status)
+			return
+		}
+		if f.FailRate > 0 && s.roll() < f.FailRate {
+			http.Error(w, "injected failure", http.StatusInternalServerError)
+			return
+		}
+		next(w, r)
+	}
+}
+
+func (s *Server) roll() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rnd.Float64()
+}
+
+func (s *Server) serveFaults(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut, http.MethodPost:
+		var f Faults
+		if err := json.NewDecoder(r.Body).Decode(&f); err != nil {
+			http.Error(w, "invalid faults: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if f.Only != "" && f.Only != Bridge && f.Only != Vessels {
+			http.Error(w, `invalid faults: only must be "bridge" or "vessels"`, http.StatusBadRequest)
+			return
+		}
+		s.SetFaults(f)
+		logger.Logger.Infof("Faults set: %+v", f)
+	case http.MethodDelete:
+		s.SetFaults(Faults{})
+		logger.Logger.Infof("Faults cleared")
+	default:
+		w.Header().Set("Allow", "GET, PUT, POST, DELETE")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(s.Faults())
+}
+
+type liftRow struct {
+	Day, Date, DateTime, Time, TimeTime, Vessel, Direction string
+}
+
+type liftPage struct {
+	Rows  []liftRow
+	Pages []int
+	Page  int
+}
+
+var liftsPage = template.Must(template.New("lifts").Funcs(template.FuncMap{
+	"inc": func(i int) int { return i + 1 },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head><meta charset="utf-8"><title>Bridge lift times | Tower Bridge</title></head>
+<body>
+<main>
+<h1>Bridge lift times</h1>
+<table class="views-table">
+<thead><tr><th>Day</th><th>Date</th><th>Time</th><th>Vessel</th><th>Direction</th></tr></thead>
+<tbody>
+{{- range .Rows}}
+<tr><td>{{.Day}}</td><td><time datetime="{{.DateTime}}">{{.Date}}</time></td><td><time datetime="{{.TimeTime}}">{{.Time}}</time></td><td>{{.Vessel}}</td><td>{{.Direction}}</td></tr>
+{{- end}}
+</tbody>
+</table>
+<nav class="pager" role="navigation">
+<ul class="pager__items">
+{{- range .Pages}}
+{{- if eq . $.Page}}
+<li class="pager__item is-active"><a href="?page={{.}}" title="Current page">{{inc .}}</a></li>
+{{- else}}
+<li class="pager__item"><a href="?page={{.}}" title="Go to page {{inc .}}">{{inc .}}</a></li>
+{{- end}}
+{{- end}}
+</ul>
+</nav>
+</main>
+</body>
+</html>
+`))
+
+// redesignedPage renders the same lifts in markup the built-in definition
+// does not know, as after a redesign of the real site.
+var redesignedPage = template.Must(template.New("redesigned").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head><meta charset="utf-8"><title>Lift times | Tower Bridge</title></head>
+<body>
+<section class="lift-times">
+{{- range .Rows}}
+<article class="lift" data-time="{{.TimeTime}}"><h3>{{.Vessel}}</h3><p>{{.Direction}}, {{.Date}} {{.Time}}</p></article>
+{{- end}}
+</section>
+</body>
+</html>
+`))
+
+func (s *Server) serveBridge(w http.ResponseWriter, r *http.Request) {
+	pages := (len(s.lifts) + s.opts.PageSize - 1) / s.opts.PageSize
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 0 || (page >= pages && page != 0) {
+		http.NotFound(w, r)
+		return
+	}
+	data := liftPage{Page: page}
+	for i := 0; i < pages; i++ {
+		data.Pages = append(data.Pages, i)
+	}
+	end := min((page+1)*s.opts.PageSize, len(s.lifts))
+	for _, l := range s.lifts[page*s.opts.PageSize : end] {
+		local := l.At.In(utils.LondonLocation)
+		data.Rows = append(data.Rows, liftRow{
+			Day:       local.Format("Mon"),
+			Date:      local.Format("02 Jan 2006"),
+			DateTime:  time.Date(local.Year(), local.Month(), local.Day(), 12, 0, 0, 0, time.UTC).Format(time.RFC3339),
+			Time:      local.Format("15:04"),
+			TimeTime:  l.At.UTC().Format(time.RFC3339),
+			Vessel:    l.Vessel,
+			Direction: l.Direction,
+		})
+	}
+	tmpl := liftsPage
+	if f := s.Faults(); f.applies(Bridge) && f.ChangedMarkup {
+		tmpl = redesignedPage
+	}
+	w.Header().Set("Content-Type", "text/html; charset=UTF-8")
+	if err := tmpl.Execute(w, data); err != nil {
+		logger.Logger.Errorf("Failed to render lift times: %v", err)
+	}
+}
+
+func (s *Server) serveVessels(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("url") != "ships/lists" {
+		http.NotFound(w, r)
+		return
+	}
+	body, err := json.Marshal(s.ships)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if f := s.Faults(); f.applies(Vessels) && f.MalformedJSON {
+		body = body[:len(body)/2]
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(body)
+}
+
+var (
+	liftVessels = []string{
+		"Paddle Steamer Dixie Queen", "Sailing Barge Centaur", "Sailing Barge Thalatta",
+		"HMS Dauntless", "MV Balmoral", "PS Waverley", "Sailing Barge Will",
+		"Cutty Sark Barge", "Thames Sailing Barge Xylonite", "SB Pudge",
+		"Havengore", "Silver Sturgeon", "Golden Hinde II", "Stavros S Niarchos",
+	}
+	shipNames = []string{
+		"SILVER STURGEON", "SAN NICOLAS MAERSK", "FRISIAN SPRING", "ADELINE",
+		"GRANDE LAGOS", "CMA CGM BERLIOZ", "THAMES TITAN", "ARCO DEE",
+		"BRITANNIA SEAWAYS", "CELINE", "MSC CAPUCINE", "EVER GOVERN",
+		"STENA FORERUNNER", "ELBEBORG", "KEW PRINCESS", "TAMESIS",
+		"NORDIC STAR", "BOMAR RAINBOW", "HAV DOLPHIN", "SEA LIGHT",
+	}
+	berths = []string{
+		"WOODS QUAY", "TILBURY DOCK BERTH 40", "LONDON GATEWAY1", "LONDON GATEWAY3",
+		"FORDS JETTY", "PURFLEET TERMINAL", "THAMESPORT", "DAGENHAM DOCK",
+	}
+	ports         = []string{"NLRTM", "BEANR", "DEHAM", "MAPTM", "ESALG", "SESOE", "NLVLI", "FRLEH", "DKAAR"}
+	nationalities = []string{"GB", "NL", "DK", "MT", "LR", "PA", "IT", "DE", "CY"}
+)
+
+// generateLifts schedules up to LiftsPerDay lifts a day, from today, between
+// 06:00 and 22:00 London time.
+func generateLifts(rnd *rand.Rand, opts Options) []lift {
+	now := opts.Now().In(utils.LondonLocation)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, utils.LondonLocation)
+	var lifts []lift
+	for d := 0; d < opts.Days; d++ {
+		day := today.AddDate(0, 0, d)
+		for n := 1 + rnd.Intn(opts.LiftsPerDay); n > 0; n-- {
+			vessel := liftVessels[rnd.Intn(len(liftVessels))]
+			up := day.Add(time.Duration(6*60+rnd.Intn(12*60)/5*5) * time.Minute)
+			lifts = append(lifts, lift{At: up, Vessel: vessel, Direction: "Up river"})
+			// most vessels head back down river the same day
+			if n > 1 && rnd.Intn(3) > 0 {
+				lifts = append(lifts, lift{At: up.Add(time.Duration(60+rnd.Intn(180)/5*5) * time.Minute), Vessel: vessel, Direction: "Down river"})
+				n--
+			}
+		}
+	}
+	sort.SliceStable(lifts, func(i, j int) bool { return lifts[i].At.Before(lifts[j].At) })
+	return lifts
+}
+
+// generateShips fills each PLA list with Vessels entries: reports from the
+// last two days for ships in port, arriving and departing, and estimated
+// arrivals over the next three days for the forecast.
+func generateShips(rnd *rand.Rand, opts Options) shipLists {
+	now := opts.Now().In(utils.LondonLocation)
+	pick := func(list []string) string { return list[rnd.Intn(len(list))] }
+	visit := func(name string) string { return fmt.Sprintf("%c%04d", name[0], rnd.Intn(10000)) }
+	past := func() string {
+		return now.Add(-time.Duration(rnd.Intn(48*60)) * time.Minute).Format(utils.SrcLayout)
+	}
+	var lists shipLists
+	for i := 0; i < opts.Vessels; i++ {
+		name := pick(shipNames)
+		lists.InPort = append(lists.InPort, ship{LocationName: pick(berths), VesselName: name, Visit: visit(name), Nationality: pick(nationalities), LastRepDT: past()})
+		name = pick(shipNames)
+		lists.Arrivals = append(lists.Arrivals, ship{LocationFrom: pick(ports), LocationTo: pick(berths), VesselName: name, Visit: visit(name), Nationality: pick(nationalities), LastRepDT: past()})
+		name = pick(shipNames)
+		lists.Departures = append(lists.Departures, ship{LocationFrom: pick(berths), LocationTo: pick(ports), VesselName: name, Visit: visit(name), Nationality: pick(nationalities), FirstRepDT: past()})
+		name = pick(shipNames)
+		eta := now.Add(time.Duration(30+rnd.Intn(72*60)) * time.Minute).Truncate(15 * time.Minute)
+		lists.Forecast = append(lists.Forecast, ship{LocationFrom: pick(ports), LocationTo: pick(berths), VesselName: name, Visit: visit(name), Nationality: pick(nationalities), ETADate: eta.Format(utils.SrcLayout)})
+	}
+	return lists
+}
@@ -0,0 +1,122 @@
+package fakeupstream_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Takenobou/thamestracker/internal/config"
+	"github.com/Takenobou/thamestracker/internal/fakeupstream"
+	"github.com/Takenobou/thamestracker/internal/helpers/logger"
+	"github.com/Takenobou/thamestracker/internal/scraper/bridge"
+	"github.com/Takenobou/thamestracker/internal/scraper/vessels"
+	"github.com/Takenobou/thamestracker/internal/validation"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	logger.InitLogger()
+	os.Exit(m.Run())
+}
+
+// start serves a fake with a fixed seed and points the scrapers at it.
+func start(t *testing.T) (*fakeupstream.Server, *httptest.Server) {
+	t.Helper()
+	fake := fakeupstream.New(fakeupstream.Options{Seed: 1, PageSize: 5, Vessels: 3})
+	server := httptest.NewServer(fake.Handler())
+	t.Cleanup(server.Close)
+	old := *config.Get()
+	cfg := old
+	cfg.URLs.TowerBridge = server.URL + fakeupstream.BridgePath
+	cfg.URLs.PortOfLondon = server.URL + fakeupstream.VesselsPath + "?url=ships/lists"
+	config.Set(cfg)
+	t.Cleanup(func() { config.Set(old) })
+	return fake, server
+}
+
+func TestScrapersReadFakeUpstreams(t *testing.T) {
+	fake, server := start(t)
+	tracker := validation.NewTracker(10)
+
+	lifts, err := bridge.BridgeScraperImpl{URL: server.URL + fakeupstream.BridgePath, Tracker: tracker}.ScrapeBridgeLifts(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, lifts, fake.Lifts(), "every page is followed")
+	assert.Greater(t, fake.Requests(fakeupstream.Bridge), int64(1))
+	for i := 1; i < len(lifts); i++ {
+		assert.False(t, lifts[i].Timestamp.Before(lifts[i-1].Timestamp), "lifts are in time order")
+	}
+
+	ships, err := vessels.VesselScraperImpl{Tracker: tracker}.ScrapeVessels(context.Background(), "all")
+	assert.NoError(t, err)
+	assert.Len(t, ships, 12)
+	assert.Equal(t, int64(1), fake.Requests(fakeupstream.Vessels))
+
+	for _, st := range tracker.Status() {
+		assert.False(t, st.Degraded, "%s: %v", st.Source, st.Reasons)
+	}
+}
+
+func TestSameSeedSameSchedule(t *testing.T) {
+	now := func() time.Time { return time.Date(2025, 4, 5, 9, 0, 0, 0, time.UTC) }
+	get := func() string {
+		server := httptest.NewServer(fakeupstream.New(fakeupstream.Options{Seed: 42, Now: now}).Handler())
+		defer server.Close()
+		resp, err := http.Get(server.URL + fakeupstream.VesselsPath + "?url=ships/lists")
+		assert.NoError(t, err)
+		defer resp.Body.Close()
+		var buf bytes.Buffer
+		_, _ = buf.ReadFrom(resp.Body)
+		return buf.String()
+	}
+	assert.Equal(t, get(), get())
+}
+
+func TestFaults(t *testing.T) {
+	fake, server := start(t)
+	ctx := context.Background()
+
+	fake.SetFaults(fakeupstream.Faults{Status: http.StatusServiceUnavailable, Only: fakeupstream.Vessels})
+	_, err := vessels.ScrapeVessels(ctx, "inport")
+	assert.Error(t, err)
+	assert.Equal(t, int64(3), fake.Requests(fakeupstream.Vessels), "the scraper retries 5xx responses")
+	_, err = bridge.ScrapeBridgeLifts(ctx)
+	assert.NoError(t, err, "faults limited to vessels leave the bridge alone")
+
+	fake.SetFaults(fakeupstream.Faults{MalformedJSON: true})
+	_, err = vessels.ScrapeVessels(ctx, "inport")
+	assert.Error(t, err)
+
+	fake.SetFaults(fakeupstream.Faults{ChangedMarkup: true})
+	tracker := validation.NewTracker(10)
+	lifts, err := bridge.BridgeScraperImpl{URL: server.URL + fakeupstream.BridgePath, Tracker: tracker}.ScrapeBridgeLifts(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, lifts)
+	assert.True(t, tracker.Status()[0].Degraded, "changed markup is flagged")
+
+	fake.SetFaults(fakeupstream.Faults{DelayMS: 2000})
+	timeout, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	_, err = vessels.ScrapeVessels(timeout, "inport")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// faults can also be changed over HTTP
+	req, _ := http.NewRequest(http.MethodDelete, server.URL+fakeupstream.FaultsPath, nil)
+	resp, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, fakeupstream.Faults{}, fake.Faults())
+	resp, err = http.Post(server.URL+fakeupstream.FaultsPath, "application/json", bytes.NewBufferString(`{"fail_rate":1,"only":"bridge"}`))
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, fakeupstream.Faults{FailRate: 1, Only: fakeupstream.Bridge}, fake.Faults())
+	_, err = bridge.ScrapeBridgeLifts(ctx)
+	assert.Error(t, err)
+	resp, err = http.Post(server.URL+fakeupstream.FaultsPath, "application/json", bytes.NewBufferString(`{"only":"ferries"}`))
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
@@ -0,0 +1,310 @@
+// Package fixtures records raw upstream responses to a directory and replays
+// them in place of the network, for offline development, demos and
+// deterministic tests.
+package fixtures
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Takenobou/thamestracker/internal/helpers/logger"
+	"github.com/Takenobou/thamestracker/internal/helpers/utils"
+)
+
+// Modes accepted by NewTransport.
+const (
+	ModeRecord = "record"
+	ModeReplay = "replay"
+)
+
+// indexFile maps request URLs to the files holding their responses.
+const indexFile = "index.json"
+
+// maxBodyBytes bounds a recorded response.
+const maxBodyBytes = 10 << 20
+
+// ErrNoFixture is returned when replaying a request that was never recorded.
+var ErrNoFixture = errors.New("no fixture recorded")
+
+// Entry describes one recorded response.
+type Entry struct {
+	URL         string    `json:"url"`
+	File        string    `json:"file"`
+	ContentType string    `json:"content_type,omitempty"`
+	RecordedAt  time.Time `json:"recorded_at"`
+}
+
+type index struct {
+	Responses []Entry `json:"responses"`
+}
+
+// NewTransport returns the transport for mode: base when mode is empty, a
+// Recorder wrapping base for "record" and a Replayer for "replay".
+func NewTransport(mode, dir string, timeShift bool, base http.RoundTripper) (http.RoundTripper, error) {
+	switch mode {
+	case "":
+		return base, nil
+	case ModeRecord:
+		return NewRecorder(dir, base)
+	case ModeReplay:
+		return NewReplayer(dir, timeShift)
+	default:
+		return nil, fmt.Errorf("unknown fixtures mode %q: want %q or %q", mode, ModeRecord, ModeReplay)
+	}
+}
+
+// Recorder is an http.RoundTripper that saves every successful GET response
+// from Base to Dir, adding to any fixtures already there.
+type Recorder struct {
+	Dir  string
+	Base http.RoundTripper
+
+	mu      sync.Mutex
+	entries map[string]Entry
+}
+
+// NewRecorder creates dir if needed and loads its existing index.
+func NewRecorder(dir string, base http.RoundTripper) (*Recorder, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create fixtures dir: %w", err)
+	}
+	entries, err := readIndex(dir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &Recorder{Dir: dir, Base: base, entries: entries}, nil
+}
+
+// RoundTrip sends req through Base and records the response. A response that
+// cannot be saved is still returned to the caller.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := r.Base.RoundTrip(req)
+	if err != nil || req.Method != http.MethodGet || resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp, err
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBodyBytes))
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if err := r.save(req.URL.String(), resp.Header.Get("Content-Type"), body); err != nil {
+		logger.FromContext(req.Context()).Warnf("Failed to record fixture for %s: %v", req.URL, err)
+	}
+	return resp, nil
+}
+
+func (r *Recorder) save(url, contentType string, body []byte) error {
+	e := Entry{
+		URL:         url,
+		File:        fileName(url, contentType),
+		ContentType: contentType,
+		RecordedAt:  time.Now().UTC(),
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := writeFile(filepath.Join(r.Dir, e.File), body); err != nil {
+		return err
+	}
+	r.entries[url] = e
+	list := make([]Entry, 0, len(r.entries))
+	for _, e := range r.entries {
+		list = append(list, e)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].URL < list[j].URL })
+	data, err := json.MarshalIndent(index{Responses: list}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFile(filepath.Join(r.Dir, indexFile), append(data, '\n')); err != nil {
+		return err
+	}
+	logger.Logger.Infof("Recorded fixture, url: %s, file: %s", url, e.File)
+	return nil
+}
+
+// Replayer is an http.RoundTripper that answers GET requests from the
+// fixtures in a directory and never touches the network. With TimeShift set,
+// every date followed by a time of day in a replayed body is moved forward by
+// whole days, so the fixture recorded earliest starts today.
+type Replayer struct {
+	TimeShift bool
+	// Now returns the current time; time.Now if nil.
+	Now func() time.Time
+
+	dir      string
+	entries  map[string]Entry
+	recorded time.Time // earliest RecordedAt
+}
+
+// NewReplayer loads the fixtures index in dir.
+func NewReplayer(dir string, timeShift bool) (*Replayer, error) {
+	entries, err := readIndex(dir)
+	if err != nil {
+		return nil, err
+	}
+	r := &Replayer{TimeShift: timeShift, dir: dir, entries: entries}
+	for _, e := range entries {
+		if r.recorded.IsZero() || e.RecordedAt.Before(r.recorded) {
+			r.recorded = e.RecordedAt
+		}
+	}
+	return r, nil
+}
+
+// RoundTrip returns the recorded response for req's URL, or ErrNoFixture.
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := req.Context().Err(); err != nil {
+		return nil, err
+	}
+	e, ok := r.entries[req.URL.String()]
+	if !ok || (req.Method != http.MethodGet && req.Method != http.MethodHead) {
+		return nil, fmt.Errorf("%w for %s %s", ErrNoFixture, req.Method, req.URL)
+	}
+	body, err := os.ReadFile(filepath.Join(r.dir, e.File))
+	if err != nil {
+		return nil, fmt.Errorf("read fixture %s: %w", e.File, err)
+	}
+	if r.TimeShift {
+		body = shiftDates(body, r.shiftDays())
+	}
+	header := make(http.Header)
+	if e.ContentType != "" {
+		header.Set("Content-Type", e.ContentType)
+	}
+	if req.Method == http.MethodHead {
+		body = nil
+	}
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+// shiftDays is the number of London calendar days from the earliest
+// recording to now.
+func (r *Replayer) shiftDays() int {
+	if r.recorded.IsZero() {
+		return 0
+	}
+	now := time.Now
+	if r.Now != nil {
+		now = r.Now
+	}
+	day := func(t time.Time) time.Time {
+		y, m, d := t.In(utils.LondonLocation).Date()
+		return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	}
+	return int(day(now()).Sub(day(r.recorded)).Hours() / 24)
+}
+
+// datePattern matches a date followed by a time of day, as in RFC 3339
+// timestamps and the PLA's "2006-01-02 15:04:05.000".
+var datePattern = regexp.MustCompile(`\b(\d{4}-\d{2}-\d{2})([T ]\d{2}:\d{2})`)
+
+// epochPattern matches an HTML datetime attribute holding Unix seconds, as on
+// the Tower Bridge lift times page.
+var epochPattern = regexp.MustCompile(`(datetime=["'])(\d{9,11})(["'])`)
+
+// shiftDates moves every date matched by datePattern or epochPattern in body
+// by days, leaving times of day (in London, for epoch values) and formatting
+// unchanged.
+func shiftDates(body []byte, days int) []byte {
+	if days == 0 {
+		return body
+	}
+	body = datePattern.ReplaceAllFunc(body, func(m []byte) []byte {
+		d, err := time.Parse("2006-01-02", string(m[:10]))
+		if err != nil {
+			return m
+		}
+		return append([]byte(d.AddDate(0, 0, days).Format("2006-01-02")), m[10:]...)
+	})
+	return epochPattern.ReplaceAllFunc(body, func(m []byte) []byte {
+		parts := epochPattern.FindSubmatch(m)
+		secs, err := strconv.ParseInt(string(parts[2]), 10, 64)
+		if err != nil {
+			return m
+		}
+		shifted := time.Unix(secs, 0).In(utils.LondonLocation).AddDate(0, 0, days).Unix()
+		return []byte(string(parts[1]) + strconv.FormatInt(shifted, 10) + string(parts[3]))
+	})
+}
+
+func readIndex(dir string) (map[string]Entry, error) {
+	entries := make(map[string]Entry)
+	data, err := os.ReadFile(filepath.Join(dir, indexFile))
+	if err != nil {
+		return entries, fmt.Errorf("read fixtures index: %w", err)
+	}
+	var idx index
+	if err := json.Unmarshal(data, &idx); err != nil {
+		return nil, fmt.Errorf("parse fixtures index: %w", err)
+	}
+	for _, e := range idx.Responses {
+		entries[e.URL] = e
+	}
+	return entries, nil
+}
+
+// writeFile replaces path atomically.
+func writeFile(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+var nonSlug = regexp.MustCompile(`[^a-z0-9]+`)
+
+// fileName derives a readable, unique file name for url's response.
+func fileName(url, contentType string) string {
+	slug := strings.ToLower(url)
+	slug = strings.TrimPrefix(strings.TrimPrefix(slug, "https://"), "http://")
+	slug = strings.Trim(nonSlug.ReplaceAllString(slug, "-"), "-")
+	if len(slug) > 60 {
+		slug = strings.TrimRight(slug[:60], "-")
+	}
+	sum := sha256.Sum256([]byte(url))
+	return slug + "-" + hex.EncodeToString(sum[:4]) + extension(contentType)
+}
+
+func extension(contentType string) string {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case strings.Contains(mediaType, "html"):
+		return ".html"
+	case strings.Contains(mediaType, "json"):
+		return ".json"
+	case strings.Contains(mediaType, "xml"):
+		return ".xml"
+	default:
+		return ".txt"
+	}
+}
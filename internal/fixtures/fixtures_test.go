@@ -0,0 +1,129 @@
+package fixtures
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Takenobou/thamestracker/internal/helpers/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	logger.InitLogger()
+	os.Exit(m.Run())
+}
+
+func get(t *testing.T, rt http.RoundTripper, url string) (*http.Response, string) {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("GET %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return resp, string(body)
+}
+
+func TestRecordThenReplay(t *testing.T) {
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		switch r.URL.Query().Get("page") {
+		case "":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write([]byte(`<html><body>page 0</body></html>`))
+		case "1":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"page":1}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	rec, err := NewRecorder(dir, nil)
+	assert.NoError(t, err)
+	_, body := get(t, rec, server.URL)
+	assert.Equal(t, `<html><body>page 0</body></html>`, body, "the caller still gets the response")
+	get(t, rec, server.URL+"?page=1")
+	resp, _ := get(t, rec, server.URL+"?page=2")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	assert.Equal(t, 3, calls)
+
+	// a second recorder adds to the index rather than replacing it
+	rec, err = NewRecorder(dir, nil)
+	assert.NoError(t, err)
+	assert.Len(t, rec.entries, 2, "only successful responses are recorded")
+
+	server.Close()
+	rp, err := NewReplayer(dir, false)
+	assert.NoError(t, err)
+	resp, body = get(t, rp, server.URL)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/html; charset=utf-8", resp.Header.Get("Content-Type"))
+	assert.Equal(t, `<html><body>page 0</body></html>`, body)
+	_, body = get(t, rp, server.URL+"?page=1")
+	assert.Equal(t, `{"page":1}`, body)
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"?page=2", nil)
+	_, err = rp.RoundTrip(req)
+	assert.ErrorIs(t, err, ErrNoFixture)
+}
+
+func TestReplay_Cancelled(t *testing.T) {
+	rp, err := NewReplayer("../../testdata/fixtures", false)
+	assert.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "https://pla.co.uk/pla-proxy/five-minute?url=ships/lists", nil)
+	_, err = rp.RoundTrip(req)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestReplay_TimeShift(t *testing.T) {
+	rp, err := NewReplayer("../../testdata/fixtures", true)
+	assert.NoError(t, err)
+	// recorded on 5 April 2025; replayed on 1 May 2025 the dates move 26 days
+	rp.Now = func() time.Time { return time.Date(2025, 5, 1, 9, 0, 0, 0, time.UTC) }
+
+	_, body := get(t, rp, "https://pla.co.uk/pla-proxy/five-minute?url=ships/lists")
+	assert.Contains(t, body, `"last_rep_dt": "2025-05-01 08:33:47.150"`)
+	assert.Contains(t, body, `"etad_dt": "2025-05-02 14:15:00.000"`)
+	assert.NotContains(t, body, "2025-04-0")
+
+	_, body = get(t, rp, "https://www.towerbridge.org.uk/flat/lift-times")
+	// the page carries Unix seconds: 17:45 BST on 5 April becomes 17:45 BST on 1 May
+	assert.Contains(t, body, `<time datetime="1746117900">17:45</time>`)
+	assert.Contains(t, body, `05 Apr 2025`, "text without a time is left alone")
+}
+
+func TestShiftDates(t *testing.T) {
+	in := []byte(`2025-12-31T23:00:00Z 2025-12-31 23:00:00.000 2025-12-31 id-2025-12-31`)
+	assert.Equal(t, `2026-01-01T23:00:00Z 2026-01-01 23:00:00.000 2025-12-31 id-2025-12-31`, string(shiftDates(in, 1)))
+	assert.Equal(t, string(in), string(shiftDates(in, 0)))
+
+	// 18:00 GMT on 29 March 2025 is 18:00 BST a day later, across the clock change
+	epoch := []byte(`<time datetime="1743271200"> <time datetime='1743271200'> <td>1743271200</td>`)
+	assert.Equal(t, `<time datetime="1743354000"> <time datetime='1743354000'> <td>1743271200</td>`, string(shiftDates(epoch, 1)))
+}
+
+func TestNewTransport(t *testing.T) {
+	base := http.DefaultTransport
+	rt, err := NewTransport("", "", false, base)
+	assert.NoError(t, err)
+	assert.Equal(t, base, rt)
+	rt, err = NewTransport(ModeReplay, "../../testdata/fixtures", false, base)
+	assert.NoError(t, err)
+	assert.IsType(t, &Replayer{}, rt)
+	_, err = NewTransport(ModeReplay, t.TempDir(), false, base)
+	assert.Error(t, err, "replaying needs an index")
+	_, err = NewTransport("playback", "", false, base)
+	assert.Error(t, err)
+}
@@ -14,19 +14,33 @@ import (
 	"github.com/Takenobou/thamestracker/internal/config"
 	"github.com/Takenobou/thamestracker/internal/helpers/logger"
 	"github.com/Takenobou/thamestracker/internal/helpers/metrics"
+	"github.com/Takenobou/thamestracker/internal/helpers/tracing"
 	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Cache defines the interface for a cache.
 type Cache interface {
-	Set(key string, value interface{}, ttl time.Duration) error
-	Get(key string, dest interface{}) error
+	Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error
+	Get(ctx context.Context, key string, dest interface{}) error
+}
+
+// Pinger is implemented by caches backed by a remote store whose
+// reachability can be checked.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// UsageReporter is implemented by bounded in-memory caches.
+type UsageReporter interface {
+	// Usage reports the number of entries held and the maximum.
+	Usage() (entries, capacity int)
 }
 
 // RedisCache is a Redis implementation of the Cache interface.
 type RedisCache struct {
 	client *redis.Client
-	ctx    context.Context
 }
 
 // NewRedisCache creates a new RedisCache or fallbackCache.
@@ -55,40 +69,53 @@ func NewRedisCache(addr string) Cache {
 		if u.Scheme == "rediss" || strings.EqualFold(u.Query().Get("ssl"), "true") {
 			opts.TLSConfig = &tls.Config{
 				MinVersion:         tls.VersionTLS12,
-				InsecureSkipVerify: config.AppConfig.Redis.InsecureSkipVerify,
+				InsecureSkipVerify: config.Get().Redis.InsecureSkipVerify,
 			}
 		}
 	} else {
 		opts.Addr = addr
 	}
 	client := redis.NewClient(&opts)
-	return &RedisCache{client: client, ctx: context.Background()}
+	return &RedisCache{client: client}
 }
 
 // Set stores data in Redis.
-func (r *RedisCache) Set(key string, value interface{}, ttl time.Duration) error {
+func (r *RedisCache) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) (err error) {
+	ctx, span := startSpan(ctx, "cache.Set", "redis", key)
+	defer func() { tracing.End(span, err) }()
 	jsonData, err := json.Marshal(value)
 	if err != nil {
 		return err
 	}
-	logger.Logger.Infof("Saving data to Redis, key: %s", key)
-	if err := r.client.Set(r.ctx, key, jsonData, ttl).Err(); err != nil {
+	logger.FromContext(ctx).Infof("Saving data to Redis, key: %s", key)
+	if err := r.client.Set(ctx, key, jsonData, ttl).Err(); err != nil {
 		metrics.RedisErrorsTotal.Inc()
-		logger.Logger.Errorf("Redis SET error (key=%s): %v", key, err)
+		logger.FromContext(ctx).Errorf("Redis SET error (key=%s): %v", key, err)
 		return err
 	}
 	return nil
 }
 
+// Ping checks that Redis is reachable.
+func (r *RedisCache) Ping(ctx context.Context) error {
+	return r.client.Ping(ctx).Err()
+}
+
 // Get retrieves data from Redis.
-func (r *RedisCache) Get(key string, dest interface{}) error {
-	data, err := r.client.Get(r.ctx, key).Result()
+func (r *RedisCache) Get(ctx context.Context, key string, dest interface{}) error {
+	ctx, span := startSpan(ctx, "cache.Get", "redis", key)
+	defer span.End()
+	data, err := r.client.Get(ctx, key).Result()
+	span.SetAttributes(attribute.Bool("cache.hit", err == nil))
 	if err != nil {
 		metrics.RedisErrorsTotal.Inc()
-		logger.Logger.Warnf("Redis GET error (key=%s): %v", key, err)
+		logger.FromContext(ctx).Warnf("Redis GET error (key=%s): %v", key, err)
+		if !errors.Is(err, redis.Nil) {
+			span.RecordError(err)
+		}
 		return errors.New("cache miss")
 	}
-	logger.Logger.Infof("Cache hit, key: %s", key)
+	logger.FromContext(ctx).Infof("Cache hit, key: %s", key)
 	return json.Unmarshal([]byte(data), dest)
 }
 
@@ -108,7 +135,7 @@ type fallbackCache struct {
 }
 
 func newFallbackCache() *fallbackCache {
-	cfg := config.AppConfig
+	cfg := config.Get()
 	fc := &fallbackCache{
 		size:  cfg.FallbackCacheSize,
 		ttl:   time.Duration(cfg.FallbackCacheTTLSeconds) * time.Second,
@@ -138,7 +165,9 @@ func (f *fallbackCache) startPurge() {
 }
 
 // Set stores in fallback and also evicts LFU when full.
-func (f *fallbackCache) Set(key string, value interface{}, ttl time.Duration) error {
+func (f *fallbackCache) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) (err error) {
+	_, span := startSpan(ctx, "cache.Set", "memory", key)
+	defer func() { tracing.End(span, err) }()
 	f.mu.Lock()
 	defer f.mu.Unlock()
 	if len(f.items) >= f.size {
@@ -157,19 +186,39 @@ func (f *fallbackCache) Set(key string, value interface{}, ttl time.Duration) er
 	if err != nil {
 		return err
 	}
-	f.items[key] = &entry{data: data, expiresAt: time.Now().Add(f.ttl), freq: 1}
+	// CACHE_TTL_SECONDS is the minimum lifetime; longer TTLs (e.g. last known good copies) are kept
+	if ttl < f.ttl {
+		ttl = f.ttl
+	}
+	f.items[key] = &entry{data: data, expiresAt: time.Now().Add(ttl), freq: 1}
 	return nil
 }
 
 // Get retrieves from fallback; removes expired entries.
-func (f *fallbackCache) Get(key string, dest interface{}) error {
+func (f *fallbackCache) Get(ctx context.Context, key string, dest interface{}) error {
+	_, span := startSpan(ctx, "cache.Get", "memory", key)
+	defer span.End()
 	f.mu.Lock()
 	defer f.mu.Unlock()
 	e, ok := f.items[key]
-	if !ok || time.Now().After(e.expiresAt) {
+	hit := ok && !time.Now().After(e.expiresAt)
+	span.SetAttributes(attribute.Bool("cache.hit", hit))
+	if !hit {
 		delete(f.items, key)
 		return errors.New("cache miss")
 	}
 	e.freq++
 	return json.Unmarshal(e.data, dest)
 }
+
+// startSpan starts the span of one cache operation.
+func startSpan(ctx context.Context, name, backend, key string) (context.Context, trace.Span) {
+	return tracing.Start(ctx, name, attribute.String("cache.backend", backend), attribute.String("cache.key", key))
+}
+
+// Usage reports the number of entries held, including expired ones not yet purged.
+func (f *fallbackCache) Usage() (entries, capacity int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.items), f.size
+}
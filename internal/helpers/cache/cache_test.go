@@ -1,6 +1,7 @@
 package cache
 
 import (
+	"context"
 	"os"
 	"testing"
 	"time"
@@ -22,22 +23,46 @@ func TestRedisCache_SetGet(t *testing.T) {
 	defer srv.Close()
 
 	c := NewRedisCache(srv.Addr())
+	ctx := context.Background()
 	key := "test_key"
 	value := map[string]string{"foo": "bar"}
 	ttl := 5 * time.Second
 
 	// Set value in cache.
-	err = c.Set(key, value, ttl)
+	err = c.Set(ctx, key, value, ttl)
 	assert.NoError(t, err)
 
 	// Get value from cache.
 	var result map[string]string
-	err = c.Get(key, &result)
+	err = c.Get(ctx, key, &result)
 	assert.NoError(t, err)
 	assert.Equal(t, value, result)
 
 	// Wait for TTL expiration in miniredis (fast-forward time).
 	srv.FastForward(ttl + 1*time.Second)
-	err = c.Get(key, &result)
+	err = c.Get(ctx, key, &result)
 	assert.Error(t, err, "expected cache miss after TTL expiration")
 }
+
+func TestLastGood_OutlivesRegularEntry(t *testing.T) {
+	srv, err := miniredis.Run()
+	assert.NoError(t, err)
+	defer srv.Close()
+
+	c := NewRedisCache(srv.Addr())
+	ctx := context.Background()
+	value := []string{"a", "b"}
+	assert.NoError(t, SetWithLastGood(ctx, c, "k", value, time.Minute))
+
+	srv.FastForward(2 * time.Minute)
+	var result []string
+	assert.Error(t, c.Get(ctx, "k", &result), "regular entry should have expired")
+
+	storedAt, err := GetLastGood(ctx, c, "k", &result)
+	assert.NoError(t, err)
+	assert.Equal(t, value, result)
+	assert.WithinDuration(t, time.Now(), storedAt, 5*time.Second)
+
+	_, err = GetLastGood(ctx, c, "missing", &result)
+	assert.Error(t, err)
+}
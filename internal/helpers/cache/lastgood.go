@@ -0,0 +1,53 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// LastGoodTTL is how long the last known good copy of a key is kept after
+// its regular entry has expired, so it can still be served if upstream fails.
+const LastGoodTTL = 7 * 24 * time.Hour
+
+// lastGood is a timestamped copy of a successfully fetched value.
+type lastGood struct {
+	StoredAt time.Time       `json:"stored_at"`
+	Data     json.RawMessage `json:"data"`
+}
+
+func lastGoodKey(key string) string {
+	return key + "_last_good"
+}
+
+// SetWithLastGood stores value under key for ttl and also keeps a timestamped
+// last known good copy of it for LastGoodTTL.
+func SetWithLastGood(ctx context.Context, c Cache, key string, value interface{}, ttl time.Duration) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	// keep the fallback copy even if the regular entry cannot be written
+	lgErr := c.Set(ctx, lastGoodKey(key), lastGood{StoredAt: time.Now().UTC(), Data: data}, LastGoodTTL)
+	if err := c.Set(ctx, key, value, ttl); err != nil {
+		return err
+	}
+	return lgErr
+}
+
+// GetLastGood loads the last known good copy of key into dest and reports when
+// it was stored.
+func GetLastGood(ctx context.Context, c Cache, key string, dest interface{}) (time.Time, error) {
+	var lg lastGood
+	if err := c.Get(ctx, lastGoodKey(key), &lg); err != nil {
+		return time.Time{}, err
+	}
+	if len(lg.Data) == 0 {
+		return time.Time{}, fmt.Errorf("empty last known good copy for %s", key)
+	}
+	if err := json.Unmarshal(lg.Data, dest); err != nil {
+		return time.Time{}, err
+	}
+	return lg.StoredAt, nil
+}
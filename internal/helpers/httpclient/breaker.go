@@ -0,0 +1,139 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Takenobou/thamestracker/internal/helpers/logger"
+	"github.com/Takenobou/thamestracker/internal/helpers/metrics"
+	"github.com/sony/gobreaker"
+)
+
+// BreakerStatus is the state of one circuit breaker: "closed", "half-open" or "open".
+type BreakerStatus struct {
+	Name  string `json:"name"`
+	State string `json:"state"`
+}
+
+// BreakerSet tracks circuit breakers by name so their state can be reported.
+type BreakerSet struct {
+	mu       sync.Mutex
+	breakers map[string]*gobreaker.CircuitBreaker
+}
+
+// Breakers holds every breaker created by NewBreaker.
+var Breakers = &BreakerSet{breakers: make(map[string]*gobreaker.CircuitBreaker)}
+
+// Status returns the state of each breaker, sorted by name.
+func (s *BreakerSet) Status() []BreakerStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]BreakerStatus, 0, len(s.breakers))
+	for name, cb := range s.breakers {
+		out = append(out, BreakerStatus{Name: name, State: cb.State().String()})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+func (s *BreakerSet) add(cb *gobreaker.CircuitBreaker) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.breakers[cb.Name()] = cb
+}
+
+// NewBreaker creates a circuit breaker for one upstream, which opens after
+// maxFailures consecutive failures and lets a trial request through after
+// coolOffSeconds. State changes are logged and exported as metrics, and the
+// breaker is added to Breakers, replacing any earlier one of the same name.
+func NewBreaker(name string, maxFailures int, coolOffSeconds int) *gobreaker.CircuitBreaker {
+	cb := gobreaker.NewCircuitBreaker(gobreaker.Settings{
+		Name:    name,
+		Timeout: time.Duration(coolOffSeconds) * time.Second,
+		ReadyToTrip: func(counts gobreaker.Counts) bool {
+			return counts.ConsecutiveFailures >= uint32(maxFailures)
+		},
+		OnStateChange: func(name string, from, to gobreaker.State) {
+			metrics.CircuitBreakerState.WithLabelValues(name).Set(float64(to))
+			metrics.CircuitBreakerTransitionsTotal.WithLabelValues(name, from.String(), to.String()).Inc()
+			if to == gobreaker.StateOpen {
+				logger.Logger.Warnf("Circuit breaker opened, breaker: %s, from: %s, cool off: %ds", name, from, coolOffSeconds)
+				return
+			}
+			logger.Logger.Infof("Circuit breaker state changed, breaker: %s, from: %s, to: %s", name, from, to)
+		},
+		// a cancelled caller says nothing about the upstream's health
+		IsSuccessful: func(err error) bool {
+			return err == nil || errors.Is(err, context.Canceled)
+		},
+	})
+	metrics.CircuitBreakerState.WithLabelValues(name).Set(float64(gobreaker.StateClosed))
+	Breakers.add(cb)
+	return cb
+}
+
+// ReloadableBreaker is a circuit breaker (see NewBreaker) whose thresholds can
+// be changed while it runs. A change made while the breaker is open or
+// half-open waits until it closes, so a reload during an outage neither lets
+// requests through to the failing upstream nor restarts the cool-off.
+type ReloadableBreaker struct {
+	name    string
+	mu      sync.Mutex // guards current and pending
+	cb      atomic.Pointer[gobreaker.CircuitBreaker]
+	current breakerSettings
+	pending *breakerSettings
+}
+
+type breakerSettings struct {
+	maxFailures, coolOffSeconds int
+}
+
+// NewReloadableBreaker creates a breaker as NewBreaker does.
+func NewReloadableBreaker(name string, maxFailures int, coolOffSeconds int) *ReloadableBreaker {
+	b := &ReloadableBreaker{name: name, current: breakerSettings{maxFailures, coolOffSeconds}}
+	b.cb.Store(NewBreaker(name, maxFailures, coolOffSeconds))
+	return b
+}
+
+// Execute runs fn if the breaker allows it, as gobreaker.CircuitBreaker.Execute
+// does, then applies any pending thresholds if the breaker has closed.
+func (b *ReloadableBreaker) Execute(fn func() (interface{}, error)) (interface{}, error) {
+	result, err := b.cb.Load().Execute(fn)
+	b.mu.Lock()
+	b.apply()
+	b.mu.Unlock()
+	return result, err
+}
+
+// State returns the current state of the breaker.
+func (b *ReloadableBreaker) State() gobreaker.State {
+	return b.cb.Load().State()
+}
+
+// Reconfigure changes the breaker's thresholds, replacing it with a closed one
+// now if it is closed, or once it next closes otherwise.
+func (b *ReloadableBreaker) Reconfigure(maxFailures int, coolOffSeconds int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	next := breakerSettings{maxFailures, coolOffSeconds}
+	b.pending = &next
+	if next == b.current {
+		b.pending = nil
+	}
+	b.apply()
+}
+
+// apply replaces the breaker with one using the pending thresholds, if any,
+// once it is closed. b.mu must be held.
+func (b *ReloadableBreaker) apply() {
+	if b.pending == nil || b.cb.Load().State() != gobreaker.StateClosed {
+		return
+	}
+	b.cb.Store(NewBreaker(b.name, b.pending.maxFailures, b.pending.coolOffSeconds))
+	b.current = *b.pending
+	b.pending = nil
+}
@@ -1,61 +1,124 @@
 package httpclient
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"time"
 
-	"github.com/sony/gobreaker"
+	"github.com/Takenobou/thamestracker/internal/helpers/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// Client sends HTTP requests; *http.Client satisfies it. Cancellation and
+// deadlines are carried by the request's context.
 type Client interface {
-	Get(url string) (*http.Response, error)
+	Do(req *http.Request) (*http.Response, error)
 }
 
 // ClientFunc adapter for using ordinary functions as Client.
-type ClientFunc func(url string) (*http.Response, error)
+type ClientFunc func(req *http.Request) (*http.Response, error)
 
-// Get calls f(url).
-func (f ClientFunc) Get(url string) (*http.Response, error) {
-	return f(url)
+// Do calls f(req).
+func (f ClientFunc) Do(req *http.Request) (*http.Response, error) {
+	return f(req)
 }
 
-var DefaultClient Client = &http.Client{
-	Timeout: 15 * time.Second,
-	Transport: &http.Transport{
-		IdleConnTimeout:     30 * time.Second,
-		TLSHandshakeTimeout: 10 * time.Second,
-		MaxIdleConns:        100,
-		MaxIdleConnsPerHost: 10,
-	},
+var DefaultClient Client = NewClient(&http.Transport{
+	IdleConnTimeout:     30 * time.Second,
+	TLSHandshakeTimeout: 10 * time.Second,
+	MaxIdleConns:        100,
+	MaxIdleConnsPerHost: 10,
+})
+
+// NewClient returns a client with the default timeout that sends requests through rt.
+func NewClient(rt http.RoundTripper) *http.Client {
+	return &http.Client{Timeout: 15 * time.Second, Transport: rt}
+}
+
+// AsTransport adapts c to an http.RoundTripper, for code such as colly that
+// builds its own *http.Client.
+func AsTransport(c Client) http.RoundTripper {
+	return clientTransport{c}
+}
+
+type clientTransport struct {
+	client Client
+}
+
+func (t clientTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.client.Do(req)
+}
+
+// WithUserAgent sets the User-Agent header of every request sent through inner,
+// replacing any set by the caller.
+func WithUserAgent(inner Client, userAgent string) Client {
+	return ClientFunc(func(req *http.Request) (*http.Response, error) {
+		req = req.Clone(req.Context())
+		req.Header.Set("User-Agent", userAgent)
+		return inner.Do(req)
+	})
 }
 
-// NewBreakerClient wraps an existing Client with a circuit breaker.
-func NewBreakerClient(inner Client, maxFailures int, coolOffSeconds int) Client {
-	cb := gobreaker.NewCircuitBreaker(gobreaker.Settings{
-		Name:    "http_get_breaker",
-		Timeout: time.Duration(coolOffSeconds) * time.Second,
-		ReadyToTrip: func(counts gobreaker.Counts) bool {
-			return counts.ConsecutiveFailures >= uint32(maxFailures)
-		},
-		OnStateChange: func(name string, from, to gobreaker.State) {
-			// no-op or log state changes
-		},
+// WithTracing sends every request through inner in a client span recording
+// the method, URL and response status.
+func WithTracing(inner Client) Client {
+	return ClientFunc(func(req *http.Request) (*http.Response, error) {
+		ctx, span := tracing.Tracer().Start(req.Context(), "HTTP "+req.Method,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				attribute.String("http.request.method", req.Method),
+				attribute.String("url.full", req.URL.String()),
+			))
+		resp, err := inner.Do(req.WithContext(ctx))
+		if err == nil {
+			span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+			if resp.StatusCode >= http.StatusInternalServerError {
+				span.SetStatus(codes.Error, resp.Status)
+			}
+		}
+		tracing.End(span, err)
+		return resp, err
 	})
-	return &cbClient{client: inner, breaker: cb}
 }
 
-// cbClient implements Client by wrapping Get calls in a circuit breaker.
-type cbClient struct {
+// Get issues a GET request for url bound to ctx.
+func Get(ctx context.Context, client Client, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return client.Do(req)
+}
+
+// NewBreakerClient wraps an existing Client with a circuit breaker named after
+// the upstream it calls (see NewBreaker).
+func NewBreakerClient(name string, inner Client, maxFailures int, coolOffSeconds int) *BreakerClient {
+	return &BreakerClient{client: inner, breaker: NewReloadableBreaker(name, maxFailures, coolOffSeconds)}
+}
+
+// BreakerClient implements Client by wrapping requests in a circuit breaker.
+type BreakerClient struct {
 	client  Client
-	breaker *gobreaker.CircuitBreaker
+	breaker *ReloadableBreaker
+}
+
+// Reconfigure changes the breaker's thresholds (see ReloadableBreaker.Reconfigure).
+func (c *BreakerClient) Reconfigure(maxFailures int, coolOffSeconds int) {
+	c.breaker.Reconfigure(maxFailures, coolOffSeconds)
 }
 
-// Get invokes the inner Client.Get within the circuit breaker. Returns an error if the breaker is open.
-func (c *cbClient) Get(url string) (*http.Response, error) {
+// Do invokes the inner Client.Do within the circuit breaker. Returns an error if the breaker is open.
+func (c *BreakerClient) Do(req *http.Request) (*http.Response, error) {
 	result, err := c.breaker.Execute(func() (interface{}, error) {
-		resp, err := c.client.Get(url)
+		resp, err := c.client.Do(req)
 		if err != nil {
+			if errors.Is(req.Context().Err(), context.Canceled) {
+				return nil, req.Context().Err()
+			}
 			return nil, err
 		}
 		if resp.StatusCode >= http.StatusInternalServerError {
@@ -1,7 +1,11 @@
 package logger
 
 import (
+	"context"
 	"os"
+	"regexp"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
@@ -12,6 +16,36 @@ import (
 
 var Logger *zap.SugaredLogger
 
+// Level is the minimum level logged, info until Configure sets it. It can be
+// changed while running.
+var Level = zap.NewAtomicLevel()
+
+// sampling controls the per-row logs of a scrape: the first rows are logged,
+// then every nth; an every of 0 drops the rest.
+type sampling struct{ first, every int }
+
+// rowSampling is the current sampling, replaced as a whole by Configure.
+var rowSampling atomic.Pointer[sampling]
+
+func init() {
+	rowSampling.Store(&sampling{first: 5, every: 20})
+}
+
+// Configure sets the minimum level and the sampling of a scrape's per-row
+// logs, from the log settings of the configuration. It may be called while
+// running, such as on a configuration reload. An empty level means info.
+func Configure(level string, rowSampleFirst, rowSampleEvery int) error {
+	l := zapcore.InfoLevel
+	if level != "" {
+		if err := l.UnmarshalText([]byte(level)); err != nil {
+			return err
+		}
+	}
+	Level.SetLevel(l)
+	rowSampling.Store(&sampling{first: rowSampleFirst, every: rowSampleEvery})
+	return nil
+}
+
 func InitLogger() {
 	var zapLogger *zap.Logger
 	var err error
@@ -21,9 +55,12 @@ func InitLogger() {
 		cfg.EncoderConfig.ConsoleSeparator = "  "
 		cfg.EncoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
 		cfg.EncoderConfig.MessageKey = "msg"
+		cfg.Level = Level
 		zapLogger, err = cfg.Build()
 	} else {
-		zapLogger, err = zap.NewProduction()
+		cfg := zap.NewProductionConfig()
+		cfg.Level = Level
+		zapLogger, err = cfg.Build()
 	}
 	if err != nil {
 		panic("failed to initialize logger: " + err.Error())
@@ -31,13 +68,75 @@ func InitLogger() {
 	Logger = zapLogger.Sugar()
 }
 
+type contextKey struct{}
+
+// LocalsKey is the fiber.Ctx local holding the request-scoped logger, for
+// handlers such as WebSockets that outlive the request's user context.
+const LocalsKey = "logger"
+
+// WithContext returns a copy of ctx carrying l.
+func WithContext(ctx context.Context, l *zap.SugaredLogger) context.Context {
+	return context.WithValue(ctx, contextKey{}, l)
+}
+
+// FromContext returns the request-scoped logger carried by ctx, or Logger if
+// there is none, such as in background jobs.
+func FromContext(ctx context.Context) *zap.SugaredLogger {
+	if l, ok := ctx.Value(contextKey{}).(*zap.SugaredLogger); ok {
+		return l
+	}
+	return Logger
+}
+
+// Rows returns the logger for the per-row messages of a single scrape, which
+// logs the first few of each message and then every nth, as set by Configure.
+// Messages must be constant, with details in fields, for sampling to apply.
+func Rows(ctx context.Context) *zap.SugaredLogger {
+	l := FromContext(ctx)
+	rows := rowSampling.Load()
+	if rows.every == 1 {
+		return l
+	}
+	return l.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		// one scrape fits in a single tick, so its counts are not reset
+		return zapcore.NewSamplerWithOptions(core, time.Hour, rows.first, rows.every)
+	}))
+}
+
+// validRequestID limits the incoming X-Request-ID values that are trusted, so
+// clients cannot inject arbitrary text into logs.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// traceParent matches a W3C traceparent header, capturing the trace ID.
+var traceParent = regexp.MustCompile(`^[0-9a-f]{2}-([0-9a-f]{32})-[0-9a-f]{16}-[0-9a-f]{2}$`)
+
 // RequestLogger returns a Fiber middleware that logs each HTTP request in JSON.
+// The request ID is taken from an incoming X-Request-ID, else the trace ID of
+// an incoming traceparent, else generated, and echoed in X-Request-ID. A logger
+// carrying it (and the trace ID) is stored in the request's user context, so
+// it must run after any middleware that replaces the user context.
 func RequestLogger() fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		// Generate or retrieve request ID
-		reqID := uuid.New().String()
+		var traceID string
+		if m := traceParent.FindStringSubmatch(strings.TrimSpace(c.Get("traceparent"))); m != nil {
+			traceID = m[1]
+		}
+		reqID := c.Get("X-Request-ID")
+		if !validRequestID.MatchString(reqID) {
+			reqID = traceID
+		}
+		if reqID == "" {
+			reqID = uuid.New().String()
+		}
 		c.Set("X-Request-ID", reqID)
 
+		l := Logger.With("request_id", reqID)
+		if traceID != "" {
+			l = l.With("trace_id", traceID)
+		}
+		c.Locals(LocalsKey, l)
+		c.SetUserContext(WithContext(c.UserContext(), l))
+
 		start := time.Now()
 		err := c.Next()
 		latency := time.Since(start).Milliseconds()
@@ -45,7 +144,6 @@ func RequestLogger() fiber.Handler {
 		// Structured log: only include error when non-nil
 		fields := []interface{}{
 			"module", "api",
-			"request_id", reqID,
 			"method", c.Method(),
 			"path", c.OriginalURL(),
 			"status", c.Response().StatusCode(),
@@ -54,7 +152,7 @@ func RequestLogger() fiber.Handler {
 		if err != nil {
 			fields = append(fields, "error", err)
 		}
-		Logger.Infow("http_request", fields...)
+		l.Infow("http_request", fields...)
 		return err
 	}
 }
@@ -1,12 +1,106 @@
 package logger
 
 import (
+	"context"
+	"net/http/httptest"
 	"testing"
 
+	"github.com/gofiber/fiber/v2"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
 )
 
 func TestLoggerInit(t *testing.T) {
 	InitLogger()
 	assert.NotNil(t, Logger)
 }
+
+func TestConfigure(t *testing.T) {
+	t.Cleanup(func() { _ = Configure("", 5, 20) })
+	assert.NoError(t, Configure("warn", 1, 2))
+	assert.Equal(t, zapcore.WarnLevel, Level.Level())
+	assert.Equal(t, sampling{first: 1, every: 2}, *rowSampling.Load())
+
+	assert.Error(t, Configure("loud", 3, 4))
+	assert.Equal(t, zapcore.WarnLevel, Level.Level(), "an invalid level changes nothing")
+	assert.NoError(t, Configure("", 5, 20))
+	assert.Equal(t, zapcore.InfoLevel, Level.Level())
+}
+
+// observe replaces Logger with one recording its entries for the test.
+func observe(t *testing.T) *observer.ObservedLogs {
+	core, logs := observer.New(zapcore.DebugLevel)
+	prev := Logger
+	Logger = zap.New(core).Sugar()
+	t.Cleanup(func() { Logger = prev })
+	return logs
+}
+
+// request sends a request with headers through RequestLogger, returning the
+// X-Request-ID response header and the logger seen by the handler.
+func request(t *testing.T, headers map[string]string) (string, *zap.SugaredLogger) {
+	var seen *zap.SugaredLogger
+	app := fiber.New()
+	app.Use(RequestLogger())
+	app.Get("/", func(c *fiber.Ctx) error {
+		seen = FromContext(c.UserContext())
+		seen.Info("handled")
+		return c.SendStatus(fiber.StatusOK)
+	})
+	req := httptest.NewRequest("GET", "/", nil)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	return resp.Header.Get("X-Request-ID"), seen
+}
+
+func TestRequestLogger_HonoursIncomingID(t *testing.T) {
+	logs := observe(t)
+	id, _ := request(t, map[string]string{"X-Request-ID": "abc-123"})
+	assert.Equal(t, "abc-123", id)
+	// the handler's line and the access log carry the same ID
+	require.Equal(t, 2, logs.Len())
+	for _, e := range logs.All() {
+		assert.Equal(t, "abc-123", e.ContextMap()["request_id"], e.Message)
+	}
+}
+
+func TestRequestLogger_ReplacesInvalidID(t *testing.T) {
+	observe(t)
+	id, _ := request(t, map[string]string{"X-Request-ID": "bad id\nforged=1"})
+	assert.NotEqual(t, "bad id\nforged=1", id)
+	assert.Len(t, id, 36)
+}
+
+func TestRequestLogger_UsesTraceParent(t *testing.T) {
+	logs := observe(t)
+	traceID := "4bf92f3577b34da6a3ce929d0e0e4736"
+	id, _ := request(t, map[string]string{"traceparent": "00-" + traceID + "-00f067aa0ba902b7-01"})
+	assert.Equal(t, traceID, id)
+	fields := logs.FilterMessage("http_request").All()[0].ContextMap()
+	assert.Equal(t, traceID, fields["trace_id"])
+}
+
+func TestFromContext_FallsBackToLogger(t *testing.T) {
+	observe(t)
+	assert.Same(t, Logger, FromContext(context.Background()))
+}
+
+func TestRows_Samples(t *testing.T) {
+	logs := observe(t)
+	prev := rowSampling.Load()
+	rowSampling.Store(&sampling{first: 2, every: 3})
+	t.Cleanup(func() { rowSampling.Store(prev) })
+
+	rows := Rows(context.Background())
+	for i := 0; i < 10; i++ {
+		rows.Infow("Found lift event", "row", i)
+	}
+	// the 1st and 2nd, then every third after them: the 5th and 8th
+	assert.Equal(t, 4, logs.FilterMessage("Found lift event").Len())
+}
@@ -0,0 +1,116 @@
+package metrics
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// unmatchedRoute labels requests that did not reach a route handler, such as
+// unknown paths and rate-limited requests, so arbitrary paths cannot inflate
+// the number of series.
+const unmatchedRoute = "unmatched"
+
+var (
+	// HTTPRequestsTotal counts HTTP requests by route template, method and status class.
+	HTTPRequestsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "thamestracker_http_requests_total",
+			Help: "Total number of HTTP requests, labeled by route, method and status class.",
+		},
+		[]string{"route", "method", "status"},
+	)
+	// HTTPRequestErrorsTotal counts HTTP requests answered with a 5xx status.
+	HTTPRequestErrorsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "thamestracker_http_request_errors_total",
+			Help: "Total number of HTTP requests that failed with a server error, labeled by route, method and status class.",
+		},
+		[]string{"route", "method", "status"},
+	)
+	// HTTPRequestDuration tracks HTTP request latency.
+	HTTPRequestDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "thamestracker_http_request_duration_seconds",
+			Help:    "Duration of HTTP request handling in seconds, labeled by route, method and status class.",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"route", "method", "status"},
+	)
+	// HTTPResponseSize tracks the size of HTTP response bodies. Streamed
+	// responses (SSE, WebSockets) are not observed.
+	HTTPResponseSize = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "thamestracker_http_response_size_bytes",
+			Help:    "Size of HTTP response bodies in bytes, labeled by route, method and status class.",
+			Buckets: prometheus.ExponentialBuckets(100, 4, 8),
+		},
+		[]string{"route", "method", "status"},
+	)
+	// HTTPRequestsInFlight tracks the HTTP requests being handled.
+	HTTPRequestsInFlight = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "thamestracker_http_requests_in_flight",
+			Help: "Number of HTTP requests currently being handled.",
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(HTTPRequestsTotal, HTTPRequestErrorsTotal, HTTPRequestDuration, HTTPResponseSize,
+		HTTPRequestsInFlight)
+}
+
+// Middleware returns a Fiber middleware recording the rate, errors and
+// duration of every request, labeled by route template (e.g. /admin/webhooks/:id),
+// method and status class (2xx, 4xx, ...). Register it before any middleware
+// that may answer a request itself, such as the rate limiter. Routes must all
+// be registered before the first request.
+func Middleware() fiber.Handler {
+	var once sync.Once
+	var handlers map[string]bool // "METHOD path" of every route that is not middleware
+	return func(c *fiber.Ctx) error {
+		once.Do(func() {
+			handlers = make(map[string]bool)
+			for _, r := range c.App().GetRoutes(true) {
+				handlers[r.Method+" "+r.Path] = true
+			}
+		})
+		HTTPRequestsInFlight.Inc()
+		defer HTTPRequestsInFlight.Dec()
+		start := time.Now()
+		err := c.Next()
+
+		// a returned error is turned into a response by the error handler later
+		status := c.Response().StatusCode()
+		if err != nil {
+			status = fiber.StatusInternalServerError
+			var fe *fiber.Error
+			if errors.As(err, &fe) {
+				status = fe.Code
+			}
+		}
+		route := c.Route().Path
+		if !handlers[c.Route().Method+" "+route] {
+			route = unmatchedRoute
+		}
+		labels := prometheus.Labels{"route": route, "method": c.Method(), "status": statusClass(status)}
+		HTTPRequestsTotal.With(labels).Inc()
+		if status >= fiber.StatusInternalServerError {
+			HTTPRequestErrorsTotal.With(labels).Inc()
+		}
+		HTTPRequestDuration.With(labels).Observe(time.Since(start).Seconds())
+		if !c.Response().IsBodyStream() {
+			HTTPResponseSize.With(labels).Observe(float64(len(c.Response().Body())))
+		}
+		return err
+	}
+}
+
+func statusClass(status int) string {
+	return strconv.Itoa(status/100) + "xx"
+}
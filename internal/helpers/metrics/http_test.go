@@ -0,0 +1,36 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/limiter"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiddleware_LabelsByRouteTemplate(t *testing.T) {
+	app := fiber.New()
+	app.Use(Middleware())
+	app.Use(limiter.New(limiter.Config{Max: 2, Next: func(c *fiber.Ctx) bool { return c.Path() != "/limited" }}))
+	app.Get("/items/:id", func(c *fiber.Ctx) error { return c.SendString("item " + c.Params("id")) })
+	app.Get("/broken", func(c *fiber.Ctx) error { return c.Status(fiber.StatusBadGateway).SendString("down") })
+	app.Get("/limited", func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusOK) })
+
+	for _, path := range []string{"/items/1", "/items/2", "/broken", "/nope", "/limited", "/limited", "/limited"} {
+		_, err := app.Test(httptest.NewRequest(http.MethodGet, path, nil))
+		assert.NoError(t, err)
+	}
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(HTTPRequestsTotal.WithLabelValues("/items/:id", "GET", "2xx")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(HTTPRequestsTotal.WithLabelValues("/broken", "GET", "5xx")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(HTTPRequestErrorsTotal.WithLabelValues("/broken", "GET", "5xx")))
+	assert.Equal(t, 0.0, testutil.ToFloat64(HTTPRequestErrorsTotal.WithLabelValues("/items/:id", "GET", "2xx")))
+	assert.Equal(t, 2.0, testutil.ToFloat64(HTTPRequestsTotal.WithLabelValues("/limited", "GET", "2xx")))
+	assert.Equal(t, 2.0, testutil.ToFloat64(HTTPRequestsTotal.WithLabelValues(unmatchedRoute, "GET", "4xx")),
+		"unknown paths and rate-limited requests are not labeled with the raw path")
+	assert.Equal(t, 0.0, testutil.ToFloat64(HTTPRequestsInFlight))
+	assert.Equal(t, 4, testutil.CollectAndCount(HTTPResponseSize), "one series per route, method and status class")
+}
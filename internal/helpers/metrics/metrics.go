@@ -22,6 +22,22 @@ var (
 		},
 		[]string{"api"},
 	)
+	// ScrapesCoalescedTotal counts cache misses that waited for an in-flight scrape instead of starting their own.
+	ScrapesCoalescedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "thamestracker_scrapes_coalesced_total",
+			Help: "Total number of scrape calls coalesced into an in-flight scrape.",
+		},
+		[]string{"api"},
+	)
+	// StaleServedTotal counts responses served from the last known good copy, by cache key.
+	StaleServedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "thamestracker_stale_served_total",
+			Help: "Total number of times stale data was served after a failed scrape, labeled by cache key.",
+		},
+		[]string{"key"},
+	)
 	// CacheHits counts cache hits.
 	CacheHits = prometheus.NewCounter(
 		prometheus.CounterOpts{
@@ -36,27 +52,138 @@ var (
 			Help: "Total number of cache misses.",
 		},
 	)
-	// LocationsRequests counts GET /locations calls.
-	LocationsRequests = prometheus.NewCounter(
+	// RedisErrorsTotal counts Redis errors.
+	RedisErrorsTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "thamestracker_redis_errors_total",
+			Help: "Total number of Redis errors.",
+		},
+	)
+	// HistoryErrorsTotal counts failed writes to the event history store.
+	HistoryErrorsTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "thamestracker_history_errors_total",
+			Help: "Total number of event history store errors.",
+		},
+	)
+	// ChangesDetectedTotal counts changes between consecutive scrapes, labeled by change type and category.
+	ChangesDetectedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "thamestracker_changes_detected_total",
+			Help: "Total number of added, removed or rescheduled events detected between scrapes.",
+		},
+		[]string{"type", "category"},
+	)
+	// ChangeSubscribers tracks live change subscribers (SSE streams, WebSockets).
+	ChangeSubscribers = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "thamestracker_change_subscribers",
+			Help: "Number of live change feed subscribers.",
+		},
+	)
+	// ChangeSubscriberDropsTotal counts changes dropped for slow subscribers.
+	ChangeSubscriberDropsTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "thamestracker_change_subscriber_drops_total",
+			Help: "Total number of changes dropped because a subscriber was not keeping up.",
+		},
+	)
+	// WebhookDeliveriesTotal counts finished webhook deliveries by result (delivered, dead, aborted).
+	WebhookDeliveriesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "thamestracker_webhook_deliveries_total",
+			Help: "Total number of webhook deliveries, labeled by result.",
+		},
+		[]string{"result"},
+	)
+	// ScheduledRefreshesTotal counts background cache refreshes by job and result (success, error).
+	ScheduledRefreshesTotal = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
-			Name: "thamestracker_locations_requests_total",
-			Help: "Total number of /locations API requests.",
+			Name: "thamestracker_scheduled_refreshes_total",
+			Help: "Total number of scheduled background refreshes, labeled by job and result.",
 		},
+		[]string{"job", "result"},
 	)
-	// LocationsRequestDuration tracks duration of GET /locations.
-	LocationsRequestDuration = prometheus.NewHistogram(
+	// ScrapeRowsSeen reports the upstream rows seen by the latest scrape of each source.
+	ScrapeRowsSeen = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "thamestracker_scrape_rows_seen",
+			Help: "Number of upstream rows seen by the latest scrape, labeled by source.",
+		},
+		[]string{"source"},
+	)
+	// ScrapeRowsSkippedTotal counts upstream rows dropped by scrapers, labeled by source and reason.
+	ScrapeRowsSkippedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "thamestracker_scrape_rows_skipped_total",
+			Help: "Total number of upstream rows skipped, labeled by source and reason.",
+		},
+		[]string{"source", "reason"},
+	)
+	// ScrapeUnknownFields reports the unrecognised upstream fields in the latest scrape of each source.
+	ScrapeUnknownFields = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "thamestracker_scrape_unknown_fields",
+			Help: "Number of unrecognised upstream fields in the latest scrape, labeled by source.",
+		},
+		[]string{"source"},
+	)
+	// ScrapeZeroResultsTotal counts scrapes that produced no events, labeled by source.
+	ScrapeZeroResultsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "thamestracker_scrape_zero_results_total",
+			Help: "Total number of scrapes that produced no events, labeled by source.",
+		},
+		[]string{"source"},
+	)
+	// SourceDegraded is 1 while the shape of a source's upstream data departs from its baseline.
+	SourceDegraded = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "thamestracker_source_degraded",
+			Help: "Whether a source's upstream data has changed shape (1) or not (0), labeled by source.",
+		},
+		[]string{"source"},
+	)
+	// LastSuccessfulScrape is the Unix time of each source's latest successful scrape.
+	LastSuccessfulScrape = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "thamestracker_last_successful_scrape_timestamp_seconds",
+			Help: "Unix time of the latest successful scrape, labeled by source.",
+		},
+		[]string{"source"},
+	)
+	// ScrapedEvents reports the events found by the latest successful scrape, labeled by source and category.
+	ScrapedEvents = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "thamestracker_scraped_events",
+			Help: "Number of events found by the latest successful scrape, labeled by source and category.",
+		},
+		[]string{"source", "category"},
+	)
+	// DataAgeSeconds tracks how old the data served to clients is, labeled by source.
+	DataAgeSeconds = prometheus.NewHistogramVec(
 		prometheus.HistogramOpts{
-			Name:    "thamestracker_locations_request_duration_seconds",
-			Help:    "Duration of /locations request handling in seconds.",
-			Buckets: prometheus.DefBuckets,
+			Name:    "thamestracker_data_age_seconds",
+			Help:    "Age of the data at the time it is served in seconds, labeled by source.",
+			Buckets: []float64{60, 300, 600, 900, 1800, 3600, 7200, 21600, 86400},
 		},
+		[]string{"source"},
 	)
-	// RedisErrorsTotal counts Redis errors.
-	RedisErrorsTotal = prometheus.NewCounter(
+	// CircuitBreakerState is each circuit breaker's state: 0 closed, 1 half-open, 2 open.
+	CircuitBreakerState = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "thamestracker_circuit_breaker_state",
+			Help: "Circuit breaker state (0 closed, 1 half-open, 2 open), labeled by breaker.",
+		},
+		[]string{"breaker"},
+	)
+	// CircuitBreakerTransitionsTotal counts circuit breaker state changes.
+	CircuitBreakerTransitionsTotal = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
-			Name: "thamestracker_redis_errors_total",
-			Help: "Total number of Redis errors.",
+			Name: "thamestracker_circuit_breaker_transitions_total",
+			Help: "Total number of circuit breaker state changes, labeled by breaker and states.",
 		},
+		[]string{"breaker", "from", "to"},
 	)
 	// FilteredEventsTotal counts events filtered out by unique logic, labeled by category.
 	FilteredEventsTotal = prometheus.NewCounterVec(
@@ -66,9 +193,28 @@ var (
 		},
 		[]string{"category"},
 	)
+	// ConfigReloadsTotal counts configuration reloads, labeled by result ("success" or "failure").
+	ConfigReloadsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "thamestracker_config_reloads_total",
+			Help: "Total number of configuration reloads, labeled by result.",
+		},
+		[]string{"result"},
+	)
+	// ConfigLastReloadSuccess is the Unix time of the last successful configuration reload.
+	ConfigLastReloadSuccess = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "thamestracker_config_last_reload_success_timestamp_seconds",
+			Help: "Unix time of the last successful configuration reload.",
+		},
+	)
 )
 
 func init() {
-	prometheus.MustRegister(ScrapeCounter, ScrapeDuration, CacheHits, CacheMisses,
-		LocationsRequests, LocationsRequestDuration, RedisErrorsTotal, FilteredEventsTotal)
+	prometheus.MustRegister(ScrapeCounter, ScrapeDuration, ScrapesCoalescedTotal, StaleServedTotal,
+		CacheHits, CacheMisses, RedisErrorsTotal, HistoryErrorsTotal, ChangesDetectedTotal,
+		ChangeSubscribers, ChangeSubscriberDropsTotal, WebhookDeliveriesTotal, ScheduledRefreshesTotal,
+		ScrapeRowsSeen, ScrapeRowsSkippedTotal, ScrapeUnknownFields, ScrapeZeroResultsTotal, SourceDegraded,
+		LastSuccessfulScrape, ScrapedEvents, DataAgeSeconds, CircuitBreakerState, CircuitBreakerTransitionsTotal,
+		FilteredEventsTotal, ConfigReloadsTotal, ConfigLastReloadSuccess)
 }
@@ -0,0 +1,87 @@
+// Package tracing sets up OpenTelemetry tracing and provides helpers for
+// starting and ending spans. Until Setup installs a provider, spans are no-ops.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentation = "github.com/Takenobou/thamestracker"
+
+// Options configures the exported traces.
+type Options struct {
+	// Exporter is "otlp", "stdout", or empty to disable tracing.
+	Exporter string
+	// Endpoint is the OTLP/HTTP traces URL, e.g. http://localhost:4318/v1/traces.
+	Endpoint string
+	// SampleRatio is the fraction of new traces recorded; requests that arrive
+	// with a sampled traceparent are always recorded.
+	SampleRatio float64
+	// Writer receives stdout traces; nil means os.Stdout.
+	Writer io.Writer
+}
+
+// Setup installs a global tracer provider exporting as opts describes, and the
+// W3C trace context propagator. The returned function flushes and stops the
+// exporter.
+func Setup(ctx context.Context, opts Options) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch opts.Exporter {
+	case "":
+		return func(context.Context) error { return nil }, nil
+	case "stdout":
+		w := opts.Writer
+		if w == nil {
+			w = os.Stdout
+		}
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(w))
+	case "otlp":
+		exporter, err = otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(opts.Endpoint))
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q", opts.Exporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("create %s trace exporter: %w", opts.Exporter, err)
+	}
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(opts.SampleRatio))),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", "thamestracker"))),
+	)
+	otel.SetTracerProvider(tp)
+	return tp.Shutdown, nil
+}
+
+// Tracer returns the application's tracer, for spans that need more options than Start takes.
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentation)
+}
+
+// Start starts a span as a child of any span in ctx.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End records err, if any, on span and ends it.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,44 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+)
+
+func TestSetup_StdoutExportsSpans(t *testing.T) {
+	old := otel.GetTracerProvider()
+	t.Cleanup(func() { otel.SetTracerProvider(old) })
+
+	var out bytes.Buffer
+	shutdown, err := Setup(context.Background(), Options{Exporter: "stdout", SampleRatio: 1, Writer: &out})
+	assert.NoError(t, err)
+
+	ctx, parent := Start(context.Background(), "parent")
+	_, child := Start(ctx, "child")
+	End(child, errors.New("upstream down"))
+	End(parent, nil)
+	assert.NoError(t, shutdown(context.Background()))
+
+	assert.Contains(t, out.String(), `"Name":"parent"`)
+	assert.Contains(t, out.String(), `"Name":"child"`)
+	assert.Contains(t, out.String(), "upstream down")
+}
+
+func TestSetup_Disabled(t *testing.T) {
+	shutdown, err := Setup(context.Background(), Options{})
+	assert.NoError(t, err)
+	assert.NoError(t, shutdown(context.Background()))
+	_, span := Start(context.Background(), "noop")
+	assert.False(t, span.SpanContext().IsValid(), "spans are no-ops until an exporter is set up")
+	span.End()
+}
+
+func TestSetup_UnknownExporter(t *testing.T) {
+	_, err := Setup(context.Background(), Options{Exporter: "zipkin"})
+	assert.EqualError(t, err, `unknown trace exporter "zipkin"`)
+}
@@ -1,20 +1,40 @@
 package utils
 
 import (
+	"context"
 	"time"
+
+	"github.com/Takenobou/thamestracker/internal/helpers/tracing"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // Retry calls the provided function up to maxAttempts times, with exponential backoff starting at initial.
-// It returns nil on first success, or the last error if all attempts fail.
-func Retry(maxAttempts int, initialBackoff time.Duration, fn func() error) error {
+// It returns nil on first success, or the last error if all attempts fail. It stops early, returning
+// ctx.Err(), if ctx is cancelled while waiting between attempts. Each attempt runs in its own span,
+// and fn receives a context carrying it.
+func Retry(ctx context.Context, maxAttempts int, initialBackoff time.Duration, fn func(ctx context.Context) error) error {
 	backoff := initialBackoff
 	var err error
 	for i := 0; i < maxAttempts; i++ {
-		err = fn()
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		attemptCtx, span := tracing.Start(ctx, "retry.attempt", attribute.Int("retry.attempt", i+1))
+		err = fn(attemptCtx)
+		tracing.End(span, err)
 		if err == nil {
 			return nil
 		}
-		time.Sleep(backoff)
+		if i == maxAttempts-1 {
+			break
+		}
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
 		backoff *= 2
 	}
 	return err
@@ -0,0 +1,42 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetry_SucceedsAfterFailures(t *testing.T) {
+	attempts := 0
+	err := Retry(context.Background(), 3, time.Millisecond, func(context.Context) error {
+		attempts++
+		if attempts < 3 {
+			return errors.New("flaky")
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, attempts)
+}
+
+func TestRetry_ReturnsLastErrorWithoutTrailingSleep(t *testing.T) {
+	start := time.Now()
+	err := Retry(context.Background(), 2, 200*time.Millisecond, func(context.Context) error { return errors.New("down") })
+	assert.EqualError(t, err, "down")
+	assert.Less(t, time.Since(start), 400*time.Millisecond)
+}
+
+func TestRetry_StopsWhenContextCancelled(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	attempts := 0
+	err := Retry(ctx, 5, time.Second, func(context.Context) error {
+		attempts++
+		return errors.New("down")
+	})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, 1, attempts)
+}
@@ -0,0 +1,182 @@
+package history
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Takenobou/thamestracker/internal/models"
+	bolt "go.etcd.io/bbolt"
+)
+
+// EventStore defines the interface for a durable store of scraped events.
+type EventStore interface {
+	// Upsert inserts or updates events, keyed by models.Event.Key.
+	Upsert(events []models.Event) error
+	// Delete removes the records of events, keyed by models.Event.Key, such
+	// as lifts that were cancelled or moved. Unknown events are ignored.
+	Delete(events []models.Event) error
+	// Query returns stored events with timestamps in [after, before].
+	// A zero bound leaves that side of the range open.
+	Query(after, before time.Time) ([]models.Event, error)
+	Close() error
+}
+
+var (
+	eventsBucket = []byte("events")
+	timeBucket   = []byte("events_by_time")
+)
+
+// indexLayout sorts lexicographically in time order.
+const indexLayout = "20060102T150405Z"
+
+// record is the stored form of an event.
+type record struct {
+	Event     models.Event `json:"event"`
+	FirstSeen time.Time    `json:"first_seen"`
+	LastSeen  time.Time    `json:"last_seen"`
+}
+
+// BoltStore is a bbolt implementation of the EventStore interface.
+type BoltStore struct {
+	db *bolt.DB
+}
+
+// NewBoltStore opens (or creates) the history database at path.
+func NewBoltStore(path string) (*BoltStore, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("creating history directory: %w", err)
+		}
+	}
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("opening history database: %w", err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(eventsBucket); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucketIfNotExists(timeBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("initialising history database: %w", err)
+	}
+	return &BoltStore{db: db}, nil
+}
+
+func indexKey(ts time.Time, key string) []byte {
+	return []byte(ts.UTC().Format(indexLayout) + "|" + key)
+}
+
+// Upsert stores events, replacing any earlier record with the same key.
+func (s *BoltStore) Upsert(events []models.Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+	now := time.Now().UTC()
+	return s.db.Update(func(tx *bolt.Tx) error {
+		eb := tx.Bucket(eventsBucket)
+		tb := tx.Bucket(timeBucket)
+		for _, e := range events {
+			key := e.Key()
+			rec := record{Event: e, FirstSeen: now, LastSeen: now}
+			if existing := eb.Get([]byte(key)); existing != nil {
+				var old record
+				if err := json.Unmarshal(existing, &old); err == nil {
+					rec.FirstSeen = old.FirstSeen
+					if !old.Event.Timestamp.Equal(e.Timestamp) {
+						if err := tb.Delete(indexKey(old.Event.Timestamp, key)); err != nil {
+							return err
+						}
+					}
+				}
+			}
+			data, err := json.Marshal(rec)
+			if err != nil {
+				return err
+			}
+			if err := eb.Put([]byte(key), data); err != nil {
+				return err
+			}
+			if err := tb.Put(indexKey(e.Timestamp, key), []byte(key)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Delete removes the stored records of events and their time index entries.
+func (s *BoltStore) Delete(events []models.Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		eb := tx.Bucket(eventsBucket)
+		tb := tx.Bucket(timeBucket)
+		for _, e := range events {
+			key := e.Key()
+			existing := eb.Get([]byte(key))
+			if existing == nil {
+				continue
+			}
+			var old record
+			if err := json.Unmarshal(existing, &old); err == nil {
+				if err := tb.Delete(indexKey(old.Event.Timestamp, key)); err != nil {
+					return err
+				}
+			}
+			if err := eb.Delete([]byte(key)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Query returns events ordered by timestamp within the given range.
+func (s *BoltStore) Query(after, before time.Time) ([]models.Event, error) {
+	events := make([]models.Event, 0)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		eb := tx.Bucket(eventsBucket)
+		c := tx.Bucket(timeBucket).Cursor()
+		var k, v []byte
+		if after.IsZero() {
+			k, v = c.First()
+		} else {
+			k, v = c.Seek([]byte(after.UTC().Format(indexLayout)))
+		}
+		for ; k != nil; k, v = c.Next() {
+			data := eb.Get(v)
+			if data == nil {
+				continue
+			}
+			var rec record
+			if err := json.Unmarshal(data, &rec); err != nil {
+				return err
+			}
+			if !after.IsZero() && rec.Event.Timestamp.Before(after) {
+				continue
+			}
+			if !before.IsZero() && rec.Event.Timestamp.After(before) {
+				break
+			}
+			events = append(events, rec.Event)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// Close releases the underlying database.
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
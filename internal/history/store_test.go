@@ -0,0 +1,107 @@
+package history
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Takenobou/thamestracker/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestStore(t *testing.T) *BoltStore {
+	t.Helper()
+	s, err := NewBoltStore(filepath.Join(t.TempDir(), "history.db"))
+	assert.NoError(t, err)
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestBoltStore_UpsertDoesNotDuplicate(t *testing.T) {
+	s := newTestStore(t)
+	lift := models.Event{
+		Timestamp:  time.Date(2025, 4, 5, 17, 45, 0, 0, time.UTC),
+		VesselName: "Paddle Steamer Dixie Queen",
+		Category:   "bridge",
+		Direction:  "Up river",
+	}
+	assert.NoError(t, s.Upsert([]models.Event{lift}))
+	assert.NoError(t, s.Upsert([]models.Event{lift}))
+
+	events, err := s.Query(time.Time{}, time.Time{})
+	assert.NoError(t, err)
+	assert.Len(t, events, 1)
+	assert.Equal(t, "Paddle Steamer Dixie Queen", events[0].VesselName)
+}
+
+func TestBoltStore_UpsertUpdatesVoyageTimestamp(t *testing.T) {
+	s := newTestStore(t)
+	first := models.Event{
+		Timestamp:  time.Date(2025, 3, 13, 14, 0, 0, 0, time.UTC),
+		VesselName: "SAN NICOLAS MAERSK",
+		Category:   "arrivals",
+		VoyageNo:   "S7795",
+	}
+	second := first
+	second.Timestamp = first.Timestamp.Add(2 * time.Hour)
+	assert.NoError(t, s.Upsert([]models.Event{first}))
+	assert.NoError(t, s.Upsert([]models.Event{second}))
+
+	events, err := s.Query(time.Time{}, time.Time{})
+	assert.NoError(t, err)
+	assert.Len(t, events, 1)
+	assert.True(t, second.Timestamp.Equal(events[0].Timestamp))
+
+	// the stale time index entry must not match the old timestamp
+	events, err = s.Query(first.Timestamp, first.Timestamp.Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Empty(t, events)
+}
+
+func TestBoltStore_Delete(t *testing.T) {
+	s := newTestStore(t)
+	moved := models.Event{Timestamp: time.Date(2025, 4, 5, 17, 45, 0, 0, time.UTC), VesselName: "Dixie Queen", Category: "bridge", Direction: "Up river"}
+	kept := moved
+	kept.Timestamp = moved.Timestamp.Add(time.Hour)
+	assert.NoError(t, s.Upsert([]models.Event{moved, kept}))
+
+	assert.NoError(t, s.Delete([]models.Event{moved, {VesselName: "Unknown", Category: "bridge"}}))
+	events, err := s.Query(time.Time{}, time.Time{})
+	assert.NoError(t, err)
+	assert.Len(t, events, 1)
+	assert.True(t, kept.Timestamp.Equal(events[0].Timestamp))
+}
+
+func TestBoltStore_QueryRange(t *testing.T) {
+	s := newTestStore(t)
+	base := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	var events []models.Event
+	for i := 0; i < 5; i++ {
+		events = append(events, models.Event{
+			Timestamp:  base.Add(time.Duration(i) * 24 * time.Hour),
+			VesselName: "Vessel",
+			Category:   "bridge",
+		})
+	}
+	assert.NoError(t, s.Upsert(events))
+
+	got, err := s.Query(base.Add(24*time.Hour), base.Add(3*24*time.Hour))
+	assert.NoError(t, err)
+	assert.Len(t, got, 3)
+	assert.True(t, got[0].Timestamp.Before(got[2].Timestamp))
+}
+
+func TestBoltStore_PersistsAcrossReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.db")
+	s, err := NewBoltStore(path)
+	assert.NoError(t, err)
+	assert.NoError(t, s.Upsert([]models.Event{{VesselName: "A", Category: "inport", VoyageNo: "V1"}}))
+	assert.NoError(t, s.Close())
+
+	s, err = NewBoltStore(path)
+	assert.NoError(t, err)
+	defer s.Close()
+	events, err := s.Query(time.Time{}, time.Time{})
+	assert.NoError(t, err)
+	assert.Len(t, events, 1)
+}
@@ -1,6 +1,9 @@
 package models
 
-import "time"
+import (
+	"strings"
+	"time"
+)
 
 // Event represents a unified event for bridge lifts and vessel movements.
 type Event struct {
@@ -13,4 +16,26 @@ type Event struct {
 	From        string    `json:"from,omitempty"`
 	To          string    `json:"to,omitempty"`
 	Location    string    `json:"location,omitempty"`
+	// Source names the configured feed the event came from; empty for the
+	// built-in Tower Bridge and PLA scrapers.
+	Source string `json:"source,omitempty"`
+	// Stale is set when the event comes from the last known good copy because
+	// the upstream source could not be scraped.
+	Stale bool `json:"stale,omitempty"`
+}
+
+// Key returns a stable identity for the event so that repeated scrapes of the
+// same lift or voyage map to the same record. Vessel movements are identified
+// by voyage number; bridge lifts (and voyages without a number) by their time.
+// Events from configured sources are additionally namespaced by source.
+func (e Event) Key() string {
+	category := strings.ToLower(e.Category)
+	if e.Source != "" {
+		category = e.Source + "/" + category
+	}
+	name := strings.ToLower(strings.TrimSpace(e.VesselName))
+	if strings.ToLower(e.Category) != "bridge" && e.VoyageNo != "" {
+		return strings.Join([]string{category, name, strings.ToLower(e.VoyageNo)}, "|")
+	}
+	return strings.Join([]string{category, name, strings.ToLower(e.Direction), e.Timestamp.UTC().Format(time.RFC3339)}, "|")
 }
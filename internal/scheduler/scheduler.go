@@ -0,0 +1,152 @@
+package scheduler
+
+import (
+	"context"
+	"math/rand/v2"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Takenobou/thamestracker/internal/helpers/logger"
+	"github.com/Takenobou/thamestracker/internal/helpers/metrics"
+)
+
+// JobFunc refreshes a single source.
+type JobFunc func(ctx context.Context) error
+
+// JobStatus reports the last and next run of a scheduled job.
+type JobStatus struct {
+	Name            string     `json:"name"`
+	IntervalSeconds float64    `json:"interval_seconds"`
+	Running         bool       `json:"running"`
+	LastRun         *time.Time `json:"last_run,omitempty"`
+	LastSuccess     *time.Time `json:"last_success,omitempty"`
+	LastDurationMs  int64      `json:"last_duration_ms"`
+	LastError       string     `json:"last_error,omitempty"`
+	NextRun         *time.Time `json:"next_run,omitempty"`
+	Runs            int        `json:"runs"`
+	Failures        int        `json:"failures"`
+}
+
+type job struct {
+	name     string
+	interval time.Duration
+	run      JobFunc
+	status   JobStatus
+}
+
+// Scheduler runs each registered job on its own interval, with random jitter
+// so that refreshes of different sources do not line up.
+type Scheduler struct {
+	mu     sync.Mutex
+	jitter time.Duration
+	jobs   []*job
+	now    func() time.Time
+}
+
+// New creates a Scheduler; each run is delayed or advanced by up to jitter.
+func New(jitter time.Duration) *Scheduler {
+	if jitter < 0 {
+		jitter = 0
+	}
+	return &Scheduler{jitter: jitter, now: time.Now}
+}
+
+// Add registers a job. Jobs with a non-positive interval are ignored.
+func (s *Scheduler) Add(name string, interval time.Duration, fn JobFunc) {
+	if interval <= 0 {
+		logger.Logger.Infof("Background refresh disabled, job: %s", name)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs = append(s.jobs, &job{
+		name:     name,
+		interval: interval,
+		run:      fn,
+		status:   JobStatus{Name: name, IntervalSeconds: interval.Seconds()},
+	})
+}
+
+// Start runs every job immediately, to warm the cache, and then on its
+// interval until ctx is cancelled. It does not block.
+func (s *Scheduler) Start(ctx context.Context) {
+	s.mu.Lock()
+	jobs := append([]*job(nil), s.jobs...)
+	s.mu.Unlock()
+	for _, j := range jobs {
+		go s.loop(ctx, j)
+	}
+}
+
+func (s *Scheduler) loop(ctx context.Context, j *job) {
+	for {
+		s.runOnce(ctx, j)
+		delay := s.nextDelay(j.interval)
+		next := s.now().Add(delay)
+		s.mu.Lock()
+		j.status.NextRun = &next
+		s.mu.Unlock()
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+func (s *Scheduler) runOnce(ctx context.Context, j *job) {
+	start := s.now()
+	s.mu.Lock()
+	j.status.Running = true
+	j.status.LastRun = &start
+	j.status.NextRun = nil
+	s.mu.Unlock()
+
+	err := j.run(ctx)
+	elapsed := time.Since(start)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j.status.Running = false
+	j.status.Runs++
+	j.status.LastDurationMs = elapsed.Milliseconds()
+	if err != nil {
+		j.status.Failures++
+		j.status.LastError = err.Error()
+		metrics.ScheduledRefreshesTotal.WithLabelValues(j.name, "error").Inc()
+		logger.Logger.Warnf("Scheduled refresh failed, job: %s, error: %v", j.name, err)
+		return
+	}
+	end := start.Add(elapsed)
+	j.status.LastSuccess = &end
+	j.status.LastError = ""
+	metrics.ScheduledRefreshesTotal.WithLabelValues(j.name, "success").Inc()
+}
+
+// nextDelay returns interval shifted by a random amount in [-jitter, +jitter],
+// never less than half the interval.
+func (s *Scheduler) nextDelay(interval time.Duration) time.Duration {
+	if s.jitter <= 0 {
+		return interval
+	}
+	delay := interval + time.Duration(rand.Int64N(int64(2*s.jitter)+1)) - s.jitter
+	if delay < interval/2 {
+		delay = interval / 2
+	}
+	return delay
+}
+
+// Status returns a snapshot of every job, sorted by name.
+func (s *Scheduler) Status() []JobStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]JobStatus, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		out = append(out, j.status)
+	}
+	sort.Slice(out, func(i, k int) bool { return out[i].Name < out[k].Name })
+	return out
+}
@@ -0,0 +1,88 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Takenobou/thamestracker/internal/helpers/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	logger.InitLogger()
+	os.Exit(m.Run())
+}
+
+func TestScheduler_RunsImmediatelyAndOnInterval(t *testing.T) {
+	var runs int32
+	s := New(0)
+	s.Add("bridge", 20*time.Millisecond, func(context.Context) error {
+		atomic.AddInt32(&runs, 1)
+		return nil
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s.Start(ctx)
+
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(&runs) >= 3 }, time.Second, 5*time.Millisecond)
+	status := s.Status()
+	assert.Len(t, status, 1)
+	assert.Equal(t, "bridge", status[0].Name)
+	assert.NotNil(t, status[0].LastSuccess)
+	assert.Zero(t, status[0].Failures)
+}
+
+func TestScheduler_RecordsFailures(t *testing.T) {
+	s := New(0)
+	s.Add("vessels", time.Hour, func(context.Context) error { return errors.New("upstream down") })
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s.Start(ctx)
+
+	assert.Eventually(t, func() bool {
+		st := s.Status()[0]
+		return st.Runs == 1 && st.NextRun != nil
+	}, time.Second, 5*time.Millisecond)
+	st := s.Status()[0]
+	assert.Equal(t, 1, st.Failures)
+	assert.Equal(t, "upstream down", st.LastError)
+	assert.Nil(t, st.LastSuccess)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), *st.NextRun, time.Minute)
+}
+
+func TestScheduler_DisabledJobIgnored(t *testing.T) {
+	s := New(0)
+	s.Add("bridge", 0, func(context.Context) error { return nil })
+	assert.Empty(t, s.Status())
+}
+
+func TestScheduler_StopsOnCancel(t *testing.T) {
+	var runs int32
+	s := New(0)
+	s.Add("bridge", 10*time.Millisecond, func(context.Context) error {
+		atomic.AddInt32(&runs, 1)
+		return nil
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	s.Start(ctx)
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(&runs) >= 1 }, time.Second, time.Millisecond)
+	cancel()
+	time.Sleep(30 * time.Millisecond)
+	n := atomic.LoadInt32(&runs)
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, n, atomic.LoadInt32(&runs))
+}
+
+func TestNextDelay_StaysWithinJitter(t *testing.T) {
+	s := New(10 * time.Second)
+	for i := 0; i < 100; i++ {
+		d := s.nextDelay(time.Minute)
+		assert.GreaterOrEqual(t, d, 50*time.Second)
+		assert.LessOrEqual(t, d, 70*time.Second)
+	}
+	assert.Equal(t, time.Minute, New(0).nextDelay(time.Minute))
+}
@@ -1,126 +1,95 @@
 package bridge
 
 import (
+	"context"
 	"fmt"
-	"net/url"
-	"strconv"
-	"strings"
 	"time"
 
 	"github.com/Takenobou/thamestracker/internal/config"
+	"github.com/Takenobou/thamestracker/internal/helpers/httpclient"
 	"github.com/Takenobou/thamestracker/internal/helpers/logger"
-	"github.com/Takenobou/thamestracker/internal/helpers/utils"
 	"github.com/Takenobou/thamestracker/internal/models"
-	"github.com/gocolly/colly"
+	"github.com/Takenobou/thamestracker/internal/scraper/selectors"
+	"github.com/Takenobou/thamestracker/internal/validation"
 )
 
-// ScrapeBridgeLifts fetches upcoming bridge lift times as unified events.
-func ScrapeBridgeLifts() ([]models.Event, error) {
-	baseURL := config.AppConfig.URLs.TowerBridge
-	if baseURL == "" {
-		logger.Logger.Errorf("Tower Bridge URL is missing: set TOWER_BRIDGE environment variable")
-		return nil, fmt.Errorf("missing Tower Bridge URL")
+// DefaultDefinition describes the Tower Bridge lift times page at url. Override
+// it with TOWER_BRIDGE_DEFINITION when the page's markup changes.
+func DefaultDefinition(url string) selectors.Definition {
+	return selectors.Definition{
+		URL:  url,
+		Rows: "tbody tr",
+		Fields: map[string]selectors.Field{
+			"timestamp":   {Selector: "td:nth-child(3) time", Attr: "datetime"},
+			"vessel_name": {Selector: "td:nth-child(4)"},
+			"direction":   {Selector: "td:nth-child(5)"},
+		},
+		Category: "bridge",
+		Location: "Tower Bridge Road, London",
+		Pagination: &selectors.Pagination{
+			Pager: "nav.pager",
+			Next:  "nav.pager :has(a[title='Current page']) + * a",
+		},
 	}
-	logger.Logger.Infof("Fetching Tower Bridge lifts, url: %s", baseURL)
-
-	c := colly.NewCollector()
-	var events []models.Event
-	foundPager := false
-
-	c.OnHTML("tbody tr", func(e *colly.HTMLElement) {
-		rawTime := e.ChildAttr("td:nth-child(3) time", "datetime")
-		if rawTime == "" {
-			logger.Logger.Warnf("Missing datetime for vessel row, skipping")
-			return
-		}
-		tParsed, err := parseBridgeTimestamp(rawTime)
-		if err != nil {
-			logger.Logger.Errorf("Error parsing datetime %s: %v", rawTime, err)
-			return
-		}
-		vesselName := strings.TrimSpace(e.ChildText("td:nth-child(4)"))
-		direction := strings.TrimSpace(e.ChildText("td:nth-child(5)"))
-		event := models.Event{
-			Timestamp:  tParsed,
-			VesselName: vesselName,
-			Category:   "bridge",
-			Direction:  direction,
-			Location:   "Tower Bridge Road, London",
-		}
-		logger.Logger.Infof("Found lift event: vessel: %s, timestamp: %s, direction: %s",
-			vesselName, tParsed.Format(time.RFC3339), direction)
-		events = append(events, event)
-	})
+}
 
-	// Handle pagination.
-	c.OnHTML("nav.pager a[title='Current page']", func(e *colly.HTMLElement) {
-		foundPager = true
-		nextPage := e.DOM.Parent().Next().Find("a").AttrOr("href", "")
-		if nextPage != "" {
-			baseParsed, err := url.Parse(baseURL)
-			if err != nil {
-				return
-			}
-			nextParsed, err := url.Parse(nextPage)
-			if err != nil {
-				return
-			}
-			// If absolute URL, ensure same host
-			if nextParsed.IsAbs() && nextParsed.Host != baseParsed.Host {
-				logger.Logger.Warnf("Skipping external next page URL: %s", nextParsed)
-				return
-			}
-			// Resolve relative URL
-			safeURL := baseParsed.ResolveReference(nextParsed).String()
-			logger.Logger.Infof("Scraping next page, url: %s", safeURL)
-			c.Visit(safeURL)
-		}
-	})
+// ScrapeBridgeLifts fetches upcoming bridge lift times as unified events using
+// the built-in Tower Bridge definition and the configured URL. Every page
+// request is bound to ctx, so cancelling it aborts the scrape.
+func ScrapeBridgeLifts(ctx context.Context) ([]models.Event, error) {
+	return BridgeScraperImpl{URL: config.Get().URLs.TowerBridge}.ScrapeBridgeLifts(ctx)
+}
 
-	// Start scraping with retry
-	if err := utils.Retry(3, 500*time.Millisecond, func() error {
-		return c.Visit(baseURL)
-	}); err != nil {
-		logger.Logger.Errorf("Error scraping Tower Bridge lifts after retries: %v", err)
+// scrape scrapes def through client, recording the scrape's statistics in
+// tracker (if any) as "bridge".
+func scrape(ctx context.Context, def selectors.Definition, client httpclient.Client, tracker *validation.Tracker) ([]models.Event, error) {
+	if def.URL == "" {
+		logger.FromContext(ctx).Errorf("Tower Bridge URL is missing: set TOWER_BRIDGE environment variable")
+		return nil, fmt.Errorf("missing Tower Bridge URL")
+	}
+	logger.FromContext(ctx).Infof("Fetching Tower Bridge lifts, url: %s", def.URL)
+	events, stats, err := selectors.ScrapeWithStats(ctx, def, httpclient.AsTransport(client))
+	if err != nil {
+		logger.FromContext(ctx).Errorf("Error scraping Tower Bridge lifts after retries: %v", err)
 		return nil, err
 	}
-
-	c.Wait()
-	if !foundPager {
-		logger.Logger.Warnf("Bridge scraper: missing pagination link, structure may have changed")
+	tracker.Record("bridge", stats)
+	rows := logger.Rows(ctx)
+	for _, e := range events {
+		rows.Infow("Found lift event",
+			"vessel", e.VesselName, "timestamp", e.Timestamp.Format(time.RFC3339), "direction", e.Direction)
 	}
-	logger.Logger.Infof("Retrieved bridge lift events from API, count: %d", len(events))
+	logger.FromContext(ctx).Infof("Retrieved bridge lift events from API, count: %d", len(events))
 	return events, nil
 }
 
-// BridgeScraperImpl is a concrete implementation of service.BridgeScraper
-// that calls ScrapeBridgeLifts.
-type BridgeScraperImpl struct{}
-
-func (BridgeScraperImpl) ScrapeBridgeLifts() ([]models.Event, error) {
-	return ScrapeBridgeLifts()
+// BridgeScraperImpl is a concrete implementation of service.BridgeScraper.
+// Every page request is sent through Client (httpclient.DefaultClient if nil),
+// so wrapping it in a circuit breaker, timeout or user agent applies to the
+// scrape. URL is the lift times page. Definition, when set, replaces the
+// built-in Tower Bridge definition; its URL defaults to URL. Tracker, when
+// set, receives the statistics of every scrape.
+type BridgeScraperImpl struct {
+	Client     httpclient.Client
+	URL        string
+	Definition *selectors.Definition
+	Tracker    *validation.Tracker
 }
 
-// parseBridgeTimestamp supports both the current epoch-seconds format and
-// older ISO-like values returned by Tower Bridge pages.
-func parseBridgeTimestamp(raw string) (time.Time, error) {
-	raw = strings.TrimSpace(raw)
-	if raw == "" {
-		return time.Time{}, fmt.Errorf("empty datetime")
+func (b BridgeScraperImpl) ScrapeBridgeLifts(ctx context.Context) ([]models.Event, error) {
+	client := b.Client
+	if client == nil {
+		client = httpclient.DefaultClient
 	}
-
-	if epochSeconds, err := strconv.ParseInt(raw, 10, 64); err == nil {
-		return time.Unix(epochSeconds, 0).In(utils.LondonLocation), nil
+	if b.Definition == nil {
+		return scrape(ctx, DefaultDefinition(b.URL), client, b.Tracker)
 	}
-
-	if t, err := time.Parse(time.RFC3339, raw); err == nil {
-		return t.In(utils.LondonLocation), nil
+	def := *b.Definition
+	if def.URL == "" {
+		def.URL = b.URL
 	}
-
-	legacyISO := strings.TrimSuffix(raw, "Z")
-	t, err := time.ParseInLocation("2006-01-02T15:04:05", legacyISO, utils.LondonLocation)
-	if err != nil {
-		return time.Time{}, err
+	if def.Category == "" {
+		def.Category = "bridge"
 	}
-	return t, nil
+	return scrape(ctx, def, client, b.Tracker)
 }
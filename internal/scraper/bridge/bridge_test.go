@@ -1,8 +1,10 @@
 package bridge
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -10,9 +12,12 @@ import (
 	"os"
 
 	"github.com/Takenobou/thamestracker/internal/config"
+	"github.com/Takenobou/thamestracker/internal/fixtures"
+	"github.com/Takenobou/thamestracker/internal/helpers/httpclient"
 	"github.com/Takenobou/thamestracker/internal/helpers/logger"
-	"github.com/Takenobou/thamestracker/internal/models"
+	"github.com/Takenobou/thamestracker/internal/scraper/selectors"
 	"github.com/gocolly/colly"
+	"github.com/sony/gobreaker"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -41,53 +46,36 @@ const sampleBridgeHTML = `
 </html>
 `
 
+// setTowerBridgeURL points the scraper at url.
+func setTowerBridgeURL(url string) {
+	cfg := *config.Get()
+	cfg.URLs.TowerBridge = url
+	config.Set(cfg)
+}
+
 func TestMain(m *testing.M) {
 	logger.InitLogger()
 	os.Exit(m.Run())
 }
 
-func TestScrapeBridgeLifts(t *testing.T) {
-	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		_, _ = w.Write([]byte(sampleBridgeHTML))
-	}))
-	defer server.Close()
+// towerBridgeURL is the page the fixtures in testdata/fixtures were recorded from.
+const towerBridgeURL = "https://www.towerbridge.org.uk/flat/lift-times"
 
-	c := colly.NewCollector()
-	var events []models.Event
-
-	c.OnHTML("tbody tr", func(e *colly.HTMLElement) {
-		timeStr := e.ChildAttr("td:nth-child(3) time", "datetime")
-		timestamp, err := time.Parse(time.RFC3339, timeStr)
-		if timeStr == "" {
-			return // skip missing datetime
-		}
-		if err != nil {
-			return // skip parse error
-		}
-		vessel := e.ChildText("td:nth-child(4)")
-		direction := e.ChildText("td:nth-child(5)")
-		event := models.Event{
-			Timestamp:  timestamp,
-			VesselName: vessel,
-			Category:   "bridge",
-			Direction:  direction,
-			Location:   "Tower Bridge Road, London",
-		}
-		events = append(events, event)
-	})
-
-	err := c.Visit(server.URL)
+func TestScrapeBridgeLifts(t *testing.T) {
+	rp, err := fixtures.NewReplayer("../../../testdata/fixtures", false)
 	assert.NoError(t, err)
+	def := DefaultDefinition(towerBridgeURL)
 
-	assert.Len(t, events, 2, "expected 2 bridge lift events")
+	events, err := BridgeScraperImpl{Client: &http.Client{Transport: rp}, Definition: &def}.ScrapeBridgeLifts(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, events, 4, "expected the lifts from both recorded pages")
 	assert.Equal(t, "Paddle Steamer Dixie Queen", events[0].VesselName)
 	assert.Equal(t, "Up river", events[0].Direction)
 	assert.Equal(t, "Down river", events[1].Direction)
 	assert.Equal(t, "bridge", events[0].Category)
 	assert.Equal(t, "Tower Bridge Road, London", events[0].Location)
-	assert.Equal(t, 17, events[0].Timestamp.Hour())
-	assert.Equal(t, 45, events[0].Timestamp.Minute())
+	assert.Equal(t, time.Date(2025, 4, 5, 16, 45, 0, 0, time.UTC), events[0].Timestamp.UTC())
+	assert.Equal(t, "HMS Dauntless", events[3].VesselName, "the second page is followed")
 }
 
 func TestScrapeBridgeLifts_MissingDatetime(t *testing.T) {
@@ -133,39 +121,6 @@ func TestScrapeBridgeLifts_ParseError(t *testing.T) {
 	assert.True(t, called, "should skip row with parse error")
 }
 
-func TestScrapeBridgeLifts_Pagination(t *testing.T) {
-	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.URL.Path == "/" {
-			w.Write([]byte(`<html><body>
-				<table><tbody>
-					<tr><td>Sat</td><td></td><td><time datetime="2025-04-05T17:45:00Z"></time></td><td>Vessel1</td><td>Up river</td></tr>
-				</tbody></table>
-				<nav class="pager">
-					<span><a title="Current page"></a></span>
-					<span><a href="/page2">Next</a></span>
-				</nav>
-			</body></html>`))
-		} else if r.URL.Path == "/page2" {
-			w.Write([]byte(`<html><body>
-				<table><tbody>
-					<tr><td>Sat</td><td></td><td><time datetime="2025-04-05T18:45:00Z"></time></td><td>Vessel2</td><td>Down river</td></tr>
-				</tbody></table>
-			</body></html>`))
-		}
-	}))
-	defer server.Close()
-
-	oldURL := config.AppConfig.URLs.TowerBridge
-	config.AppConfig.URLs.TowerBridge = server.URL
-	defer func() { config.AppConfig.URLs.TowerBridge = oldURL }()
-
-	events, err := ScrapeBridgeLifts()
-	assert.NoError(t, err)
-	assert.Len(t, events, 2, "should collect events from both pages")
-	assert.Equal(t, "Vessel1", events[0].VesselName)
-	assert.Equal(t, "Vessel2", events[1].VesselName)
-}
-
 func TestScrapeBridgeLifts_VisitError(t *testing.T) {
 	c := colly.NewCollector()
 	err := c.Visit(":bad-url:")
@@ -186,35 +141,147 @@ func BenchmarkScrapeBridgeLifts(b *testing.B) {
 	}))
 	defer server.Close()
 
-	oldURL := config.AppConfig.URLs.TowerBridge
-	config.AppConfig.URLs.TowerBridge = server.URL
-	defer func() { config.AppConfig.URLs.TowerBridge = oldURL }()
+	old := *config.Get()
+	setTowerBridgeURL(server.URL)
+	defer config.Set(old)
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		_, err := ScrapeBridgeLifts()
+		_, err := ScrapeBridgeLifts(context.Background())
 		if err != nil {
 			b.Fatalf("unexpected error: %v", err)
 		}
 	}
 }
 
-func TestParseBridgeTimestamp_EpochSeconds(t *testing.T) {
-	ts, err := parseBridgeTimestamp("1776568500")
+func TestBridgeScraperImpl_CustomDefinition(t *testing.T) {
+	// the same lifts after a redesign that moved from a table to a list
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<html><body><ul class="lifts">
+			<li data-time="1743875100"><span class="vessel">Dixie Queen</span><span class="dir">Up river</span></li>
+		</ul></body></html>`))
+	}))
+	defer server.Close()
+
+	def := selectors.Definition{
+		Rows: "ul.lifts li",
+		Fields: map[string]selectors.Field{
+			"timestamp":   {Attr: "data-time"},
+			"vessel_name": {Selector: ".vessel"},
+			"direction":   {Selector: ".dir"},
+		},
+		Location: "Tower Bridge Road, London",
+	}
+	events, err := BridgeScraperImpl{URL: server.URL, Definition: &def}.ScrapeBridgeLifts(context.Background())
 	assert.NoError(t, err)
-	assert.Equal(t, 2026, ts.Year())
-	assert.Equal(t, time.April, ts.Month())
-	assert.Equal(t, 19, ts.Day())
-	assert.Equal(t, 4, ts.Hour())
-	assert.Equal(t, 15, ts.Minute())
+	assert.Len(t, events, 1)
+	assert.Equal(t, "Dixie Queen", events[0].VesselName)
+	assert.Equal(t, "bridge", events[0].Category)
+	assert.Equal(t, "Up river", events[0].Direction)
+	assert.Equal(t, time.Unix(1743875100, 0).Unix(), events[0].Timestamp.Unix())
 }
 
-func TestParseBridgeTimestamp_RFC3339(t *testing.T) {
-	ts, err := parseBridgeTimestamp("2025-04-05T17:45:00Z")
+func TestScrapeBridgeLifts_Cancelled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer server.Close()
+
+	old := *config.Get()
+	setTowerBridgeURL(server.URL)
+	defer config.Set(old)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := ScrapeBridgeLifts(ctx)
+	assert.Error(t, err)
+	assert.Less(t, time.Since(start), 2*time.Second)
+}
+
+func TestDocumentedDefinitionMatchesDefault(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(sampleBridgeHTML))
+	}))
+	defer server.Close()
+
+	def, err := selectors.Load("../../../docs/tower-bridge.yaml")
+	assert.NoError(t, err)
+	def.URL = server.URL
+	fromFile, err := selectors.Scrape(context.Background(), def, nil)
+	assert.NoError(t, err)
+	builtIn, err := selectors.Scrape(context.Background(), DefaultDefinition(server.URL), nil)
+	assert.NoError(t, err)
+	assert.Len(t, builtIn, 2)
+	assert.Equal(t, builtIn, fromFile)
+}
+
+func TestBridgeScraperImpl_RetriesServerErrors(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		_, _ = w.Write([]byte(sampleBridgeHTML))
+	}))
+	defer server.Close()
+
+	client := httpclient.NewBreakerClient("bridge", httpclient.DefaultClient, 5, 60)
+	events, err := BridgeScraperImpl{Client: client, URL: server.URL}.ScrapeBridgeLifts(context.Background())
 	assert.NoError(t, err)
-	assert.Equal(t, 2025, ts.Year())
-	assert.Equal(t, time.April, ts.Month())
-	assert.Equal(t, 5, ts.Day())
-	assert.Equal(t, 18, ts.Hour())
-	assert.Equal(t, 45, ts.Minute())
+	assert.Len(t, events, 2)
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestBridgeScraperImpl_CircuitBreaker(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	scraper := BridgeScraperImpl{Client: httpclient.NewBreakerClient("bridge", httpclient.DefaultClient, 2, 60), URL: server.URL}
+	_, err := scraper.ScrapeBridgeLifts(context.Background())
+	assert.ErrorIs(t, err, gobreaker.ErrOpenState, "the third attempt finds the breaker open")
+	assert.Equal(t, int32(2), calls.Load())
+
+	_, err = scraper.ScrapeBridgeLifts(context.Background())
+	assert.ErrorIs(t, err, gobreaker.ErrOpenState)
+	assert.Equal(t, int32(2), calls.Load(), "no requests while the breaker is open")
+}
+
+func TestBridgeScraperImpl_ClientSettings(t *testing.T) {
+	agents := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case agents <- r.UserAgent():
+		default:
+		}
+		if r.URL.Query().Get("slow") != "" {
+			time.Sleep(500 * time.Millisecond)
+		}
+		_, _ = w.Write([]byte(sampleBridgeHTML))
+	}))
+	defer server.Close()
+
+	client := httpclient.WithUserAgent(httpclient.DefaultClient, "thamestracker-test")
+	_, err := BridgeScraperImpl{Client: client, URL: server.URL}.ScrapeBridgeLifts(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "thamestracker-test", <-agents)
+
+	start := time.Now()
+	slow := &http.Client{Timeout: 50 * time.Millisecond}
+	_, err = BridgeScraperImpl{Client: slow, URL: server.URL + "?slow=1"}.ScrapeBridgeLifts(context.Background())
+	assert.Error(t, err, "the client's timeout applies to each page")
+	assert.Less(t, time.Since(start), 2*time.Second)
+}
+
+func TestBridgeScraperImpl_MissingURL(t *testing.T) {
+	_, err := BridgeScraperImpl{}.ScrapeBridgeLifts(context.Background())
+	assert.Error(t, err)
 }
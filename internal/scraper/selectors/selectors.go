@@ -0,0 +1,327 @@
+// Package selectors scrapes events from HTML pages described by a
+// selector-driven Definition, so a markup change upstream can be fixed in
+// configuration rather than code.
+package selectors
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Takenobou/thamestracker/internal/helpers/logger"
+	"github.com/Takenobou/thamestracker/internal/helpers/tracing"
+	"github.com/Takenobou/thamestracker/internal/helpers/utils"
+	"github.com/Takenobou/thamestracker/internal/models"
+	"github.com/Takenobou/thamestracker/internal/validation"
+	"github.com/gocolly/colly"
+	"go.opentelemetry.io/otel/attribute"
+	"gopkg.in/yaml.v3"
+)
+
+// Field locates one value within a row.
+type Field struct {
+	// Selector is relative to the row; empty selects the row itself.
+	Selector string `yaml:"selector" json:"selector"`
+	// Attr is the attribute to read; empty reads the element's text.
+	Attr string `yaml:"attr,omitempty" json:"attr,omitempty"`
+}
+
+// Pagination follows "next page" links.
+type Pagination struct {
+	// Pager matches the pagination block; a page without one is logged, as it
+	// usually means the markup has changed.
+	Pager string `yaml:"pager,omitempty" json:"pager,omitempty"`
+	// Next matches the link to the following page, if any.
+	Next string `yaml:"next" json:"next"`
+	// Attr holds the link target; defaults to href.
+	Attr string `yaml:"attr,omitempty" json:"attr,omitempty"`
+	// MaxPages bounds how many pages are visited; defaults to 20.
+	MaxPages int `yaml:"max_pages,omitempty" json:"max_pages,omitempty"`
+}
+
+// Definition describes how to turn an HTML page into events.
+type Definition struct {
+	URL string `yaml:"url,omitempty" json:"url,omitempty"`
+	// Rows matches one element per event.
+	Rows string `yaml:"rows" json:"rows"`
+	// Fields maps Event JSON field names (timestamp, vessel_name, direction,
+	// from, to, location, voyage_number, nationality) to their location in a row.
+	Fields map[string]Field `yaml:"fields" json:"fields"`
+	// TimestampFormats are tried in order: "unix" for epoch seconds, otherwise a
+	// Go time layout parsed in Timezone. Defaults to unix, RFC3339 and
+	// RFC3339 without a zone.
+	TimestampFormats []string `yaml:"timestamp_formats,omitempty" json:"timestamp_formats,omitempty"`
+	// Timezone defaults to Europe/London.
+	Timezone string `yaml:"timezone,omitempty" json:"timezone,omitempty"`
+	// Category and Location are set on every event; a location field, when
+	// defined and non-empty, takes precedence over Location.
+	Category   string      `yaml:"category,omitempty" json:"category,omitempty"`
+	Location   string      `yaml:"location,omitempty" json:"location,omitempty"`
+	Pagination *Pagination `yaml:"pagination,omitempty" json:"pagination,omitempty"`
+}
+
+// DefaultTimestampFormats are used when a definition lists none.
+var DefaultTimestampFormats = []string{"unix", time.RFC3339, "2006-01-02T15:04:05"}
+
+// knownFields are the Event fields a definition can populate.
+var knownFields = map[string]bool{"timestamp": true, "vessel_name": true, "direction": true,
+	"from": true, "to": true, "location": true, "voyage_number": true, "nationality": true}
+
+// Load reads a definition from a YAML or JSON file.
+func Load(path string) (Definition, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Definition{}, fmt.Errorf("reading scraper definition: %w", err)
+	}
+	var def Definition
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = json.Unmarshal(data, &def)
+	} else {
+		err = yaml.Unmarshal(data, &def)
+	}
+	if err != nil {
+		return Definition{}, fmt.Errorf("parsing scraper definition: %w", err)
+	}
+	if err := def.Validate(); err != nil {
+		return Definition{}, err
+	}
+	return def, nil
+}
+
+// Validate reports whether the definition can be scraped.
+func (d Definition) Validate() error {
+	if d.Rows == "" {
+		return fmt.Errorf("scraper definition: rows selector is required")
+	}
+	if _, ok := d.Fields["timestamp"]; !ok {
+		return fmt.Errorf("scraper definition: timestamp field is required")
+	}
+	if _, ok := d.Fields["vessel_name"]; !ok {
+		return fmt.Errorf("scraper definition: vessel_name field is required")
+	}
+	for name := range d.Fields {
+		if !knownFields[name] {
+			return fmt.Errorf("scraper definition: unknown field %q", name)
+		}
+	}
+	if d.Pagination != nil && d.Pagination.Next == "" {
+		return fmt.Errorf("scraper definition: pagination requires a next selector")
+	}
+	if _, err := d.location(); err != nil {
+		return fmt.Errorf("scraper definition: %w", err)
+	}
+	return nil
+}
+
+func (d Definition) location() (*time.Location, error) {
+	if d.Timezone == "" {
+		return utils.LondonLocation, nil
+	}
+	return time.LoadLocation(d.Timezone)
+}
+
+// ParseTimestamp parses raw with the first matching format, returning the
+// time in loc.
+func ParseTimestamp(raw string, formats []string, loc *time.Location) (time.Time, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return time.Time{}, fmt.Errorf("empty datetime")
+	}
+	if len(formats) == 0 {
+		formats = DefaultTimestampFormats
+	}
+	var lastErr error
+	for _, format := range formats {
+		if format == "unix" {
+			secs, err := strconv.ParseInt(raw, 10, 64)
+			if err != nil {
+				lastErr = err
+				continue
+			}
+			return time.Unix(secs, 0).In(loc), nil
+		}
+		t, err := time.ParseInLocation(format, raw, loc)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		return t.In(loc), nil
+	}
+	return time.Time{}, lastErr
+}
+
+// Scrape fetches def.URL and every following page, returning one event per
+// row. Rows without a name or a parseable timestamp are skipped. Every request
+// goes through transport (http.DefaultTransport if nil) bound to ctx.
+func Scrape(ctx context.Context, def Definition, transport http.RoundTripper) ([]models.Event, error) {
+	events, _, err := ScrapeWithStats(ctx, def, transport)
+	return events, err
+}
+
+// ScrapeWithStats is Scrape, also reporting the rows seen and skipped and
+// whether the pager was found.
+func ScrapeWithStats(ctx context.Context, def Definition, transport http.RoundTripper) ([]models.Event, validation.Stats, error) {
+	var stats validation.Stats
+	if def.URL == "" {
+		return nil, stats, fmt.Errorf("scraper definition has no url")
+	}
+	if err := def.Validate(); err != nil {
+		return nil, stats, err
+	}
+	loc, _ := def.location()
+	baseURL, err := url.Parse(def.URL)
+	if err != nil {
+		return nil, stats, fmt.Errorf("invalid url: %w", err)
+	}
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	c := colly.NewCollector()
+	// colly visits synchronously, so the transport can follow each retry attempt's context
+	tr := &contextTransport{ctx: ctx, base: transport}
+	c.WithTransport(tr)
+	// colly marks a URL visited before fetching it, which would turn every
+	// retry of the first page into ErrAlreadyVisited; pages are tracked below
+	c.AllowURLRevisit = true
+	visited := map[string]bool{def.URL: true}
+	var events []models.Event
+	pages, pagesWithPager := 0, 0
+
+	c.OnResponse(func(r *colly.Response) { pages++ })
+
+	rows := logger.Rows(ctx)
+	c.OnHTML(def.Rows, func(e *colly.HTMLElement) {
+		stats.RowsSeen++
+		values := make(map[string]string, len(def.Fields))
+		for name, f := range def.Fields {
+			values[name] = extract(e, f)
+		}
+		if values["timestamp"] == "" {
+			rows.Warnw("Missing datetime for row, skipping")
+			stats.Skip("missing_timestamp")
+			return
+		}
+		ts, err := ParseTimestamp(values["timestamp"], def.TimestampFormats, loc)
+		if err != nil {
+			rows.Errorw("Error parsing datetime for row, skipping", "datetime", values["timestamp"], "error", err)
+			stats.Skip("invalid_timestamp")
+			return
+		}
+		if values["vessel_name"] == "" {
+			rows.Warnw("Missing name for row, skipping")
+			stats.Skip("missing_name")
+			return
+		}
+		location := values["location"]
+		if location == "" {
+			location = def.Location
+		}
+		events = append(events, models.Event{
+			Timestamp:   ts,
+			VesselName:  values["vessel_name"],
+			Category:    def.Category,
+			VoyageNo:    values["voyage_number"],
+			Nationality: values["nationality"],
+			Direction:   values["direction"],
+			From:        values["from"],
+			To:          values["to"],
+			Location:    location,
+		})
+	})
+
+	if p := def.Pagination; p != nil {
+		attr := p.Attr
+		if attr == "" {
+			attr = "href"
+		}
+		maxPages := p.MaxPages
+		if maxPages <= 0 {
+			maxPages = 20
+		}
+		if p.Pager != "" {
+			c.OnHTML(p.Pager, func(e *colly.HTMLElement) { pagesWithPager++ })
+		}
+		c.OnHTML(p.Next, func(e *colly.HTMLElement) {
+			next := e.Attr(attr)
+			if next == "" || pages >= maxPages {
+				return
+			}
+			nextParsed, err := url.Parse(next)
+			if err != nil {
+				return
+			}
+			// If absolute URL, ensure same host
+			if nextParsed.IsAbs() && nextParsed.Host != baseURL.Host {
+				logger.FromContext(ctx).Warnf("Skipping external next page URL: %s", nextParsed)
+				return
+			}
+			// Resolve relative URL
+			safeURL := e.Request.URL.ResolveReference(nextParsed).String()
+			if visited[safeURL] {
+				return
+			}
+			visited[safeURL] = true
+			logger.FromContext(ctx).Infof("Scraping next page, url: %s", safeURL)
+			c.Visit(safeURL)
+		})
+	}
+
+	// Start scraping with retry
+	if err := utils.Retry(ctx, 3, 500*time.Millisecond, func(ctx context.Context) error {
+		tr.ctx = ctx
+		return c.Visit(def.URL)
+	}); err != nil {
+		return nil, stats, err
+	}
+
+	c.Wait()
+	if err := ctx.Err(); err != nil {
+		// later pages were aborted, so the result would be incomplete
+		return nil, stats, err
+	}
+	if def.Pagination != nil && def.Pagination.Pager != "" && pagesWithPager == 0 {
+		logger.FromContext(ctx).Warnf("Scraper: missing pagination on %s, structure may have changed", def.URL)
+		stats.Missing = append(stats.Missing, "pager")
+	}
+	stats.Events = len(events)
+	return events, stats, nil
+}
+
+// extract reads one field from a row.
+func extract(e *colly.HTMLElement, f Field) string {
+	if f.Selector == "" {
+		if f.Attr != "" {
+			return strings.TrimSpace(e.Attr(f.Attr))
+		}
+		return strings.TrimSpace(e.Text)
+	}
+	if f.Attr != "" {
+		return strings.TrimSpace(e.ChildAttr(f.Selector, f.Attr))
+	}
+	return strings.TrimSpace(e.ChildText(f.Selector))
+}
+
+// contextTransport binds every request colly makes to ctx, since colly v1
+// has no context support of its own, and traces each page visit.
+type contextTransport struct {
+	ctx  context.Context
+	base http.RoundTripper
+}
+
+func (t *contextTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, span := tracing.Start(t.ctx, "scraper.visit", attribute.String("url.full", req.URL.String()))
+	resp, err := t.base.RoundTrip(req.WithContext(ctx))
+	if err == nil {
+		span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+	}
+	tracing.End(span, err)
+	return resp, err
+}
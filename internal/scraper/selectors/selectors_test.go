@@ -0,0 +1,195 @@
+package selectors
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Takenobou/thamestracker/internal/helpers/logger"
+	"github.com/Takenobou/thamestracker/internal/helpers/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	logger.InitLogger()
+	os.Exit(m.Run())
+}
+
+const definitionYAML = `
+rows: "table.schedule tr.lift"
+fields:
+  timestamp:
+    selector: "td.when"
+  vessel_name:
+    selector: "td.vessel"
+  direction:
+    selector: "td.vessel"
+    attr: "data-direction"
+timestamp_formats: ["02/01/2006 15:04"]
+category: bridge
+location: "Albert Bridge, London"
+pagination:
+  pager: "div.pages"
+  next: "div.pages a.next"
+  max_pages: 2
+`
+
+func TestLoad_YAMLAndJSON(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "albert.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte(definitionYAML), 0o644))
+	def, err := Load(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "table.schedule tr.lift", def.Rows)
+	assert.Equal(t, "data-direction", def.Fields["direction"].Attr)
+	assert.Equal(t, 2, def.Pagination.MaxPages)
+
+	path = filepath.Join(dir, "albert.json")
+	assert.NoError(t, os.WriteFile(path, []byte(`{"rows": "tr", "fields": {"timestamp": {"selector": "time", "attr": "datetime"}, "vessel_name": {"selector": "td"}}}`), 0o644))
+	def, err = Load(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "datetime", def.Fields["timestamp"].Attr)
+}
+
+func TestValidate(t *testing.T) {
+	valid := Definition{Rows: "tr", Fields: map[string]Field{"timestamp": {}, "vessel_name": {}}}
+	assert.NoError(t, valid.Validate())
+
+	missingRows := valid
+	missingRows.Rows = ""
+	assert.ErrorContains(t, missingRows.Validate(), "rows")
+
+	unknown := valid
+	unknown.Fields = map[string]Field{"timestamp": {}, "vessel_name": {}, "colour": {}}
+	assert.ErrorContains(t, unknown.Validate(), "colour")
+
+	badZone := valid
+	badZone.Timezone = "Mars/Olympus"
+	assert.Error(t, badZone.Validate())
+
+	noNext := valid
+	noNext.Pagination = &Pagination{Pager: "nav"}
+	assert.ErrorContains(t, noNext.Validate(), "next")
+}
+
+func TestScrape_FollowsPagesUpToMax(t *testing.T) {
+	page := func(n int, next string) string {
+		return fmt.Sprintf(`<html><body><table class="schedule">
+			<tr class="lift"><td class="when">05/04/2025 1%d:00</td><td class="vessel" data-direction="Up river">Vessel%d</td></tr>
+			<tr class="lift"><td class="when">soon</td><td class="vessel">Unparseable</td></tr>
+			<tr class="lift"><td class="when">05/04/2025 1%d:30</td><td class="vessel"></td></tr>
+		</table><div class="pages"><a class="next" href="%s">Next</a></div></body></html>`, n, n, n, next)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			w.Write([]byte(page(1, "/p2")))
+		case "/p2":
+			w.Write([]byte(page(2, "/p3")))
+		default:
+			w.Write([]byte(page(3, "/p4")))
+		}
+	}))
+	defer server.Close()
+
+	def, err := Load(writeFile(t, definitionYAML))
+	assert.NoError(t, err)
+	def.URL = server.URL
+	events, stats, err := ScrapeWithStats(context.Background(), def, nil)
+	assert.NoError(t, err)
+	assert.Len(t, events, 2, "unparseable and nameless rows are skipped; max_pages stops at page 2")
+	assert.Equal(t, 6, stats.RowsSeen)
+	assert.Equal(t, 2, stats.Events)
+	assert.Equal(t, map[string]int{"invalid_timestamp": 2, "missing_name": 2}, stats.Skipped)
+	assert.Empty(t, stats.Missing)
+	assert.Equal(t, "Vessel1", events[0].VesselName)
+	assert.Equal(t, "Up river", events[0].Direction)
+	assert.Equal(t, "bridge", events[0].Category)
+	assert.Equal(t, "Albert Bridge, London", events[0].Location)
+	// BST: 11:00 London is 10:00 UTC
+	assert.Equal(t, time.Date(2025, 4, 5, 10, 0, 0, 0, time.UTC), events[0].Timestamp.UTC())
+}
+
+func TestScrape_SkipsExternalNextPage(t *testing.T) {
+	var hits int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		w.Write([]byte(`<table><tr><td><time datetime="2025-04-05T17:45:00Z"></time></td><td>V</td></tr></table>
+			<nav><a class="next" href="https://example.com/page2">Next</a></nav>`))
+	}))
+	defer server.Close()
+
+	def := Definition{
+		URL:  server.URL,
+		Rows: "tr",
+		Fields: map[string]Field{
+			"timestamp":   {Selector: "time", Attr: "datetime"},
+			"vessel_name": {Selector: "td:nth-child(2)"},
+		},
+		Pagination: &Pagination{Next: "nav a.next"},
+	}
+	events, err := Scrape(context.Background(), def, nil)
+	assert.NoError(t, err)
+	assert.Len(t, events, 1)
+	assert.Equal(t, 1, hits)
+}
+
+func TestScrapeWithStats_MissingPager(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<table><tr><td><time datetime="2025-04-05T17:45:00Z"></time></td><td>V</td></tr></table>`))
+	}))
+	defer server.Close()
+
+	def := Definition{
+		URL:  server.URL,
+		Rows: "tr",
+		Fields: map[string]Field{
+			"timestamp":   {Selector: "time", Attr: "datetime"},
+			"vessel_name": {Selector: "td:nth-child(2)"},
+		},
+		Pagination: &Pagination{Pager: "nav.pager", Next: "nav.pager a.next"},
+	}
+	_, stats, err := ScrapeWithStats(context.Background(), def, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"pager"}, stats.Missing)
+}
+
+func TestParseTimestamp_EpochSeconds(t *testing.T) {
+	ts, err := ParseTimestamp("1776568500", nil, utils.LondonLocation)
+	assert.NoError(t, err)
+	assert.Equal(t, 2026, ts.Year())
+	assert.Equal(t, time.April, ts.Month())
+	assert.Equal(t, 19, ts.Day())
+	assert.Equal(t, 4, ts.Hour())
+	assert.Equal(t, 15, ts.Minute())
+}
+
+func TestParseTimestamp_RFC3339(t *testing.T) {
+	ts, err := ParseTimestamp("2025-04-05T17:45:00Z", nil, utils.LondonLocation)
+	assert.NoError(t, err)
+	assert.Equal(t, 2025, ts.Year())
+	assert.Equal(t, time.April, ts.Month())
+	assert.Equal(t, 5, ts.Day())
+	assert.Equal(t, 18, ts.Hour())
+	assert.Equal(t, 45, ts.Minute())
+}
+
+func TestParseTimestamp_NoZone(t *testing.T) {
+	ts, err := ParseTimestamp("2025-04-05T17:45:00", nil, utils.LondonLocation)
+	assert.NoError(t, err)
+	assert.Equal(t, 17, ts.Hour())
+	_, err = ParseTimestamp("tomorrow", nil, utils.LondonLocation)
+	assert.Error(t, err)
+}
+
+func writeFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "def.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	return path
+}
@@ -1,9 +1,14 @@
 package vessels
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"reflect"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/Takenobou/thamestracker/internal/config"
@@ -11,6 +16,7 @@ import (
 	"github.com/Takenobou/thamestracker/internal/helpers/logger"
 	"github.com/Takenobou/thamestracker/internal/helpers/utils"
 	"github.com/Takenobou/thamestracker/internal/models"
+	"github.com/Takenobou/thamestracker/internal/validation"
 )
 
 // apiResponse represents the API response structure.
@@ -36,62 +42,80 @@ type vesselData struct {
 }
 
 // ScrapeVessels fetches vessel data as unified events based on the type (arrivals, departures, inport, forecast).
-func ScrapeVessels(vesselType string) ([]models.Event, error) {
-	return scrapeVesselsWithClient(httpclient.DefaultClient, vesselType)
+func ScrapeVessels(ctx context.Context, vesselType string) ([]models.Event, error) {
+	return scrapeVesselsWithClient(ctx, httpclient.DefaultClient, vesselType, nil)
 }
 
-func scrapeVesselsWithClient(client httpclient.Client, vesselType string) ([]models.Event, error) {
+// scrapeVesselsWithClient scrapes vesselType, recording statistics about the
+// lists it covers in tracker (if any) as "vessels", or "vessels:<type>" for a
+// single list so that each scope is compared with its own history.
+func scrapeVesselsWithClient(ctx context.Context, client httpclient.Client, vesselType string, tracker *validation.Tracker) ([]models.Event, error) {
+	switch vesselType {
+	case "all", "inport", "arrivals", "departures", "forecast":
+	default:
+		return nil, fmt.Errorf("invalid vesselType: %s", vesselType)
+	}
 	if client == nil {
 		client = httpclient.DefaultClient
 	}
 
-	apiURL := config.AppConfig.URLs.PortOfLondon
+	apiURL := config.Get().URLs.PortOfLondon
 	if apiURL == "" {
-		logger.Logger.Errorf("Port of London API URL is missing: set PORT_OF_LONDON environment variable")
+		logger.FromContext(ctx).Errorf("Port of London API URL is missing: set PORT_OF_LONDON environment variable")
 		return nil, fmt.Errorf("missing api url")
 	}
-	logger.Logger.Infof("Fetching vessels from API, url: %s, vesselType: %s", apiURL, vesselType)
+	logger.FromContext(ctx).Infof("Fetching vessels from API, url: %s, vesselType: %s", apiURL, vesselType)
 
 	var resp *http.Response
-	err := utils.Retry(3, 500*time.Millisecond, func() error {
-		r, e := client.Get(apiURL)
+	err := utils.Retry(ctx, 3, 500*time.Millisecond, func(ctx context.Context) error {
+		r, e := httpclient.Get(ctx, client, apiURL)
 		if e != nil {
-			logger.Logger.Warnf("Fetch failed: %v", e)
+			logger.FromContext(ctx).Warnf("Fetch failed: %v", e)
 			return e
 		}
 		if r.StatusCode >= http.StatusInternalServerError {
 			r.Body.Close()
 			e = fmt.Errorf("server error %d", r.StatusCode)
-			logger.Logger.Warnf("Fetch failed: %v", e)
+			logger.FromContext(ctx).Warnf("Fetch failed: %v", e)
 			return e
 		}
 		resp = r
 		return nil
 	})
 	if err != nil {
-		logger.Logger.Errorf("Error fetching vessels after retries: %v", err)
+		logger.FromContext(ctx).Errorf("Error fetching vessels after retries: %v", err)
 		return nil, err
 	}
 	defer resp.Body.Close()
 
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		logger.FromContext(ctx).Errorf("Error reading API response: %v", err)
+		return nil, err
+	}
 	var result apiResponse
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		logger.Logger.Errorf("Error decoding API response: %v", err)
+	if err := json.Unmarshal(body, &result); err != nil {
+		logger.FromContext(ctx).Errorf("Error decoding API response: %v", err)
 		return nil, err
 	}
-	logger.Logger.Infof("PLA vessels fetched: inport=%d arrivals=%d departures=%d forecast=%d url=%s",
+	logger.FromContext(ctx).Infof("PLA vessels fetched: inport=%d arrivals=%d departures=%d forecast=%d url=%s",
 		len(result.InPort), len(result.Arrivals), len(result.Departures), len(result.Forecast), apiURL)
 
-	events := make([]models.Event, 0)
+	stats := validation.Stats{UnknownFields: unknownFields(body)}
+	byCategory := make(map[string][]models.Event, 4)
 
+	rows := logger.Rows(ctx)
 	processVessels := func(vesselList []vesselData, category string) {
 		for _, item := range vesselList {
+			stats.RowsSeen++
 			if item.VesselName == "" {
-				logger.Logger.Warnf("Missing vessel name in category %s, skipping", category)
+				rows.Warnw("Missing vessel name, skipping", "category", category)
+				stats.Skip("missing_name")
 				continue
 			}
 			if item.Visit == "" && category != "forecast" {
-				logger.Logger.Warnf("Missing voyage number for vessel %s, skipping", item.VesselName)
+				rows.Warnw("Missing voyage number, skipping", "vessel", item.VesselName)
+				stats.Skip("missing_voyage")
 				continue
 			}
 
@@ -106,7 +130,8 @@ func scrapeVesselsWithClient(client httpclient.Client, vesselType string) ([]mod
 			}
 			tParsed, ok := parseVesselTimestamp(ts, item.LastUpdated)
 			if !ok {
-				logger.Logger.Warnf("Missing/invalid timestamp for vessel %s in category %s, skipping", item.VesselName, category)
+				rows.Warnw("Missing/invalid timestamp, skipping", "vessel", item.VesselName, "category", category)
+				stats.Skip("invalid_timestamp")
 				continue
 			}
 
@@ -120,47 +145,97 @@ func scrapeVesselsWithClient(client httpclient.Client, vesselType string) ([]mod
 				To:          item.LocationTo,
 				Location:    item.LocationName,
 			}
-			events = append(events, event)
+			byCategory[category] = append(byCategory[category], event)
 		}
 	}
 
-	if vesselType == "all" {
-		processVessels(result.InPort, "inport")
-		processVessels(result.Arrivals, "arrivals")
-		processVessels(result.Departures, "departures")
-		processVessels(result.Forecast, "forecast")
-	} else {
-		var vesselList []vesselData
-		switch vesselType {
-		case "inport":
-			vesselList = result.InPort
-		case "arrivals":
-			vesselList = result.Arrivals
-		case "departures":
-			vesselList = result.Departures
-		case "forecast":
-			vesselList = result.Forecast
-		default:
-			return nil, fmt.Errorf("invalid vesselType: %s", vesselType)
-		}
-		processVessels(vesselList, vesselType)
+	// only the requested lists are checked, so rows of the others are neither
+	// logged nor counted as skipped
+	lists := map[string][]vesselData{
+		"inport":     result.InPort,
+		"arrivals":   result.Arrivals,
+		"departures": result.Departures,
+		"forecast":   result.Forecast,
 	}
+	categories := []string{"inport", "arrivals", "departures", "forecast"}
+	source := "vessels"
+	if vesselType != "all" {
+		categories = []string{vesselType}
+		source += ":" + vesselType
+	}
+	events := make([]models.Event, 0)
+	for _, category := range categories {
+		processVessels(lists[category], category)
+		events = append(events, byCategory[category]...)
+	}
+	stats.Events = len(events)
+	tracker.Record(source, stats)
 
-	logger.Logger.Infof("Retrieved vessel events from API, count: %d, vesselType: %s", len(events), vesselType)
+	logger.FromContext(ctx).Infof("Retrieved vessel events from API, count: %d, vesselType: %s", len(events), vesselType)
 	return events, nil
 }
 
-// VesselScraperImpl implements service.VesselScraper
+// unknownFields lists fields in the raw response that apiResponse and
+// vesselData do not decode, as "key" for top-level keys and "list.key" for
+// keys of list items.
+func unknownFields(body []byte) []string {
+	var top map[string]json.RawMessage
+	if err := json.Unmarshal(body, &top); err != nil {
+		return nil
+	}
+	known := jsonNames(reflect.TypeOf(vesselData{}))
+	seen := make(map[string]bool)
+	for key, raw := range top {
+		if !topLevelFields[key] {
+			seen[key] = true
+			continue
+		}
+		var items []map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &items); err != nil {
+			continue
+		}
+		for _, item := range items {
+			for field := range item {
+				if !known[field] {
+					seen[key+"."+field] = true
+				}
+			}
+		}
+	}
+	out := make([]string, 0, len(seen))
+	for f := range seen {
+		out = append(out, f)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// topLevelFields are the lists decoded into apiResponse.
+var topLevelFields = jsonNames(reflect.TypeOf(apiResponse{}))
+
+// jsonNames returns the JSON field names of struct type t.
+func jsonNames(t reflect.Type) map[string]bool {
+	names := make(map[string]bool, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		names[name] = true
+	}
+	return names
+}
+
+// VesselScraperImpl implements service.VesselScraper. Tracker, when set,
+// receives the statistics of every scrape.
 type VesselScraperImpl struct {
-	Client httpclient.Client
+	Client  httpclient.Client
+	Tracker *validation.Tracker
 }
 
-func (v VesselScraperImpl) ScrapeVessels(vesselType string) ([]models.Event, error) {
+func (v VesselScraperImpl) ScrapeVessels(ctx context.Context, vesselType string) ([]models.Event, error) {
 	client := v.Client
 	if client == nil {
 		client = httpclient.DefaultClient
 	}
-	return scrapeVesselsWithClient(client, vesselType)
+	return scrapeVesselsWithClient(ctx, client, vesselType, v.Tracker)
 }
 
 func parseVesselTimestamp(raw string, fallback string) (time.Time, bool) {
@@ -1,15 +1,19 @@
 package vessels_test
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/Takenobou/thamestracker/internal/config"
+	"github.com/Takenobou/thamestracker/internal/fixtures"
 	"github.com/Takenobou/thamestracker/internal/helpers/logger"
 	"github.com/Takenobou/thamestracker/internal/scraper/vessels"
+	"github.com/Takenobou/thamestracker/internal/validation"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -17,58 +21,33 @@ func init() {
 	logger.InitLogger()
 }
 
-const mockAPIResponse = `{
-	"inport": [
-		{
-			"location_name": "WOODS QUAY",
-			"vessel_name": "SILVER STURGEON",
-			"visit": "S7670",
-			"last_rep_dt": "2025-01-25 20:33:47.150"
-		}
-	],
-	"arrivals": [
-		{
-			"location_from": "MAPTM",
-			"location_to": "LONDON GATEWAY1",
-			"vessel_name": "SAN NICOLAS MAERSK",
-			"visit": "S7795",
-			"last_rep_dt": "2025-03-13 14:22:09.300"
-		}
-	],
-	"departures": [
-		{
-			"location_from": "TILBURY DOCK",
-			"location_to": "SESOE",
-			"vessel_name": "FRISIAN SPRING",
-			"visit": "F1785",
-			"first_rep_dt": "2025-03-13 15:39:03.690"
-		}
-	],
-	"forecast": [
-		{
-			"location_from": "NLVLI",
-			"location_to": "FORDS JETTY",
-			"vessel_name": "ADELINE",
-			"visit": "A9999",
-			"etad_dt": "2025-03-14 14:15:00.000"
-		}
-	]
-}`
+// setPLAURL points the scraper at url.
+func setPLAURL(url string) {
+	cfg := *config.Get()
+	cfg.URLs.PortOfLondon = url
+	config.Set(cfg)
+}
 
 func TestScrapeVessels_AllTypes(t *testing.T) {
-	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte(mockAPIResponse))
-	}))
-	defer server.Close()
-	config.AppConfig.URLs.PortOfLondon = server.URL
+	rp, err := fixtures.NewReplayer("../../../testdata/fixtures", false)
+	assert.NoError(t, err)
+	// the URL the fixtures were recorded from
+	setPLAURL("https://pla.co.uk/pla-proxy/five-minute?url=ships/lists")
+	scraper := vessels.VesselScraperImpl{Client: &http.Client{Transport: rp}}
 
-	types := []string{"inport", "arrivals", "departures", "forecast", "all"}
-	for _, typ := range types {
-		events, err := vessels.ScrapeVessels(typ)
+	want := map[string]int{"inport": 2, "arrivals": 1, "departures": 1, "forecast": 1, "all": 5}
+	for typ, n := range want {
+		events, err := scraper.ScrapeVessels(context.Background(), typ)
 		assert.NoError(t, err, typ)
-		assert.NotEmpty(t, events, typ)
+		assert.Len(t, events, n, typ)
 	}
+
+	events, _ := scraper.ScrapeVessels(context.Background(), "departures")
+	assert.Equal(t, "FRISIAN SPRING", events[0].VesselName)
+	assert.Equal(t, "F1785", events[0].VoyageNo)
+	assert.Equal(t, "TILBURY DOCK", events[0].From)
+	assert.Equal(t, "SESOE", events[0].To)
+	assert.Equal(t, "2025-04-05 15:39", events[0].Timestamp.Format("2006-01-02 15:04"))
 }
 
 func TestScrapeVessels_HTTPError(t *testing.T) {
@@ -76,8 +55,8 @@ func TestScrapeVessels_HTTPError(t *testing.T) {
 		w.WriteHeader(http.StatusInternalServerError)
 	}))
 	defer server.Close()
-	config.AppConfig.URLs.PortOfLondon = server.URL
-	_, err := vessels.ScrapeVessels("inport")
+	setPLAURL(server.URL)
+	_, err := vessels.ScrapeVessels(context.Background(), "inport")
 	assert.Error(t, err)
 }
 
@@ -87,8 +66,8 @@ func TestScrapeVessels_InvalidJSON(t *testing.T) {
 		w.Write([]byte("not json"))
 	}))
 	defer server.Close()
-	config.AppConfig.URLs.PortOfLondon = server.URL
-	_, err := vessels.ScrapeVessels("inport")
+	setPLAURL(server.URL)
+	_, err := vessels.ScrapeVessels(context.Background(), "inport")
 	assert.Error(t, err)
 }
 
@@ -99,8 +78,8 @@ func TestScrapeVessels_MissingVesselName(t *testing.T) {
 		w.Write([]byte(badJSON))
 	}))
 	defer server.Close()
-	config.AppConfig.URLs.PortOfLondon = server.URL
-	events, err := vessels.ScrapeVessels("inport")
+	setPLAURL(server.URL)
+	events, err := vessels.ScrapeVessels(context.Background(), "inport")
 	assert.NoError(t, err)
 	assert.Empty(t, events)
 }
@@ -112,15 +91,15 @@ func TestScrapeVessels_MissingVoyageNo(t *testing.T) {
 		w.Write([]byte(badJSON))
 	}))
 	defer server.Close()
-	config.AppConfig.URLs.PortOfLondon = server.URL
-	events, err := vessels.ScrapeVessels("inport")
+	setPLAURL(server.URL)
+	events, err := vessels.ScrapeVessels(context.Background(), "inport")
 	assert.NoError(t, err)
 	assert.Empty(t, events)
 }
 
 func TestScrapeVessels_InvalidType(t *testing.T) {
-	config.AppConfig.URLs.PortOfLondon = "http://example.com"
-	_, err := vessels.ScrapeVessels("notatype")
+	setPLAURL("http://example.com")
+	_, err := vessels.ScrapeVessels(context.Background(), "notatype")
 	assert.Error(t, err)
 }
 
@@ -131,8 +110,8 @@ func TestScrapeVessels_EmptyLists(t *testing.T) {
 		w.Write([]byte(emptyJSON))
 	}))
 	defer server.Close()
-	config.AppConfig.URLs.PortOfLondon = server.URL
-	events, err := vessels.ScrapeVessels("all")
+	setPLAURL(server.URL)
+	events, err := vessels.ScrapeVessels(context.Background(), "all")
 	assert.NoError(t, err)
 	assert.Empty(t, events)
 }
@@ -144,8 +123,8 @@ func TestScrapeVessels_TimestampFallbackToLastUpdated(t *testing.T) {
 		w.Write([]byte(badTimeJSON))
 	}))
 	defer server.Close()
-	config.AppConfig.URLs.PortOfLondon = server.URL
-	events, err := vessels.ScrapeVessels("inport")
+	setPLAURL(server.URL)
+	events, err := vessels.ScrapeVessels(context.Background(), "inport")
 	assert.NoError(t, err)
 	assert.Len(t, events, 1)
 	assert.Equal(t, 2025, events[0].Timestamp.Year())
@@ -162,8 +141,8 @@ func TestScrapeVessels_TimestampInvalidAndNoFallbackSkipsRow(t *testing.T) {
 		w.Write([]byte(badTimeJSON))
 	}))
 	defer server.Close()
-	config.AppConfig.URLs.PortOfLondon = server.URL
-	events, err := vessels.ScrapeVessels("inport")
+	setPLAURL(server.URL)
+	events, err := vessels.ScrapeVessels(context.Background(), "inport")
 	assert.NoError(t, err)
 	assert.Empty(t, events)
 }
@@ -184,13 +163,75 @@ func BenchmarkScrapeVessels(b *testing.B) {
 		w.Write([]byte(vesselsJSON))
 	}))
 	defer server.Close()
-	config.AppConfig.URLs.PortOfLondon = server.URL
+	setPLAURL(server.URL)
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		_, err := vessels.ScrapeVessels("inport")
+		_, err := vessels.ScrapeVessels(context.Background(), "inport")
 		if err != nil {
 			b.Fatalf("unexpected error: %v", err)
 		}
 	}
 }
+
+func TestScrapeVessels_CancelStopsRetries(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+	setPLAURL(server.URL)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := vessels.ScrapeVessels(ctx, "inport")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Second, "should not wait out the retry backoff")
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestScrapeVessels_RecordsValidationStats(t *testing.T) {
+	body := `{
+		"inport": [
+			{"vessel_name": "SILVER STURGEON", "visit": "S7670", "last_rep_dt": "2025-01-25 20:33:47.150", "berth_code": "WQ1"},
+			{"visit": "S0001", "last_rep_dt": "2025-01-25 20:33:47.150"}
+		],
+		"arrivals": [
+			{"vessel_name": "SAN NICOLAS MAERSK", "last_rep_dt": "2025-03-13 14:22:09.300"}
+		],
+		"anchorages": []
+	}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+	setPLAURL(server.URL)
+
+	tracker := validation.NewTracker(10)
+	events, err := vessels.VesselScraperImpl{Tracker: tracker}.ScrapeVessels(context.Background(), "arrivals")
+	assert.NoError(t, err)
+	assert.Empty(t, events)
+
+	status := tracker.Status()
+	assert.Len(t, status, 1)
+	assert.Equal(t, "vessels:arrivals", status[0].Source)
+	last := status[0].Last
+	assert.Equal(t, 1, last.RowsSeen, "only the requested list is counted")
+	assert.Equal(t, 0, last.Events)
+	assert.Equal(t, map[string]int{"missing_voyage": 1}, last.Skipped)
+	assert.Equal(t, []string{"anchorages", "inport.berth_code"}, last.UnknownFields)
+	assert.True(t, status[0].Degraded, "new upstream fields change the shape")
+
+	events, err = vessels.VesselScraperImpl{Tracker: tracker}.ScrapeVessels(context.Background(), "all")
+	assert.NoError(t, err)
+	assert.Len(t, events, 1)
+	status = tracker.Status()
+	assert.Len(t, status, 2)
+	assert.Equal(t, "vessels", status[0].Source)
+	last = status[0].Last
+	assert.Equal(t, 3, last.RowsSeen)
+	assert.Equal(t, 1, last.Events)
+	assert.Equal(t, map[string]int{"missing_name": 1, "missing_voyage": 1}, last.Skipped)
+}
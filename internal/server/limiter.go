@@ -0,0 +1,54 @@
+package server
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/limiter"
+)
+
+// rateLimiter is the per-IP rate limiter. Fiber's limiter has a fixed limit,
+// so changing it swaps in a new one, which starts counting afresh.
+type rateLimiter struct {
+	mu      sync.Mutex // serialises SetMax
+	max     int
+	handler atomic.Pointer[fiber.Handler]
+}
+
+func newRateLimiter(max int) *rateLimiter {
+	l := &rateLimiter{}
+	l.set(max)
+	return l
+}
+
+// Handle applies the current limit to c.
+func (l *rateLimiter) Handle(c *fiber.Ctx) error {
+	return (*l.handler.Load())(c)
+}
+
+// SetMax changes the number of requests allowed per IP per minute.
+func (l *rateLimiter) SetMax(max int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if max != l.max {
+		l.set(max)
+	}
+}
+
+func (l *rateLimiter) set(max int) {
+	h := limiter.New(limiter.Config{
+		Max:        max,
+		Expiration: time.Minute,
+		KeyGenerator: func(c *fiber.Ctx) string {
+			return c.IP()
+		},
+		LimitReached: func(c *fiber.Ctx) error {
+			return c.Status(fiber.StatusTooManyRequests).
+				JSON(fiber.Map{"error": "Rate limit exceeded"})
+		},
+	})
+	l.max = max
+	l.handler.Store(&h)
+}
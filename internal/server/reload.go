@@ -0,0 +1,99 @@
+package server
+
+import (
+	"os"
+	"time"
+
+	"github.com/Takenobou/thamestracker/internal/config"
+	"github.com/Takenobou/thamestracker/internal/helpers/logger"
+	"github.com/Takenobou/thamestracker/internal/helpers/metrics"
+)
+
+// Reload loads the configuration from the config file at path (if not empty)
+// and the environment, publishes it and re-applies the settings that can
+// change while running: the rate limit, the circuit breakers' thresholds
+// (applied to a tripped breaker once it closes; sources that set their own
+// keep them), the log settings and the bridge filter, which is read on each
+// request. Other
+// settings take effect on restart. If the new configuration is invalid the
+// current one is kept and the error returned.
+func (s *Server) Reload(path string) error {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+	cfg, err := config.Load(path)
+	if err != nil {
+		metrics.ConfigReloadsTotal.WithLabelValues("failure").Inc()
+		logger.Logger.Errorf("Configuration reload failed, keeping the current configuration: %v", err)
+		return err
+	}
+	old := *config.Get()
+	config.Set(cfg)
+	s.limiter.SetMax(cfg.RequestsPerMin)
+	for _, b := range s.breakers {
+		b.Reconfigure(cfg.CircuitBreaker.MaxFailures, cfg.CircuitBreaker.CoolOffSeconds)
+	}
+	if s.Service.Sources != nil {
+		s.Service.Sources.Reconfigure(cfg.CircuitBreaker.MaxFailures, cfg.CircuitBreaker.CoolOffSeconds)
+	}
+	if err := logger.Configure(cfg.Log.Level, cfg.Log.RowSampleFirst, cfg.Log.RowSampleEvery); err != nil {
+		logger.Logger.Errorf("Failed to apply the reloaded log settings: %v", err)
+	}
+	metrics.ConfigReloadsTotal.WithLabelValues("success").Inc()
+	metrics.ConfigLastReloadSuccess.SetToCurrentTime()
+	logger.Logger.Infow("Configuration reloaded", "file", path)
+	if withoutLive(old) != withoutLive(cfg) {
+		logger.Logger.Warnf("Configuration reloaded with changes that take effect on restart")
+	}
+	return nil
+}
+
+// withoutLive clears the settings that Reload re-applies, leaving those that
+// need a restart.
+func withoutLive(cfg config.Config) config.Config {
+	cfg.RequestsPerMin = 0
+	cfg.CircuitBreaker.MaxFailures = 0
+	cfg.CircuitBreaker.CoolOffSeconds = 0
+	cfg.BridgeFilterPercentile = 0
+	cfg.BridgeFilterMaxCount = 0
+	cfg.Log.Level = ""
+	cfg.Log.RowSampleFirst = 0
+	cfg.Log.RowSampleEvery = 0
+	return cfg
+}
+
+// WatchConfig reloads the configuration whenever the file at path changes,
+// checking every interval until the server shuts down.
+func (s *Server) WatchConfig(path string, interval time.Duration) {
+	last := stat(path)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			if v := stat(path); v != last {
+				last = v
+				_ = s.Reload(path)
+			}
+		}
+	}()
+}
+
+// fileVersion identifies one version of a file; it is zero if the file cannot
+// be read.
+type fileVersion struct {
+	modTime time.Time
+	size    int64
+}
+
+// stat follows symlinks, so a Kubernetes ConfigMap update is seen too.
+func stat(path string) fileVersion {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return fileVersion{}
+	}
+	return fileVersion{modTime: fi.ModTime(), size: fi.Size()}
+}
@@ -0,0 +1,255 @@
+// Package server wires the scrapers, service, API and background workers into
+// a runnable ThamesTracker server, configured from config.Get().
+package server
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Takenobou/thamestracker/internal/api"
+	"github.com/Takenobou/thamestracker/internal/changes"
+	"github.com/Takenobou/thamestracker/internal/config"
+	"github.com/Takenobou/thamestracker/internal/fixtures"
+	"github.com/Takenobou/thamestracker/internal/helpers/cache"
+	"github.com/Takenobou/thamestracker/internal/helpers/httpclient"
+	"github.com/Takenobou/thamestracker/internal/helpers/logger"
+	"github.com/Takenobou/thamestracker/internal/helpers/metrics"
+	"github.com/Takenobou/thamestracker/internal/history"
+	"github.com/Takenobou/thamestracker/internal/scheduler"
+	bridgeScraper "github.com/Takenobou/thamestracker/internal/scraper/bridge"
+	"github.com/Takenobou/thamestracker/internal/scraper/selectors"
+	vesselScraper "github.com/Takenobou/thamestracker/internal/scraper/vessels"
+	"github.com/Takenobou/thamestracker/internal/service"
+	"github.com/Takenobou/thamestracker/internal/sources"
+	"github.com/Takenobou/thamestracker/internal/storage"
+	"github.com/Takenobou/thamestracker/internal/validation"
+	"github.com/Takenobou/thamestracker/internal/webhooks"
+	"github.com/gofiber/fiber/v2"
+)
+
+// Server is a fully wired server. Background workers start in New and run
+// until Shutdown.
+type Server struct {
+	App     *fiber.App
+	Service *service.Service
+
+	ctx          context.Context // cancelled on shutdown
+	stopApp      context.CancelFunc
+	history      history.EventStore
+	stopWebhooks func() // ends webhook delivery, if enabled
+
+	// settings re-applied when the configuration is reloaded
+	reloadMu sync.Mutex
+	limiter  *rateLimiter
+	breakers []*httpclient.BreakerClient
+}
+
+// New builds a server from the current configuration.
+func New() (*Server, error) {
+	// cancelled on shutdown to stop background workers
+	appCtx, stopApp := context.WithCancel(context.Background())
+	srv, err := build(appCtx)
+	if err != nil {
+		stopApp()
+		return nil, err
+	}
+	srv.ctx, srv.stopApp = appCtx, stopApp
+	return srv, nil
+}
+
+func build(appCtx context.Context) (*Server, error) {
+	cfg := config.Get()
+	// initialize storage cache client with loaded config
+	storage.CacheClient = cache.NewRedisCache(cfg.Redis.Address)
+
+	cacheClient := cache.NewRedisCache(cfg.Redis.Address)
+	// upstream requests go to the network, or to fixtures when configured
+	upstream := httpclient.DefaultClient
+	transport, err := upstreamTransport(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("set up fixtures: %w", err)
+	}
+	if transport != nil {
+		upstream = httpclient.NewClient(transport)
+	}
+	if cfg.UserAgent != "" {
+		upstream = httpclient.WithUserAgent(upstream, cfg.UserAgent)
+	}
+	upstream = httpclient.WithTracing(upstream)
+	// wrap each upstream's HTTP client in its own circuit breaker
+	bridgeClient := httpclient.NewBreakerClient("bridge", upstream,
+		cfg.CircuitBreaker.MaxFailures,
+		cfg.CircuitBreaker.CoolOffSeconds)
+	vesselsClient := httpclient.NewBreakerClient("vessels", upstream,
+		cfg.CircuitBreaker.MaxFailures,
+		cfg.CircuitBreaker.CoolOffSeconds)
+	// tracks the shape of each scrape to flag upstream changes
+	tracker := validation.NewTracker(10)
+	bridge := bridgeScraper.BridgeScraperImpl{
+		Client:  bridgeClient,
+		URL:     cfg.URLs.TowerBridge,
+		Tracker: tracker,
+	}
+	if cfg.TowerBridgeDefinition != "" {
+		def, err := selectors.Load(cfg.TowerBridgeDefinition)
+		if err != nil {
+			return nil, fmt.Errorf("load Tower Bridge definition: %w", err)
+		}
+		bridge.Definition = &def
+	}
+	svc := service.NewService(
+		cacheClient,
+		bridge,
+		vesselScraper.VesselScraperImpl{Client: vesselsClient, Tracker: tracker},
+	)
+	svc.Changes = changes.NewFeed(cfg.ChangesBufferSize)
+	svc.BridgeTimeout = time.Duration(cfg.Timeouts.BridgeSeconds) * time.Second
+	svc.VesselsTimeout = time.Duration(cfg.Timeouts.VesselsSeconds) * time.Second
+	svc.BridgeMaxAge = time.Duration(cfg.Freshness.BridgeMaxAgeSeconds) * time.Second
+	svc.VesselsMaxAge = time.Duration(cfg.Freshness.VesselsMaxAgeSeconds) * time.Second
+	svc.Upstreams = []service.Upstream{
+		{Name: "bridge", URL: cfg.URLs.TowerBridge},
+		{Name: "vessels", URL: cfg.URLs.PortOfLondon},
+	}
+	svc.UpstreamClient = upstream
+	svc.Breakers = httpclient.Breakers
+	svc.ReadyTimeout = time.Duration(cfg.ReadyTimeoutSeconds) * time.Second
+	svc.Lifetime = appCtx
+	srv := &Server{Service: svc, breakers: []*httpclient.BreakerClient{bridgeClient, vesselsClient}}
+	// open persistent event history store, if configured
+	if cfg.HistoryDBPath != "" {
+		store, err := history.NewBoltStore(cfg.HistoryDBPath)
+		if err != nil {
+			return nil, fmt.Errorf("open history store: %w", err)
+		}
+		srv.history = store
+		svc.History = store
+	}
+	// register configured event sources, if any
+	if cfg.SourcesFile != "" {
+		opts := sourceOptions(cfg)
+		opts.Tracker = tracker
+		registry, err := sources.Load(cfg.SourcesFile, upstream, opts)
+		if err != nil {
+			srv.closeHistory()
+			return nil, fmt.Errorf("load sources: %w", err)
+		}
+		svc.Sources = registry
+	}
+	handler := api.NewAPIHandler(svc)
+
+	// outbound webhooks, driven by the change feed
+	var webhookManager *webhooks.Manager
+	if cfg.Webhooks.File != "" {
+		m, err := webhooks.NewManager(cfg.Webhooks.File, webhooks.Options{
+			MaxAttempts:    cfg.Webhooks.MaxAttempts,
+			InitialBackoff: time.Duration(cfg.Webhooks.InitialBackoff) * time.Millisecond,
+		})
+		if err != nil {
+			srv.closeHistory()
+			return nil, fmt.Errorf("load webhooks: %w", err)
+		}
+		webhookManager = m
+		follow := svc.Changes.Follow()
+		srv.stopWebhooks = follow.Close
+		go webhookManager.Run(appCtx, follow, svc.GetBridgeLifts)
+	}
+
+	// refresh each source in the background so requests are served from a warm cache;
+	// started after the webhook subscriber so no changes are missed
+	sched := scheduler.New(time.Duration(cfg.Scheduler.JitterSeconds) * time.Second)
+	sched.Add("bridge", time.Duration(cfg.Scheduler.BridgeIntervalSeconds)*time.Second,
+		svc.RefreshBridgeLifts)
+	sched.Add("vessels", time.Duration(cfg.Scheduler.VesselsIntervalSeconds)*time.Second,
+		svc.RefreshVessels)
+	for _, info := range svc.ListSources() {
+		name := info.Name
+		sched.Add("source:"+name, info.RefreshInterval(), func(ctx context.Context) error {
+			return svc.RefreshSource(ctx, name)
+		})
+	}
+	sched.Start(appCtx)
+	handler.SetScrapeStatus(sched)
+	handler.SetSourceStatus(tracker)
+
+	app := fiber.New()
+	// request rate, errors and duration per route, including rate-limited requests
+	app.Use(metrics.Middleware())
+	// per-IP rate limiter middleware, whose limit can be changed by a reload
+	srv.limiter = newRateLimiter(cfg.RequestsPerMin)
+	app.Use(srv.limiter.Handle)
+	// cancel in-flight scrapes when the server shuts down
+	app.Use(api.RequestContext(appCtx))
+	// structured request logging, with a request-scoped logger in the user context
+	app.Use(logger.RequestLogger())
+	// trace each request through the service, cache and scrapers
+	app.Use(api.Tracing())
+	api.SetupRoutes(app, handler)
+	if cfg.AdminToken != "" && webhookManager != nil {
+		api.SetupAdminRoutes(app, api.NewWebhookHandler(webhookManager), cfg.AdminToken)
+	}
+	srv.App = app
+	return srv, nil
+}
+
+// Listen serves HTTP on addr until Shutdown.
+func (s *Server) Listen(addr string) error {
+	return s.App.Listen(addr)
+}
+
+// Serve serves HTTP on ln until Shutdown.
+func (s *Server) Serve(ln net.Listener) error {
+	return s.App.Listener(ln)
+}
+
+// Shutdown stops the background workers and cancels in-flight scrapes, waits
+// until ctx expires for open requests to finish, and closes the history store.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.stopApp()
+	if s.stopWebhooks != nil {
+		s.stopWebhooks()
+	}
+	// end streaming subscriptions so open connections can drain
+	s.Service.Changes.Close()
+	// use ShutdownWithContext to respect timeout and exit promptly
+	err := s.App.ShutdownWithContext(ctx)
+	if err != nil {
+		logger.Logger.Errorf("Error during server shutdown: %v", err)
+	}
+	s.closeHistory()
+	return err
+}
+
+func (s *Server) closeHistory() {
+	if s.history == nil {
+		return
+	}
+	if err := s.history.Close(); err != nil {
+		logger.Logger.Errorf("Error closing history store: %v", err)
+	}
+}
+
+// upstreamTransport returns the transport for upstream requests when
+// FIXTURES_MODE is set, or nil to use the network as normal.
+func upstreamTransport(cfg *config.Config) (http.RoundTripper, error) {
+	f := cfg.Fixtures
+	if f.Mode == "" {
+		return nil, nil
+	}
+	logger.Logger.Infof("Using upstream fixtures, mode: %s, dir: %s, time shift: %t", f.Mode, f.Dir, f.TimeShift)
+	return fixtures.NewTransport(f.Mode, f.Dir, f.TimeShift, http.DefaultTransport)
+}
+
+// sourceOptions returns the defaults for configured sources, taken from the
+// built-in scrapers' settings.
+func sourceOptions(cfg *config.Config) sources.Options {
+	return sources.Options{
+		TimeoutSeconds: cfg.Timeouts.VesselsSeconds,
+		MaxFailures:    cfg.CircuitBreaker.MaxFailures,
+		CoolOffSeconds: cfg.CircuitBreaker.CoolOffSeconds,
+	}
+}
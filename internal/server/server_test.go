@@ -0,0 +1,399 @@
+package server_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Takenobou/thamestracker/internal/cache"
+	"github.com/Takenobou/thamestracker/internal/config"
+	"github.com/Takenobou/thamestracker/internal/fakeupstream"
+	"github.com/Takenobou/thamestracker/internal/helpers/logger"
+	"github.com/Takenobou/thamestracker/internal/helpers/metrics"
+	"github.com/Takenobou/thamestracker/internal/models"
+	"github.com/Takenobou/thamestracker/internal/server"
+	"github.com/Takenobou/thamestracker/internal/service"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestMain(m *testing.M) {
+	logger.InitLogger()
+	os.Exit(m.Run())
+}
+
+// env is a running server with its stand-in upstreams and Redis.
+type env struct {
+	srv   *server.Server
+	fake  *fakeupstream.Server
+	redis *miniredis.Miniredis
+	base  string
+	done  chan error // receives Serve's result
+}
+
+// boot starts the server wired as cmd/server does, against a fake upstream
+// and miniredis, with background refresh off so each test controls scraping.
+// configure adjusts the configuration before the server is built.
+func boot(t *testing.T, configure func(*config.Config)) *env {
+	t.Helper()
+	fake := fakeupstream.New(fakeupstream.Options{Seed: 7, PageSize: 5, Vessels: 4})
+	upstream := httptest.NewServer(fake.Handler())
+	t.Cleanup(upstream.Close)
+	mr := miniredis.RunT(t)
+
+	old := *config.Get()
+	t.Cleanup(func() { config.Set(old) })
+	cfg := config.Defaults()
+	cfg.URLs.TowerBridge = upstream.URL + fakeupstream.BridgePath
+	cfg.URLs.PortOfLondon = upstream.URL + fakeupstream.VesselsPath + "?url=ships/lists"
+	cfg.Redis.Address = mr.Addr()
+	cfg.HistoryDBPath = filepath.Join(t.TempDir(), "history.db")
+	cfg.Webhooks.File = ""
+	cfg.SourcesFile = ""
+	cfg.Fixtures.Mode = ""
+	cfg.RequestsPerMin = 1000
+	cfg.Scheduler.BridgeIntervalSeconds = 0
+	cfg.Scheduler.VesselsIntervalSeconds = 0
+	if configure != nil {
+		configure(&cfg)
+	}
+	config.Set(cfg)
+
+	srv, err := server.New()
+	if err != nil {
+		t.Fatalf("server.New: %v", err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	e := &env{srv: srv, fake: fake, redis: mr, base: "http://" + ln.Addr().String(), done: make(chan error, 1)}
+	go func() { e.done <- srv.Serve(ln) }()
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(ctx)
+	})
+	return e
+}
+
+func (e *env) get(t *testing.T, path string) (*http.Response, string) {
+	t.Helper()
+	resp, err := http.Get(e.base + path)
+	if err != nil {
+		t.Fatalf("GET %s: %v", path, err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return resp, string(body)
+}
+
+func (e *env) events(t *testing.T, path string) []models.Event {
+	t.Helper()
+	resp, body := e.get(t, path)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET %s: %d %s", path, resp.StatusCode, body)
+	}
+	var events []models.Event
+	if err := json.Unmarshal([]byte(body), &events); err != nil {
+		t.Fatalf("GET %s: %v", path, err)
+	}
+	return events
+}
+
+func TestFiltering(t *testing.T) {
+	e := boot(t, nil)
+
+	lifts := e.events(t, "/bridge-lifts")
+	assert.Len(t, lifts, e.fake.Lifts(), "every page of lifts is served")
+	name := lifts[0].VesselName
+	filtered := e.events(t, "/bridge-lifts?name="+strings.ReplaceAll(name, " ", "%20"))
+	assert.NotEmpty(t, filtered)
+	for _, l := range filtered {
+		assert.Equal(t, name, l.VesselName)
+	}
+	after := lifts[len(lifts)/2].Timestamp
+	for _, l := range e.events(t, "/bridge-lifts?after="+after.UTC().Format(time.RFC3339)) {
+		assert.False(t, l.Timestamp.Before(after))
+	}
+
+	arrivals := e.events(t, "/vessels?type=arrivals")
+	assert.Len(t, arrivals, 4)
+	for _, v := range arrivals {
+		assert.Equal(t, "arrivals", v.Category)
+	}
+	assert.Len(t, e.events(t, "/vessels?type=all"), 16)
+
+	resp, _ := e.get(t, "/vessels?type=ferries")
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestCalendarFeeds(t *testing.T) {
+	e := boot(t, nil)
+
+	resp, body := e.get(t, "/bridge-lifts/calendar.ics")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, resp.Header.Get("Content-Type"), "text/calendar")
+	assert.True(t, strings.HasPrefix(body, "BEGIN:VCALENDAR"))
+	assert.Equal(t, e.fake.Lifts(), strings.Count(body, "BEGIN:VEVENT"))
+
+	resp, body = e.get(t, "/vessels/calendar.ics?type=forecast")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, 4, strings.Count(body, "BEGIN:VEVENT"))
+}
+
+func TestRepeatRequestsHitTheCache(t *testing.T) {
+	e := boot(t, nil)
+
+	first := e.events(t, "/vessels?type=inport")
+	second := e.events(t, "/vessels?type=inport")
+	assert.Equal(t, first, second)
+	assert.Equal(t, int64(1), e.fake.Requests(fakeupstream.Vessels), "the second request is served from Redis")
+	assert.True(t, e.redis.Exists(cache.KeyVessels("inport")))
+
+	e.events(t, "/bridge-lifts")
+	hits := e.fake.Requests(fakeupstream.Bridge)
+	e.events(t, "/bridge-lifts")
+	assert.Equal(t, hits, e.fake.Requests(fakeupstream.Bridge))
+}
+
+func TestRateLimiting(t *testing.T) {
+	e := boot(t, func(cfg *config.Config) { cfg.RequestsPerMin = 3 })
+
+	for i := 0; i < 3; i++ {
+		resp, _ := e.get(t, "/healthz")
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+	resp, body := e.get(t, "/healthz")
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	assert.JSONEq(t, `{"error":"Rate limit exceeded"}`, body)
+}
+
+func TestOpenBreakerReturns503(t *testing.T) {
+	e := boot(t, func(cfg *config.Config) {
+		cfg.CircuitBreaker.MaxFailures = 2
+		cfg.CircuitBreaker.CoolOffSeconds = 30
+	})
+	e.fake.SetFaults(fakeupstream.Faults{Status: http.StatusBadGateway})
+
+	resp, _ := e.get(t, "/vessels?type=inport")
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(t, "30", resp.Header.Get("Retry-After"))
+	assert.Equal(t, int64(2), e.fake.Requests(fakeupstream.Vessels), "the breaker stops the retries")
+
+	resp, _ = e.get(t, "/bridge-lifts")
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(t, "30", resp.Header.Get("Retry-After"))
+	assert.Equal(t, int64(2), e.fake.Requests(fakeupstream.Bridge), "the bridge scraper has its own breaker")
+
+	// the upstream recovers, but the breakers stay open until the cool-off ends
+	e.fake.SetFaults(fakeupstream.Faults{})
+	resp, _ = e.get(t, "/vessels?type=arrivals")
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(t, int64(2), e.fake.Requests(fakeupstream.Vessels))
+	resp, _ = e.get(t, "/bridge-lifts")
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(t, int64(2), e.fake.Requests(fakeupstream.Bridge))
+
+	// readiness shows which upstream is tripping without being down itself
+	resp, body := e.get(t, "/readyz")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	var report service.Readiness
+	assert.NoError(t, json.Unmarshal([]byte(body), &report))
+	assert.Equal(t, service.StatusDegraded, report.Status)
+	states := map[string]string{}
+	for _, c := range report.Checks {
+		states[c.Name] = c.Status
+	}
+	assert.Equal(t, service.StatusOK, states["redis"])
+	assert.Equal(t, service.StatusOK, states["upstream:bridge"])
+	assert.Equal(t, service.StatusDown, states["breaker:bridge"])
+	assert.Equal(t, service.StatusDown, states["breaker:vessels"])
+}
+
+func TestConfigReload(t *testing.T) {
+	e := boot(t, nil)
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	write := func(settings string) {
+		t.Helper()
+		cur := config.Get()
+		body := fmt.Sprintf("urls:\n  tower_bridge: %s\n  port_of_london: %s\n%s",
+			cur.URLs.TowerBridge, cur.URLs.PortOfLondon, settings)
+		if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	failures := testutil.ToFloat64(metrics.ConfigReloadsTotal.WithLabelValues("failure"))
+
+	t.Cleanup(func() { _ = logger.Configure("", 5, 20) })
+	write("requests_per_min: 2\ncircuit_breaker:\n  max_failures: 1\n  cool_off_seconds: 45\nbridge_filter_max_count: 1\nlog:\n  level: warn\n")
+	assert.NoError(t, e.srv.Reload(path))
+	assert.Equal(t, 1, config.Get().BridgeFilterMaxCount)
+	assert.Equal(t, zapcore.WarnLevel, logger.Level.Level())
+
+	// the new breaker threshold applies to the next failure
+	e.fake.SetFaults(fakeupstream.Faults{Status: http.StatusBadGateway})
+	resp, _ := e.get(t, "/vessels?type=inport")
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(t, "45", resp.Header.Get("Retry-After"))
+	assert.Equal(t, int64(1), e.fake.Requests(fakeupstream.Vessels))
+	// and so does the new rate limit
+	resp, _ = e.get(t, "/healthz")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp, _ = e.get(t, "/healthz")
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+
+	// an invalid file is rejected and the running configuration kept
+	write("bridge_filter_percentile: 2\n")
+	assert.Error(t, e.srv.Reload(path))
+	assert.Equal(t, 1, config.Get().BridgeFilterMaxCount)
+	assert.Equal(t, failures+1, testutil.ToFloat64(metrics.ConfigReloadsTotal.WithLabelValues("failure")))
+}
+
+func TestConfigReloadWhileBreakerOpen(t *testing.T) {
+	e := boot(t, func(cfg *config.Config) {
+		cfg.CircuitBreaker.MaxFailures = 2
+		cfg.CircuitBreaker.CoolOffSeconds = 3
+	})
+	e.fake.SetFaults(fakeupstream.Faults{Status: http.StatusBadGateway})
+	resp, _ := e.get(t, "/vessels?type=inport")
+	opened := time.Now() // at the latest
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(t, int64(2), e.fake.Requests(fakeupstream.Vessels))
+
+	cur := config.Get()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	body := fmt.Sprintf("urls:\n  tower_bridge: %s\n  port_of_london: %s\ncircuit_breaker:\n  max_failures: 1\n  cool_off_seconds: 45\n",
+		cur.URLs.TowerBridge, cur.URLs.PortOfLondon)
+	if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(t, e.srv.Reload(path))
+
+	// the open breaker is kept, so the failing upstream is not called
+	resp, _ = e.get(t, "/vessels?type=arrivals")
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(t, int64(2), e.fake.Requests(fakeupstream.Vessels))
+
+	// its own cool-off still ends it; once closed the new thresholds apply
+	e.fake.SetFaults(fakeupstream.Faults{})
+	time.Sleep(time.Until(opened.Add(3100 * time.Millisecond)))
+	e.events(t, "/vessels?type=arrivals")
+	e.fake.SetFaults(fakeupstream.Faults{Status: http.StatusBadGateway})
+	resp, _ = e.get(t, "/vessels?type=departures")
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(t, "45", resp.Header.Get("Retry-After"))
+	assert.Equal(t, int64(4), e.fake.Requests(fakeupstream.Vessels), "one failure opens it")
+}
+
+func TestWatchConfig(t *testing.T) {
+	e := boot(t, nil)
+	path := filepath.Join(t.TempDir(), "config.toml")
+	cur := config.Get()
+	write := func(maxCount int) {
+		t.Helper()
+		body := fmt.Sprintf("bridge_filter_max_count = %d\n\n[urls]\ntower_bridge = %q\nport_of_london = %q\n",
+			maxCount, cur.URLs.TowerBridge, cur.URLs.PortOfLondon)
+		if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(3)
+	e.srv.WatchConfig(path, 10*time.Millisecond)
+	write(12)
+	assert.Eventually(t, func() bool { return config.Get().BridgeFilterMaxCount == 12 },
+		2*time.Second, 10*time.Millisecond)
+}
+
+func TestStaleDataOnUpstreamFailure(t *testing.T) {
+	e := boot(t, nil)
+	fresh := e.events(t, "/vessels?type=departures")
+
+	// expire the cached copy, keeping the last known good one
+	e.redis.Del(cache.KeyVessels("departures"))
+	e.fake.SetFaults(fakeupstream.Faults{MalformedJSON: true})
+	resp, body := e.get(t, "/vessels?type=departures")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.NotEmpty(t, resp.Header.Get("Warning"))
+	var stale []models.Event
+	assert.NoError(t, json.Unmarshal([]byte(body), &stale))
+	assert.Len(t, stale, len(fresh))
+	assert.True(t, stale[0].Stale)
+}
+
+func TestClientDisconnectCancelsScrape(t *testing.T) {
+	e := boot(t, nil)
+
+	// a client asks for bridge lifts from a slow upstream, then hangs up
+	e.fake.SetFaults(fakeupstream.Faults{DelayMS: 10000, Only: fakeupstream.Bridge})
+	conn, err := net.Dial("tcp", strings.TrimPrefix(e.base, "http://"))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	fmt.Fprint(conn, "GET /bridge-lifts HTTP/1.1\r\nHost: test\r\n\r\n")
+	for e.fake.Requests(fakeupstream.Bridge) == 0 {
+		time.Sleep(10 * time.Millisecond)
+	}
+	conn.Close()
+
+	// nobody is waiting for the slow scrape any more, so the next request
+	// starts its own instead of joining it
+	e.fake.SetFaults(fakeupstream.Faults{})
+	time.Sleep(time.Second)
+	start := time.Now()
+	assert.NotEmpty(t, e.events(t, "/bridge-lifts"))
+	assert.Less(t, time.Since(start), 2*time.Second)
+}
+
+func TestGracefulShutdown(t *testing.T) {
+	e := boot(t, nil)
+	e.events(t, "/vessels?type=inport") // warm the cache
+
+	// hold a request open in a slow upstream scrape
+	e.fake.SetFaults(fakeupstream.Faults{DelayMS: 10000, Only: fakeupstream.Bridge})
+	inFlight := make(chan int, 1)
+	go func() {
+		resp, err := http.Get(e.base + "/bridge-lifts")
+		if err != nil {
+			inFlight <- 0
+			return
+		}
+		resp.Body.Close()
+		inFlight <- resp.StatusCode
+	}()
+	for e.fake.Requests(fakeupstream.Bridge) == 0 {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	start := time.Now()
+	assert.NoError(t, e.srv.Shutdown(ctx))
+	assert.Less(t, time.Since(start), 2*time.Second, "in-flight scrapes are cancelled rather than waited out")
+
+	select {
+	case status := <-inFlight:
+		assert.NotZero(t, status, "the in-flight request gets a response")
+	case <-time.After(2 * time.Second):
+		t.Fatal("in-flight request did not finish")
+	}
+	select {
+	case err := <-e.done:
+		assert.NoError(t, err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("Serve did not return")
+	}
+	_, err := http.Get(e.base + "/healthz")
+	assert.Error(t, err, "no new connections after shutdown")
+}
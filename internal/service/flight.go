@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+	"sync"
+
+	"github.com/Takenobou/thamestracker/internal/models"
+)
+
+// flightCall is an in-progress or completed scrape shared by its waiters.
+type flightCall struct {
+	done    chan struct{}
+	events  []models.Event
+	err     error
+	waiters int
+	cancel  context.CancelFunc
+}
+
+// flightGroup coalesces concurrent scrapes of the same cache key so that only
+// one runs and every caller receives its result. The zero value is ready to use.
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall
+}
+
+// Do runs fn for key unless a call for key is already in flight, in which case
+// it waits for that call and returns its result. shared reports whether the
+// result came from another caller's scrape. Waiters share the returned slice
+// and must not modify it.
+//
+// fn runs on a context detached from any single caller, so one caller going
+// away does not fail the scrape for the others. It is cancelled once every
+// waiter has given up, or when lifetime (if not nil) ends, such as on
+// shutdown.
+func (g *flightGroup) Do(ctx, lifetime context.Context, key string, fn func(ctx context.Context) ([]models.Event, error)) (events []models.Event, err error, shared bool) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*flightCall)
+	}
+	c, shared := g.calls[key]
+	if !shared {
+		fctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		c = &flightCall{done: make(chan struct{}), cancel: cancel}
+		g.calls[key] = c
+		go func() {
+			defer close(c.done)
+			defer cancel()
+			if lifetime != nil {
+				stop := context.AfterFunc(lifetime, cancel)
+				defer stop()
+			}
+			c.events, c.err = fn(fctx)
+			g.mu.Lock()
+			if g.calls[key] == c {
+				delete(g.calls, key)
+			}
+			g.mu.Unlock()
+		}()
+	}
+	c.waiters++
+	g.mu.Unlock()
+
+	select {
+	case <-c.done:
+		return c.events, c.err, shared
+	case <-ctx.Done():
+		g.mu.Lock()
+		c.waiters--
+		if c.waiters == 0 {
+			c.cancel()
+			// later callers start a fresh scrape rather than join a cancelled one
+			if g.calls[key] == c {
+				delete(g.calls, key)
+			}
+		}
+		g.mu.Unlock()
+		return nil, ctx.Err(), shared
+	}
+}
@@ -0,0 +1,120 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	keycache "github.com/Takenobou/thamestracker/internal/cache"
+	cache "github.com/Takenobou/thamestracker/internal/helpers/cache"
+	"github.com/Takenobou/thamestracker/internal/helpers/logger"
+	"github.com/Takenobou/thamestracker/internal/helpers/metrics"
+	"github.com/Takenobou/thamestracker/internal/models"
+)
+
+const (
+	bridgeTTL  = 15 * time.Minute
+	vesselsTTL = 30 * time.Minute
+	// revalidateAt is the fraction of the TTL after which a cache hit triggers
+	// a background refresh.
+	revalidateAt = 0.8
+)
+
+// Freshness describes the data currently served for a source.
+type Freshness struct {
+	UpdatedAt time.Time // when the data was scraped; zero if unknown (e.g. after a restart)
+	Stale     bool      // served from the last known good copy after a failed scrape
+}
+
+// keyState tracks the freshness of one cache key.
+type keyState struct {
+	Freshness
+	lastRevalidate time.Time
+}
+
+// DataFreshness reports how fresh the data served for source is, where source
+// is "bridge", a vessel type or the name of a configured source.
+func (s *Service) DataFreshness(source string) Freshness {
+	key := keycache.KeyVessels(source)
+	if source == "bridge" {
+		key = keycache.KeyBridgeLifts()
+	} else if s.Sources != nil {
+		if _, ok := s.Sources.Get(source); ok {
+			key = keycache.KeySource(source)
+		}
+	}
+	s.freshMu.Lock()
+	defer s.freshMu.Unlock()
+	if st, ok := s.fresh[key]; ok {
+		return st.Freshness
+	}
+	return Freshness{}
+}
+
+func (s *Service) state(key string) *keyState {
+	if s.fresh == nil {
+		s.fresh = make(map[string]*keyState)
+	}
+	st, ok := s.fresh[key]
+	if !ok {
+		st = &keyState{}
+		s.fresh[key] = st
+	}
+	return st
+}
+
+// storeFresh caches freshly scraped events together with a last known good copy.
+func (s *Service) storeFresh(ctx context.Context, key string, events []models.Event, ttl time.Duration) {
+	if err := cache.SetWithLastGood(ctx, s.Cache, key, events, ttl); err != nil {
+		logger.FromContext(ctx).Errorf("Failed to cache %s: %v", key, err)
+	}
+	s.freshMu.Lock()
+	defer s.freshMu.Unlock()
+	st := s.state(key)
+	st.UpdatedAt = time.Now().UTC()
+	st.Stale = false
+}
+
+// serveStale returns the last known good copy of key, marked stale, in place
+// of a failed scrape. If there is none, or the caller has gone away, scrapeErr
+// is returned.
+func (s *Service) serveStale(ctx context.Context, key string, scrapeErr error) ([]models.Event, error) {
+	if ctx.Err() != nil {
+		return nil, scrapeErr
+	}
+	var events []models.Event
+	storedAt, err := cache.GetLastGood(ctx, s.Cache, key, &events)
+	if err != nil {
+		return nil, scrapeErr
+	}
+	for i := range events {
+		events[i].Stale = true
+	}
+	metrics.StaleServedTotal.WithLabelValues(key).Inc()
+	logger.FromContext(ctx).Warnf("Serving stale %s from %s after scrape failure: %v", key, storedAt.Format(time.RFC3339), scrapeErr)
+	s.freshMu.Lock()
+	defer s.freshMu.Unlock()
+	st := s.state(key)
+	st.UpdatedAt = storedAt
+	st.Stale = true
+	return events, nil
+}
+
+// revalidate starts a background refresh of key when its cached copy is close
+// to expiry, at most once per tenth of the TTL so a failing upstream is not
+// hammered by every request.
+func (s *Service) revalidate(ctx context.Context, key string, ttl time.Duration, refresh func()) {
+	s.freshMu.Lock()
+	st := s.state(key)
+	now := time.Now()
+	due := !st.UpdatedAt.IsZero() &&
+		now.Sub(st.UpdatedAt) >= time.Duration(float64(ttl)*revalidateAt) &&
+		now.Sub(st.lastRevalidate) >= ttl/10
+	if due {
+		st.lastRevalidate = now
+	}
+	s.freshMu.Unlock()
+	if due {
+		logger.FromContext(ctx).Infof("Refreshing %s in the background before it expires", key)
+		go refresh()
+	}
+}
@@ -0,0 +1,113 @@
+package service
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	keycache "github.com/Takenobou/thamestracker/internal/cache"
+	"github.com/Takenobou/thamestracker/internal/helpers/cache"
+	"github.com/Takenobou/thamestracker/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+type countingBridgeScraper struct{ calls int32 }
+
+func (c *countingBridgeScraper) ScrapeBridgeLifts(context.Context) ([]models.Event, error) {
+	atomic.AddInt32(&c.calls, 1)
+	return []models.Event{{VesselName: "Refreshed", Category: "bridge"}}, nil
+}
+
+func TestRevalidate_RefreshesNearExpiryOnce(t *testing.T) {
+	scraper := &countingBridgeScraper{}
+	svc := NewService(cache.NewRedisCache(""), scraper, nil)
+	assert.NoError(t, svc.RefreshBridgeLifts(context.Background()))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&scraper.calls))
+
+	// a young entry is served without revalidating
+	_, err := svc.GetBridgeLifts(context.Background())
+	assert.NoError(t, err)
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&scraper.calls))
+
+	// age the entry past 80% of its TTL
+	svc.freshMu.Lock()
+	svc.fresh[keycache.KeyBridgeLifts()].UpdatedAt = time.Now().Add(-13 * time.Minute)
+	svc.freshMu.Unlock()
+	for i := 0; i < 5; i++ {
+		events, err := svc.GetBridgeLifts(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, "Refreshed", events[0].VesselName)
+	}
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(&scraper.calls) == 2 }, time.Second, time.Millisecond)
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&scraper.calls))
+	assert.WithinDuration(t, time.Now(), svc.DataFreshness("bridge").UpdatedAt, time.Second)
+}
+
+func TestFlightGroup_LastWaiterLeavingCancels(t *testing.T) {
+	var g flightGroup
+	started := make(chan struct{})
+	scrapeCtx := make(chan context.Context, 1)
+	release := make(chan struct{})
+	fn := func(ctx context.Context) ([]models.Event, error) {
+		scrapeCtx <- ctx
+		close(started)
+		select {
+		case <-release:
+			return []models.Event{{VesselName: "Done"}}, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	// one of two waiters gives up: the scrape carries on for the other
+	first, leaveFirst := context.WithCancel(context.Background())
+	second, leaveSecond := context.WithCancel(context.Background())
+	errs := make(chan error, 2)
+	go func() {
+		_, err, _ := g.Do(first, nil, "k", fn)
+		errs <- err
+	}()
+	<-started
+	go func() {
+		_, err, shared := g.Do(second, nil, "k", fn)
+		assert.True(t, shared)
+		errs <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	leaveFirst()
+	assert.ErrorIs(t, <-errs, context.Canceled)
+	ctx := <-scrapeCtx
+	assert.NoError(t, ctx.Err())
+
+	// the last waiter gives up: nobody wants the result, so the scrape stops
+	leaveSecond()
+	assert.ErrorIs(t, <-errs, context.Canceled)
+	assert.Eventually(t, func() bool { return ctx.Err() != nil }, time.Second, time.Millisecond)
+
+	// a later caller starts a fresh scrape rather than joining the cancelled one
+	started = make(chan struct{})
+	close(release)
+	events, err, shared := g.Do(context.Background(), nil, "k", fn)
+	assert.NoError(t, err)
+	assert.False(t, shared)
+	assert.Equal(t, "Done", events[0].VesselName)
+}
+
+func TestFlightGroup_LifetimeCancels(t *testing.T) {
+	var g flightGroup
+	lifetime, shutdown := context.WithCancel(context.Background())
+	started := make(chan struct{})
+	go func() {
+		<-started
+		shutdown()
+	}()
+	_, err, _ := g.Do(context.Background(), lifetime, "k", func(ctx context.Context) ([]models.Event, error) {
+		close(started)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+}
@@ -0,0 +1,229 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	keycache "github.com/Takenobou/thamestracker/internal/cache"
+	cache "github.com/Takenobou/thamestracker/internal/helpers/cache"
+	"github.com/Takenobou/thamestracker/internal/helpers/httpclient"
+)
+
+// Readiness statuses, from best to worst. A degraded server still answers
+// requests, possibly from stale data; a down one cannot.
+const (
+	StatusOK       = "ok"
+	StatusDegraded = "degraded"
+	StatusDown     = "down"
+)
+
+const defaultReadyTimeout = 5 * time.Second
+
+// Upstream is an external site the scrapers depend on.
+type Upstream struct {
+	Name string
+	URL  string
+}
+
+// BreakerStatus reports the state of each circuit breaker.
+type BreakerStatus interface {
+	Status() []httpclient.BreakerStatus
+}
+
+// Check is the result of checking one component.
+type Check struct {
+	Name      string  `json:"name"`
+	Status    string  `json:"status"`
+	Critical  bool    `json:"critical"` // the server is down while this component is
+	LatencyMS float64 `json:"latency_ms"`
+	Detail    string  `json:"detail,omitempty"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Readiness is the overall status of the server and of each component.
+type Readiness struct {
+	Status string  `json:"status"`
+	Checks []Check `json:"checks"`
+}
+
+// check reports on one component; it should return promptly once ctx is done.
+type check struct {
+	name     string
+	critical bool
+	run      func(ctx context.Context) (status, detail string, err error)
+}
+
+// Readiness checks the cache, each upstream, each circuit breaker and the age
+// of each source's last successful scrape, concurrently and within
+// ReadyTimeout. A source's data is degraded when it is stale or older than its
+// maximum age. The server is down when a critical component (Redis) is down,
+// and degraded when any other component is not ok.
+func (s *Service) Readiness(ctx context.Context) Readiness {
+	timeout := s.ReadyTimeout
+	if timeout <= 0 {
+		timeout = defaultReadyTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	checks := s.readinessChecks()
+	results := make([]Check, len(checks))
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			start := time.Now()
+			status, detail, err := c.run(ctx)
+			results[i] = Check{
+				Name:      c.name,
+				Status:    status,
+				Critical:  c.critical,
+				LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+				Detail:    detail,
+			}
+			if err != nil {
+				results[i].Error = err.Error()
+			}
+		}()
+	}
+	wg.Wait()
+
+	overall := StatusOK
+	for _, r := range results {
+		switch {
+		case r.Status == StatusDown && r.Critical:
+			overall = StatusDown
+		case r.Status != StatusOK && overall == StatusOK:
+			overall = StatusDegraded
+		}
+	}
+	return Readiness{Status: overall, Checks: results}
+}
+
+// scrapeKeys names the cache keys written by scrapes of one source, and how
+// old its data may get.
+type scrapeKeys struct {
+	name   string
+	keys   []string
+	maxAge time.Duration
+}
+
+func (s *Service) readinessChecks() []check {
+	var checks []check
+	if p, ok := s.Cache.(cache.Pinger); ok {
+		checks = append(checks, check{name: "redis", critical: true, run: func(ctx context.Context) (string, string, error) {
+			if err := p.Ping(ctx); err != nil {
+				return StatusDown, "", fmt.Errorf("redis ping failed: %w", err)
+			}
+			return StatusOK, "", nil
+		}})
+	}
+	if u, ok := s.Cache.(cache.UsageReporter); ok {
+		checks = append(checks, check{name: "fallback_cache", run: func(context.Context) (string, string, error) {
+			entries, capacity := u.Usage()
+			return StatusOK, fmt.Sprintf("%d/%d entries", entries, capacity), nil
+		}})
+	}
+	for _, up := range s.Upstreams {
+		checks = append(checks, check{name: "upstream:" + up.Name, run: func(ctx context.Context) (string, string, error) {
+			if err := s.checkUpstream(ctx, up.URL); err != nil {
+				return StatusDown, "", err
+			}
+			return StatusOK, "", nil
+		}})
+	}
+	if s.Breakers != nil {
+		for _, b := range s.Breakers.Status() {
+			status := StatusOK
+			switch b.State {
+			case "open":
+				status = StatusDown
+			case "half-open":
+				status = StatusDegraded
+			}
+			checks = append(checks, check{name: "breaker:" + b.Name, run: func(context.Context) (string, string, error) {
+				return status, b.State, nil
+			}})
+		}
+	}
+	// each source's cache keys; a scrape of any of them counts as a success
+	scrapes := []scrapeKeys{
+		{"bridge", []string{keycache.KeyBridgeLifts()}, s.BridgeMaxAge},
+		{"vessels", append([]string{keycache.KeyVessels("all")}, vesselKeys()...), s.VesselsMaxAge},
+	}
+	for _, info := range s.ListSources() {
+		scrapes = append(scrapes, scrapeKeys{info.Name, []string{keycache.KeySource(info.Name)}, info.MaxAge()})
+	}
+	for _, sc := range scrapes {
+		checks = append(checks, check{name: "scrape:" + sc.name, run: func(context.Context) (string, string, error) {
+			f := s.latestFreshness(sc.keys)
+			if f.UpdatedAt.IsZero() {
+				return StatusOK, "no scrape yet", nil
+			}
+			age := time.Since(f.UpdatedAt)
+			detail := fmt.Sprintf("last success %s ago", age.Round(time.Second))
+			if sc.maxAge > 0 && age > sc.maxAge {
+				return StatusDegraded, detail + fmt.Sprintf(", older than %s", sc.maxAge), nil
+			}
+			if f.Stale {
+				return StatusDegraded, detail + ", serving stale data", nil
+			}
+			return StatusOK, detail, nil
+		}})
+	}
+	return checks
+}
+
+// checkUpstream requests url with HEAD, or GET where HEAD is not supported,
+// and fails on a network error or an error status.
+func (s *Service) checkUpstream(ctx context.Context, url string) error {
+	client := s.UpstreamClient
+	if client == nil {
+		client = httpclient.DefaultClient
+	}
+	status := 0
+	for _, method := range []string{http.MethodHead, http.MethodGet} {
+		req, err := http.NewRequestWithContext(ctx, method, url, nil)
+		if err != nil {
+			return fmt.Errorf("creating %s request failed: %w", method, err)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return fmt.Errorf("%s %s failed: %w", method, url, err)
+		}
+		resp.Body.Close()
+		status = resp.StatusCode
+		if status != http.StatusMethodNotAllowed && status != http.StatusNotImplemented {
+			break
+		}
+	}
+	if status >= 400 {
+		return fmt.Errorf("upstream returned status %d", status)
+	}
+	return nil
+}
+
+// latestFreshness returns the freshness of whichever of keys was scraped most recently.
+func (s *Service) latestFreshness(keys []string) Freshness {
+	s.freshMu.Lock()
+	defer s.freshMu.Unlock()
+	var latest Freshness
+	for _, key := range keys {
+		if st, ok := s.fresh[key]; ok && st.UpdatedAt.After(latest.UpdatedAt) {
+			latest = st.Freshness
+		}
+	}
+	return latest
+}
+
+func vesselKeys() []string {
+	keys := make([]string, len(vesselCategories))
+	for i, category := range vesselCategories {
+		keys[i] = keycache.KeyVessels(category)
+	}
+	return keys
+}
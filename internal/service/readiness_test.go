@@ -0,0 +1,111 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Takenobou/thamestracker/internal/helpers/httpclient"
+	"github.com/Takenobou/thamestracker/internal/models"
+	service "github.com/Takenobou/thamestracker/internal/service"
+	"github.com/stretchr/testify/assert"
+)
+
+// pingingCache is a fakeCache backed by a remote store that can be pinged.
+type pingingCache struct {
+	*fakeCache
+	err   error
+	block bool
+}
+
+func (p *pingingCache) Ping(ctx context.Context) error {
+	if p.block {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	return p.err
+}
+
+type fakeBreakers []httpclient.BreakerStatus
+
+func (f fakeBreakers) Status() []httpclient.BreakerStatus { return f }
+
+func checksByName(r service.Readiness) map[string]service.Check {
+	out := make(map[string]service.Check, len(r.Checks))
+	for _, c := range r.Checks {
+		out[c.Name] = c
+	}
+	return out
+}
+
+func TestReadiness_ReportsEachComponent(t *testing.T) {
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the PLA proxy rejects HEAD
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+	defer up.Close()
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer down.Close()
+
+	svc := service.NewService(&pingingCache{fakeCache: newFakeCache()},
+		&fakeBridgeScraper{result: []models.Event{{VesselName: "Lift"}}}, &fakeVesselScraper{})
+	svc.Upstreams = []service.Upstream{{Name: "bridge", URL: down.URL}, {Name: "vessels", URL: up.URL}}
+	svc.Breakers = fakeBreakers{{Name: "bridge", State: "open"}, {Name: "vessels", State: "closed"}}
+	_, err := svc.GetBridgeLifts(ctx)
+	assert.NoError(t, err)
+
+	report := svc.Readiness(ctx)
+	assert.Equal(t, service.StatusDegraded, report.Status)
+	checks := checksByName(report)
+	assert.Equal(t, service.StatusOK, checks["redis"].Status)
+	assert.Equal(t, service.StatusDown, checks["upstream:bridge"].Status)
+	assert.Contains(t, checks["upstream:bridge"].Error, "502")
+	assert.Equal(t, service.StatusOK, checks["upstream:vessels"].Status)
+	assert.Equal(t, service.StatusDown, checks["breaker:bridge"].Status)
+	assert.Equal(t, service.StatusOK, checks["breaker:vessels"].Status)
+	assert.Equal(t, service.StatusOK, checks["scrape:bridge"].Status)
+	assert.Contains(t, checks["scrape:bridge"].Detail, "last success")
+	assert.Equal(t, "no scrape yet", checks["scrape:vessels"].Detail)
+}
+
+func TestReadiness_DownWhenRedisIsDown(t *testing.T) {
+	svc := service.NewService(&pingingCache{fakeCache: newFakeCache(), err: errors.New("connection refused")},
+		&fakeBridgeScraper{}, &fakeVesselScraper{})
+	report := svc.Readiness(ctx)
+	assert.Equal(t, service.StatusDown, report.Status)
+	redis := checksByName(report)["redis"]
+	assert.True(t, redis.Critical)
+	assert.Contains(t, redis.Error, "connection refused")
+}
+
+func TestReadiness_BoundedByTimeout(t *testing.T) {
+	svc := service.NewService(&pingingCache{fakeCache: newFakeCache(), block: true},
+		&fakeBridgeScraper{}, &fakeVesselScraper{})
+	svc.ReadyTimeout = 50 * time.Millisecond
+	start := time.Now()
+	report := svc.Readiness(ctx)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, service.StatusDown, report.Status)
+	assert.Contains(t, checksByName(report)["redis"].Error, "deadline exceeded")
+}
+
+func TestReadiness_DegradedWhenDataIsTooOld(t *testing.T) {
+	svc := service.NewService(newFakeCache(), &fakeBridgeScraper{result: []models.Event{{VesselName: "Lift"}}}, &fakeVesselScraper{})
+	_, err := svc.GetBridgeLifts(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, service.StatusOK, svc.Readiness(ctx).Status)
+
+	svc.BridgeMaxAge = time.Nanosecond
+	report := svc.Readiness(ctx)
+	assert.Equal(t, service.StatusDegraded, report.Status)
+	bridge := checksByName(report)["scrape:bridge"]
+	assert.Equal(t, service.StatusDegraded, bridge.Status)
+	assert.Contains(t, bridge.Detail, "older than 1ns")
+}
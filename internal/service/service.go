@@ -2,31 +2,35 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"net/http"
 	"sort"
 	"strings"
 	"sync"
 	"time"
 
 	keycache "github.com/Takenobou/thamestracker/internal/cache"
-	"github.com/Takenobou/thamestracker/internal/config"
+	"github.com/Takenobou/thamestracker/internal/changes"
 	cache "github.com/Takenobou/thamestracker/internal/helpers/cache"
+	"github.com/Takenobou/thamestracker/internal/helpers/httpclient"
 	"github.com/Takenobou/thamestracker/internal/helpers/logger"
 	"github.com/Takenobou/thamestracker/internal/helpers/metrics"
+	"github.com/Takenobou/thamestracker/internal/helpers/tracing"
+	"github.com/Takenobou/thamestracker/internal/history"
 	"github.com/Takenobou/thamestracker/internal/models"
+	"github.com/Takenobou/thamestracker/internal/sources"
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // Define BridgeScraper and VesselScraper interfaces
 
 type BridgeScraper interface {
-	ScrapeBridgeLifts() ([]models.Event, error)
+	ScrapeBridgeLifts(ctx context.Context) ([]models.Event, error)
 }
 
 type VesselScraper interface {
-	ScrapeVessels(vesselType string) ([]models.Event, error)
+	ScrapeVessels(ctx context.Context, vesselType string) ([]models.Event, error)
 }
 
 // Update Service struct to use the interfaces
@@ -35,8 +39,40 @@ type Service struct {
 	Cache         cache.Cache
 	BridgeScraper BridgeScraper
 	VesselScraper VesselScraper
+	// History, when set, receives every scraped event for historical queries.
+	History history.EventStore
+	// Changes, when set, receives the differences between consecutive scrapes.
+	Changes *changes.Feed
+	// Sources, when set, serves the configured feeds beyond the built-in scrapers.
+	Sources *sources.Registry
+	// Upstreams are checked by Readiness, through UpstreamClient when set.
+	Upstreams      []Upstream
+	UpstreamClient httpclient.Client
+	// Breakers, when set, reports circuit breaker state to Readiness.
+	Breakers BreakerStatus
+	// ReadyTimeout bounds the checks run by Readiness; zero means 5 seconds.
+	ReadyTimeout time.Duration
+	// BridgeTimeout and VesselsTimeout bound each scrape of that source; zero means no deadline.
+	BridgeTimeout  time.Duration
+	VesselsTimeout time.Duration
+	// BridgeMaxAge and VesselsMaxAge are how old that source's data may get
+	// before Readiness reports it degraded; zero means no limit.
+	BridgeMaxAge  time.Duration
+	VesselsMaxAge time.Duration
+	// Lifetime, if set, cancels in-flight scrapes when it ends, such as on
+	// shutdown. Scrapes are otherwise detached from their callers, as a
+	// client that disconnects is not noticed.
+	Lifetime context.Context
+
+	changeMu sync.Mutex
+	flights  flightGroup // coalesces concurrent scrapes per cache key
+	freshMu  sync.Mutex
+	fresh    map[string]*keyState // keyed by cache key
 }
 
+// vesselCategories lists the categories returned by a scrape of all vessels.
+var vesselCategories = []string{"inport", "arrivals", "departures", "forecast"}
+
 // Update NewService to accept the new dependencies
 func NewService(cache cache.Cache, bridgeScraper BridgeScraper, vesselScraper VesselScraper) *Service {
 	return &Service{
@@ -46,42 +82,62 @@ func NewService(cache cache.Cache, bridgeScraper BridgeScraper, vesselScraper Ve
 	}
 }
 
-// singleton redis client for reuse
-var redisClientSingleton *redis.Client
-var redisOnce sync.Once
+// GetBridgeLifts returns bridge lift events as []Event. If the scrape fails,
+// the last known good copy is returned with each event marked stale.
+func (s *Service) GetBridgeLifts(ctx context.Context) (events []models.Event, err error) {
+	ctx, span := tracing.Start(ctx, "Service.GetBridgeLifts")
+	defer func() { tracing.End(span, err) }()
+	key := keycache.KeyBridgeLifts()
+	if err := s.Cache.Get(ctx, key, &events); err == nil {
+		metrics.CacheHits.Inc()
+		s.revalidate(ctx, key, bridgeTTL, func() { _, _ = s.scrapeBridgeLifts(context.WithoutCancel(ctx)) })
+		return events, nil
+	}
+	metrics.CacheMisses.Inc()
+	events, err = s.scrapeBridgeLifts(ctx)
+	if err != nil {
+		return s.serveStale(ctx, key, err)
+	}
+	return events, nil
+}
 
-func getRedisClient() *redis.Client {
-	redisOnce.Do(func() {
-		redisClientSingleton = redis.NewClient(&redis.Options{Addr: config.AppConfig.Redis.Address})
-	})
-	return redisClientSingleton
+// RefreshBridgeLifts scrapes bridge lifts and replaces the cached copy,
+// regardless of whether it has expired.
+func (s *Service) RefreshBridgeLifts(ctx context.Context) error {
+	_, err := s.scrapeBridgeLifts(ctx)
+	return err
 }
 
-// GetBridgeLifts returns bridge lift events as []Event.
-func (s *Service) GetBridgeLifts() ([]models.Event, error) {
-	var events []models.Event
+// scrapeBridgeLifts scrapes and caches bridge lifts; concurrent callers share one scrape.
+func (s *Service) scrapeBridgeLifts(ctx context.Context) ([]models.Event, error) {
 	key := keycache.KeyBridgeLifts()
-	if err := s.Cache.Get(key, &events); err != nil {
-		metrics.CacheMisses.Inc()
+	events, err, shared := s.flights.Do(ctx, s.Lifetime, key, func(ctx context.Context) ([]models.Event, error) {
+		ctx, cancel := withTimeout(ctx, s.BridgeTimeout)
+		defer cancel()
 		timer := prometheus.NewTimer(metrics.ScrapeDuration.WithLabelValues("bridge"))
 		metrics.ScrapeCounter.WithLabelValues("bridge").Inc()
-		l, err2 := s.BridgeScraper.ScrapeBridgeLifts()
+		events, err := s.BridgeScraper.ScrapeBridgeLifts(ctx)
 		timer.ObserveDuration()
-		if err2 != nil {
-			return nil, err2
-		}
-		events = l
-		if err3 := s.Cache.Set(key, events, 15*time.Minute); err3 != nil {
-			logger.Logger.Errorf("Failed to cache bridge_lifts: %v", err3)
+		if err != nil {
+			return nil, err
 		}
-	} else {
-		metrics.CacheHits.Inc()
+		s.recordHistory(ctx, events)
+		s.recordScrape("bridge", []string{"bridge"}, events)
+		s.detectChanges(ctx, []string{"bridge"}, events)
+		s.storeFresh(ctx, key, events, bridgeTTL)
+		return events, nil
+	})
+	if shared {
+		metrics.ScrapesCoalescedTotal.WithLabelValues("bridge").Inc()
 	}
-	return events, nil
+	return events, err
 }
 
-// GetVessels returns vessel events as []Event.
-func (s *Service) GetVessels(vesselType string) ([]models.Event, error) {
+// GetVessels returns vessel events as []Event. If the scrape fails, the last
+// known good copy is returned with each event marked stale.
+func (s *Service) GetVessels(ctx context.Context, vesselType string) (events []models.Event, err error) {
+	ctx, span := tracing.Start(ctx, "Service.GetVessels", attribute.String("vessel_type", vesselType))
+	defer func() { tracing.End(span, err) }()
 	vt := strings.ToLower(vesselType)
 	switch vt {
 	case "inport", "arrivals", "departures", "forecast", "all":
@@ -89,42 +145,227 @@ func (s *Service) GetVessels(vesselType string) ([]models.Event, error) {
 	default:
 		return nil, fmt.Errorf("invalid vesselType: %s", vesselType)
 	}
-	vesselType = vt
-	var events []models.Event
+	key := keycache.KeyVessels(vt)
+	if err := s.Cache.Get(ctx, key, &events); err == nil {
+		metrics.CacheHits.Inc()
+		s.revalidate(ctx, key, vesselsTTL, func() { _, _ = s.scrapeVessels(context.WithoutCancel(ctx), vt) })
+		return events, nil
+	}
+	metrics.CacheMisses.Inc()
+	events, err = s.scrapeVessels(ctx, vt)
+	if err != nil {
+		return s.serveStale(ctx, key, err)
+	}
+	return events, nil
+}
+
+// RefreshVessels scrapes every vessel category in a single upstream call and
+// replaces the cached "all" list as well as each per-category list.
+func (s *Service) RefreshVessels(ctx context.Context) error {
+	events, err := s.scrapeVessels(ctx, "all")
+	if err != nil {
+		return err
+	}
+	byCategory := make(map[string][]models.Event, len(vesselCategories))
+	for _, e := range events {
+		byCategory[e.Category] = append(byCategory[e.Category], e)
+	}
+	for _, category := range vesselCategories {
+		list := byCategory[category]
+		if list == nil {
+			list = []models.Event{}
+		}
+		s.storeFresh(ctx, keycache.KeyVessels(category), list, vesselsTTL)
+	}
+	return nil
+}
+
+// scrapeVessels scrapes and caches one vessel type; concurrent callers share one scrape.
+func (s *Service) scrapeVessels(ctx context.Context, vesselType string) ([]models.Event, error) {
 	key := keycache.KeyVessels(vesselType)
-	if err := s.Cache.Get(key, &events); err != nil {
-		metrics.CacheMisses.Inc()
+	events, err, shared := s.flights.Do(ctx, s.Lifetime, key, func(ctx context.Context) ([]models.Event, error) {
+		ctx, cancel := withTimeout(ctx, s.VesselsTimeout)
+		defer cancel()
 		timer := prometheus.NewTimer(metrics.ScrapeDuration.WithLabelValues("vessels"))
 		metrics.ScrapeCounter.WithLabelValues("vessels").Inc()
-		data, err2 := s.VesselScraper.ScrapeVessels(vesselType)
+		events, err := s.VesselScraper.ScrapeVessels(ctx, vesselType)
 		timer.ObserveDuration()
-		if err2 != nil {
-			return nil, err2
+		if err != nil {
+			return nil, err
 		}
-		events = data
-		if err3 := s.Cache.Set(key, events, 30*time.Minute); err3 != nil {
-			logger.Logger.Errorf("Failed to cache %s: %v", key, err3)
+		s.recordHistory(ctx, events)
+		categories := vesselCategories
+		if vesselType != "all" {
+			categories = []string{vesselType}
 		}
-	} else {
-		metrics.CacheHits.Inc()
+		s.recordScrape("vessels", categories, events)
+		s.detectChanges(ctx, categories, events)
+		s.storeFresh(ctx, key, events, vesselsTTL)
+		return events, nil
+	})
+	if shared {
+		metrics.ScrapesCoalescedTotal.WithLabelValues("vessels").Inc()
 	}
-	return events, nil
+	return events, err
+}
+
+// withTimeout bounds ctx by d, if positive.
+func withTimeout(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if d <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, d)
+}
+
+// recordHistory upserts freshly scraped events into the history store, if any.
+func (s *Service) recordHistory(ctx context.Context, events []models.Event) {
+	if s.History == nil {
+		return
+	}
+	if err := s.History.Upsert(events); err != nil {
+		metrics.HistoryErrorsTotal.Inc()
+		logger.FromContext(ctx).Errorf("Failed to record %d events in history: %v", len(events), err)
+	}
+}
+
+// reconcileHistory deletes the history records of removed events and of the
+// old times of rescheduled ones, so cancelled or moved lifts do not linger as
+// phantom records. Voyages keep their key when moved and are updated in place.
+func (s *Service) reconcileHistory(ctx context.Context, found []changes.Change) {
+	if s.History == nil {
+		return
+	}
+	var gone []models.Event
+	for _, c := range found {
+		switch {
+		case c.Type == changes.Removed:
+			gone = append(gone, c.Event)
+		case c.Type == changes.Rescheduled && c.Previous.Key() != c.Event.Key():
+			gone = append(gone, *c.Previous)
+		}
+	}
+	if err := s.History.Delete(gone); err != nil {
+		metrics.HistoryErrorsTotal.Inc()
+		logger.FromContext(ctx).Errorf("Failed to remove %d superseded events from history: %v", len(gone), err)
+	}
+}
+
+// recordScrape exports the time of a successful scrape of source and the
+// number of events it found in each of the scraped categories.
+func (s *Service) recordScrape(source string, categories []string, events []models.Event) {
+	metrics.LastSuccessfulScrape.WithLabelValues(source).SetToCurrentTime()
+	counts := make(map[string]int, len(categories))
+	for _, e := range events {
+		counts[e.Category]++
+	}
+	for _, category := range categories {
+		metrics.ScrapedEvents.WithLabelValues(source, category).Set(float64(counts[category]))
+	}
+}
+
+// snapshot is the latest scrape of one change-detection scope.
+type snapshot struct {
+	key    string // cache key of the previous snapshot
+	label  string // used in logs
+	events []models.Event
+}
+
+// detectChanges diffs freshly scraped events against the previous snapshot of
+// each scraped category and appends any differences to the change feed.
+func (s *Service) detectChanges(ctx context.Context, categories []string, events []models.Event) {
+	if s.Changes == nil && s.History == nil {
+		return
+	}
+	byCategory := make(map[string][]models.Event)
+	for _, e := range events {
+		byCategory[e.Category] = append(byCategory[e.Category], e)
+	}
+	snaps := make([]snapshot, 0, len(categories))
+	for _, category := range categories {
+		snaps = append(snaps, snapshot{key: keycache.KeySnapshot(category), label: category, events: byCategory[category]})
+	}
+	s.diffSnapshots(ctx, snaps)
+}
+
+// diffSnapshots compares each snapshot with the previous one stored under its
+// key, appends the differences to the change feed, reconciles the history
+// store with them and stores the new snapshot.
+func (s *Service) diffSnapshots(ctx context.Context, snaps []snapshot) {
+	if s.Changes == nil && s.History == nil {
+		return
+	}
+	s.changeMu.Lock()
+	defer s.changeMu.Unlock()
+	now := time.Now()
+	var found []changes.Change
+	labels := make([]string, 0, len(snaps))
+	for _, snap := range snaps {
+		labels = append(labels, snap.label)
+		var prev []models.Event
+		if err := s.Cache.Get(ctx, snap.key, &prev); err == nil {
+			if len(snap.events) == 0 && len(prev) > 0 {
+				// an empty scrape is more likely a broken page than mass cancellation
+				logger.FromContext(ctx).Warnf("Empty scrape for %s, skipping change detection", snap.label)
+				continue
+			}
+			found = append(found, changes.Diff(prev, snap.events, now)...)
+		}
+		if err := s.Cache.Set(ctx, snap.key, snap.events, 24*time.Hour); err != nil {
+			logger.FromContext(ctx).Errorf("Failed to cache %s: %v", snap.key, err)
+		}
+	}
+	s.reconcileHistory(ctx, found)
+	if s.Changes != nil {
+		for _, c := range s.Changes.Append(found) {
+			metrics.ChangesDetectedTotal.WithLabelValues(string(c.Type), c.Event.Category).Inc()
+		}
+	}
+	if len(found) > 0 {
+		logger.FromContext(ctx).Infof("Detected %d changes, categories: %s", len(found), strings.Join(labels, ","))
+	}
+}
+
+// GetChanges returns up to limit changes recorded after cursor.
+func (s *Service) GetChanges(cursor uint64, limit int) changes.Page {
+	if s.Changes == nil {
+		return changes.Page{Changes: []changes.Change{}, NextCursor: cursor}
+	}
+	return s.Changes.Since(cursor, limit)
+}
+
+// SubscribeChanges streams changes detected from now on until cancel is called.
+func (s *Service) SubscribeChanges() (<-chan changes.Change, func()) {
+	if s.Changes == nil {
+		return make(chan changes.Change), func() {}
+	}
+	return s.Changes.Subscribe(64)
+}
+
+// ErrHistoryDisabled is returned by history queries when no EventStore is configured.
+var ErrHistoryDisabled = errors.New("history store not configured")
+
+// GetHistory returns stored events with timestamps in [after, before]; zero bounds are open.
+func (s *Service) GetHistory(after, before time.Time) ([]models.Event, error) {
+	if s.History == nil {
+		return nil, ErrHistoryDisabled
+	}
+	return s.History.Query(after, before)
 }
 
 // Add caching for filtered vessels by type and location
-func (s *Service) GetFilteredVessels(vesselType, location string) ([]models.Event, error) {
+func (s *Service) GetFilteredVessels(ctx context.Context, vesselType, location string) ([]models.Event, error) {
 	vt := strings.ToLower(vesselType)
 	if strings.TrimSpace(location) == "" || vt == "all" {
-		return s.GetVessels(vt)
+		return s.GetVessels(ctx, vt)
 	}
 	key := keycache.KeyVesselsByLoc(vt, location)
 	events := make([]models.Event, 0)
-	if err := s.Cache.Get(key, &events); err == nil {
+	if err := s.Cache.Get(ctx, key, &events); err == nil {
 		metrics.CacheHits.Inc()
 		return events, nil
 	}
 	metrics.CacheMisses.Inc()
-	raw, err := s.GetVessels(vt)
+	raw, err := s.GetVessels(ctx, vt)
 	if err != nil {
 		return nil, err
 	}
@@ -136,9 +377,13 @@ func (s *Service) GetFilteredVessels(vesselType, location string) ([]models.Even
 			filtered = append(filtered, e)
 		}
 	}
-	logger.Logger.Infof("Retrieved filtered events from API, type: %s location: %s, count: %d", vt, location, len(filtered))
-	if err := s.Cache.Set(key, filtered, 30*time.Minute); err != nil {
-		logger.Logger.Errorf("Failed to cache %s: %v", key, err)
+	logger.FromContext(ctx).Infof("Retrieved filtered events from API, type: %s location: %s, count: %d", vt, location, len(filtered))
+	if len(raw) > 0 && raw[0].Stale {
+		// don't let stale data outlive the upstream outage in the filtered cache
+		return filtered, nil
+	}
+	if err := s.Cache.Set(ctx, key, filtered, 30*time.Minute); err != nil {
+		logger.FromContext(ctx).Errorf("Failed to cache %s: %v", key, err)
 	}
 	return filtered, nil
 }
@@ -155,8 +400,8 @@ type LocationStats struct {
 }
 
 // ListLocations aggregates event counts by location.
-func (s *Service) ListLocations() ([]LocationStats, error) {
-	events, err := s.GetVessels("all")
+func (s *Service) ListLocations(ctx context.Context) ([]LocationStats, error) {
+	events, err := s.GetVessels(ctx, "all")
 	if err != nil {
 		return nil, err
 	}
@@ -208,42 +453,3 @@ func (s *Service) ListLocations() ([]LocationStats, error) {
 func (s *Service) HealthCheck(ctx context.Context) error {
 	return nil
 }
-
-// ReadyCheck verifies dependency readiness.
-func (s *Service) ReadyCheck(ctx context.Context) error {
-	if strings.TrimSpace(config.AppConfig.Redis.Address) != "" {
-		rdb := getRedisClient()
-		if err := rdb.Ping(ctx).Err(); err != nil {
-			return fmt.Errorf("redis ping failed: %w", err)
-		}
-	}
-
-	client := &http.Client{Timeout: 10 * time.Second}
-	req, err := http.NewRequestWithContext(ctx, http.MethodHead, config.AppConfig.URLs.PortOfLondon, nil)
-	if err != nil {
-		return fmt.Errorf("creating HEAD request failed: %w", err)
-	}
-	resp, err := client.Do(req)
-	if err != nil {
-		return fmt.Errorf("external API HEAD failed: %w", err)
-	}
-	resp.Body.Close()
-
-	if resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented {
-		req, err = http.NewRequestWithContext(ctx, http.MethodGet, config.AppConfig.URLs.PortOfLondon, nil)
-		if err != nil {
-			return fmt.Errorf("creating GET request failed: %w", err)
-		}
-		resp, err = client.Do(req)
-		if err != nil {
-			return fmt.Errorf("external API GET failed: %w", err)
-		}
-		resp.Body.Close()
-	}
-
-	if resp.StatusCode >= 400 {
-		return fmt.Errorf("external API returned status %d", resp.StatusCode)
-	}
-
-	return nil
-}
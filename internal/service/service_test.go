@@ -1,17 +1,25 @@
 package service_test
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"os"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	keycache "github.com/Takenobou/thamestracker/internal/cache"
+	"github.com/Takenobou/thamestracker/internal/changes"
 	"github.com/Takenobou/thamestracker/internal/helpers/logger"
 	"github.com/Takenobou/thamestracker/internal/models"
 	service "github.com/Takenobou/thamestracker/internal/service"
 	"github.com/stretchr/testify/assert"
 )
 
+var ctx = context.Background()
+
 func TestMain(m *testing.M) {
 	logger.InitLogger()
 	os.Exit(m.Run())
@@ -19,6 +27,7 @@ func TestMain(m *testing.M) {
 
 // --- Fakes for dependency injection ---
 type fakeCache struct {
+	mu      sync.Mutex
 	store   map[string]interface{}
 	failSet bool
 	failGet bool
@@ -27,14 +36,18 @@ type fakeCache struct {
 func newFakeCache() *fakeCache {
 	return &fakeCache{store: make(map[string]interface{})}
 }
-func (f *fakeCache) Set(key string, value interface{}, ttl time.Duration) error {
+func (f *fakeCache) Set(_ context.Context, key string, value interface{}, ttl time.Duration) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
 	if f.failSet {
 		return errors.New("fail set")
 	}
 	f.store[key] = value
 	return nil
 }
-func (f *fakeCache) Get(key string, dest interface{}) error {
+func (f *fakeCache) Get(_ context.Context, key string, dest interface{}) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
 	if f.failGet {
 		return errors.New("fail get")
 	}
@@ -46,6 +59,12 @@ func (f *fakeCache) Get(key string, dest interface{}) error {
 	switch d := dest.(type) {
 	case *[]models.Event:
 		*d = v.([]models.Event)
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(data, dest)
 	}
 	return nil
 }
@@ -56,7 +75,7 @@ type fakeBridgeScraper struct {
 	called *bool
 }
 
-func (f *fakeBridgeScraper) ScrapeBridgeLifts() ([]models.Event, error) {
+func (f *fakeBridgeScraper) ScrapeBridgeLifts(context.Context) ([]models.Event, error) {
 	if f.called != nil {
 		*f.called = true
 	}
@@ -68,7 +87,7 @@ type fakeVesselScraper struct {
 	err    error
 }
 
-func (f *fakeVesselScraper) ScrapeVessels(vesselType string) ([]models.Event, error) {
+func (f *fakeVesselScraper) ScrapeVessels(_ context.Context, vesselType string) ([]models.Event, error) {
 	if f.err != nil {
 		return nil, f.err
 	}
@@ -77,9 +96,9 @@ func (f *fakeVesselScraper) ScrapeVessels(vesselType string) ([]models.Event, er
 
 func TestGetBridgeLifts_CacheHit(t *testing.T) {
 	cache := newFakeCache()
-	cache.Set("bridge_lifts", []models.Event{{VesselName: "Cached"}}, 0)
+	cache.Set(ctx, "bridge_lifts", []models.Event{{VesselName: "Cached"}}, 0)
 	svc := service.NewService(cache, &fakeBridgeScraper{}, &fakeVesselScraper{})
-	res, err := svc.GetBridgeLifts()
+	res, err := svc.GetBridgeLifts(ctx)
 	assert.NoError(t, err)
 	assert.Equal(t, "Cached", res[0].VesselName)
 }
@@ -91,7 +110,7 @@ func TestGetBridgeLifts_CacheMissAndScraper(t *testing.T) {
 		result: []models.Event{{VesselName: "Scraped"}},
 		called: &called,
 	}, &fakeVesselScraper{})
-	res, err := svc.GetBridgeLifts()
+	res, err := svc.GetBridgeLifts(ctx)
 	assert.NoError(t, err)
 	assert.True(t, called)
 	assert.Equal(t, "Scraped", res[0].VesselName)
@@ -100,7 +119,7 @@ func TestGetBridgeLifts_CacheMissAndScraper(t *testing.T) {
 func TestGetBridgeLifts_ScraperError(t *testing.T) {
 	cache := newFakeCache()
 	svc := service.NewService(cache, &fakeBridgeScraper{err: errors.New("fail")}, &fakeVesselScraper{})
-	_, err := svc.GetBridgeLifts()
+	_, err := svc.GetBridgeLifts(ctx)
 	assert.Error(t, err)
 }
 
@@ -108,7 +127,7 @@ func TestGetBridgeLifts_CacheSetError(t *testing.T) {
 	cache := newFakeCache()
 	cache.failSet = true
 	svc := service.NewService(cache, &fakeBridgeScraper{result: []models.Event{{VesselName: "Scraped"}}}, &fakeVesselScraper{})
-	res, err := svc.GetBridgeLifts()
+	res, err := svc.GetBridgeLifts(ctx)
 	assert.NoError(t, err)
 	assert.Len(t, res, 1)
 	assert.Equal(t, "Scraped", res[0].VesselName)
@@ -125,7 +144,7 @@ func TestGetVessels_AllTypes(t *testing.T) {
 	}
 	svc := service.NewService(cache, &fakeBridgeScraper{}, &fakeVesselScraper{result: results})
 	for _, typ := range []string{"inport", "arrivals", "departures", "forecast", "all"} {
-		res, err := svc.GetVessels(typ)
+		res, err := svc.GetVessels(ctx, typ)
 		assert.NoError(t, err)
 		assert.Equal(t, typ, res[0].VesselName)
 	}
@@ -134,15 +153,15 @@ func TestGetVessels_AllTypes(t *testing.T) {
 func TestGetVessels_InvalidType(t *testing.T) {
 	cache := newFakeCache()
 	svc := service.NewService(cache, &fakeBridgeScraper{}, &fakeVesselScraper{})
-	_, err := svc.GetVessels("badtype")
+	_, err := svc.GetVessels(ctx, "badtype")
 	assert.Error(t, err)
 }
 
 func TestGetVessels_CacheHit(t *testing.T) {
 	cache := newFakeCache()
-	cache.Set("v3_vessels_inport", []models.Event{{VesselName: "Cached"}}, 0)
+	cache.Set(ctx, "v3_vessels_inport", []models.Event{{VesselName: "Cached"}}, 0)
 	svc := service.NewService(cache, &fakeBridgeScraper{}, &fakeVesselScraper{})
-	res, err := svc.GetVessels("inport")
+	res, err := svc.GetVessels(ctx, "inport")
 	assert.NoError(t, err)
 	assert.Equal(t, "Cached", res[0].VesselName)
 }
@@ -150,7 +169,7 @@ func TestGetVessels_CacheHit(t *testing.T) {
 func TestGetVessels_ScraperError(t *testing.T) {
 	cache := newFakeCache()
 	svc := service.NewService(cache, &fakeBridgeScraper{}, &fakeVesselScraper{err: errors.New("fail")})
-	_, err := svc.GetVessels("inport")
+	_, err := svc.GetVessels(ctx, "inport")
 	assert.Error(t, err)
 }
 
@@ -159,7 +178,7 @@ func TestGetVessels_CacheSetError(t *testing.T) {
 	cache.failSet = true
 	results := map[string][]models.Event{"inport": {{VesselName: "Scraped"}}}
 	svc := service.NewService(cache, &fakeBridgeScraper{}, &fakeVesselScraper{result: results})
-	res, err := svc.GetVessels("inport")
+	res, err := svc.GetVessels(ctx, "inport")
 	assert.NoError(t, err)
 	assert.Len(t, res, 1)
 	assert.Equal(t, "Scraped", res[0].VesselName)
@@ -169,16 +188,16 @@ func TestGetFilteredVessels_NoLocation(t *testing.T) {
 	cache := newFakeCache()
 	results := map[string][]models.Event{"inport": {{VesselName: "A"}}}
 	svc := service.NewService(cache, &fakeBridgeScraper{}, &fakeVesselScraper{result: results})
-	res, err := svc.GetFilteredVessels("inport", "")
+	res, err := svc.GetFilteredVessels(ctx, "inport", "")
 	assert.NoError(t, err)
 	assert.Equal(t, "A", res[0].VesselName)
 }
 
 func TestGetFilteredVessels_CacheHit(t *testing.T) {
 	cache := newFakeCache()
-	cache.Set("v3_vessels_inport_location_L1", []models.Event{{VesselName: "Cached", Location: "L1"}}, 0)
+	cache.Set(ctx, "v3_vessels_inport_location_L1", []models.Event{{VesselName: "Cached", Location: "L1"}}, 0)
 	svc := service.NewService(cache, &fakeBridgeScraper{}, &fakeVesselScraper{})
-	res, err := svc.GetFilteredVessels("inport", "L1")
+	res, err := svc.GetFilteredVessels(ctx, "inport", "L1")
 	assert.NoError(t, err)
 	assert.Equal(t, "Cached", res[0].VesselName)
 }
@@ -187,7 +206,7 @@ func TestGetFilteredVessels_CacheMissAndFilter(t *testing.T) {
 	cache := newFakeCache()
 	results := map[string][]models.Event{"inport": {{VesselName: "A", Location: "L1"}, {VesselName: "B", Location: "L2"}}}
 	svc := service.NewService(cache, &fakeBridgeScraper{}, &fakeVesselScraper{result: results})
-	res, err := svc.GetFilteredVessels("inport", "L1")
+	res, err := svc.GetFilteredVessels(ctx, "inport", "L1")
 	assert.NoError(t, err)
 	assert.Len(t, res, 1)
 	assert.Equal(t, "L1", res[0].Location)
@@ -202,7 +221,7 @@ func TestListLocations(t *testing.T) {
 		{VesselName: "D", Category: "forecast", To: "Port2"},
 	}}
 	svc := service.NewService(cache, &fakeBridgeScraper{}, &fakeVesselScraper{result: results})
-	locs, err := svc.ListLocations()
+	locs, err := svc.ListLocations(ctx)
 	assert.NoError(t, err)
 	assert.Len(t, locs, 2)
 	var names []string
@@ -216,6 +235,218 @@ func TestListLocations(t *testing.T) {
 func TestListLocations_Error(t *testing.T) {
 	cache := newFakeCache()
 	svc := service.NewService(cache, &fakeBridgeScraper{}, &fakeVesselScraper{err: errors.New("fail")})
-	_, err := svc.ListLocations()
+	_, err := svc.ListLocations(ctx)
 	assert.Error(t, err)
 }
+
+type fakeHistory struct {
+	upserted []models.Event
+	deleted  []models.Event
+}
+
+func (f *fakeHistory) Upsert(events []models.Event) error {
+	f.upserted = append(f.upserted, events...)
+	return nil
+}
+func (f *fakeHistory) Query(after, before time.Time) ([]models.Event, error) {
+	return f.upserted, nil
+}
+func (f *fakeHistory) Delete(events []models.Event) error {
+	f.deleted = append(f.deleted, events...)
+	return nil
+}
+func (f *fakeHistory) Close() error { return nil }
+
+func TestScrapesAreRecordedInHistory(t *testing.T) {
+	cache := newFakeCache()
+	hist := &fakeHistory{}
+	results := map[string][]models.Event{"inport": {{VesselName: "V", Category: "inport"}}}
+	svc := service.NewService(cache, &fakeBridgeScraper{result: []models.Event{{VesselName: "B", Category: "bridge"}}}, &fakeVesselScraper{result: results})
+	svc.History = hist
+
+	_, err := svc.GetBridgeLifts(ctx)
+	assert.NoError(t, err)
+	_, err = svc.GetVessels(ctx, "inport")
+	assert.NoError(t, err)
+	// cache hits must not re-record
+	_, _ = svc.GetBridgeLifts(ctx)
+	assert.Len(t, hist.upserted, 2)
+}
+
+func TestBridgeScrapes_ReconcileHistory(t *testing.T) {
+	cache := newFakeCache()
+	hist := &fakeHistory{}
+	future := time.Now().Add(time.Hour)
+	moved := models.Event{Timestamp: future, VesselName: "A", Category: "bridge", Direction: "Up river"}
+	cancelled := models.Event{Timestamp: future, VesselName: "B", Category: "bridge", Direction: "Up river"}
+	scraper := &fakeBridgeScraper{result: []models.Event{moved, cancelled}}
+	svc := service.NewService(cache, scraper, &fakeVesselScraper{})
+	svc.History = hist
+
+	_, err := svc.GetBridgeLifts(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, hist.deleted)
+
+	delete(cache.store, "bridge_lifts")
+	later := moved
+	later.Timestamp = future.Add(30 * time.Minute)
+	scraper.result = []models.Event{later}
+	_, err = svc.GetBridgeLifts(ctx)
+	assert.NoError(t, err)
+	keys := []string{}
+	for _, e := range hist.deleted {
+		keys = append(keys, e.Key())
+	}
+	assert.ElementsMatch(t, []string{moved.Key(), cancelled.Key()}, keys)
+}
+
+func TestBridgeScrapes_FeedChanges(t *testing.T) {
+	cache := newFakeCache()
+	future := time.Now().Add(time.Hour)
+	scraper := &fakeBridgeScraper{result: []models.Event{
+		{Timestamp: future, VesselName: "A", Category: "bridge", Direction: "Up river"},
+		{Timestamp: future, VesselName: "B", Category: "bridge", Direction: "Up river"},
+	}}
+	svc := service.NewService(cache, scraper, &fakeVesselScraper{})
+	svc.Changes = changes.NewFeed(10)
+
+	_, err := svc.GetBridgeLifts(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, svc.GetChanges(0, 10).Changes, "first scrape is the baseline")
+
+	delete(cache.store, "bridge_lifts")
+	scraper.result = []models.Event{
+		{Timestamp: future.Add(30 * time.Minute), VesselName: "A", Category: "bridge", Direction: "Up river"},
+		{Timestamp: future, VesselName: "C", Category: "bridge", Direction: "Down river"},
+	}
+	_, err = svc.GetBridgeLifts(ctx)
+	assert.NoError(t, err)
+	page := svc.GetChanges(0, 10)
+	types := map[changes.Type]string{}
+	for _, c := range page.Changes {
+		types[c.Type] = c.Event.VesselName
+	}
+	assert.Equal(t, "A", types[changes.Rescheduled])
+	assert.Equal(t, "C", types[changes.Added])
+	assert.Equal(t, "B", types[changes.Removed])
+}
+
+func TestRefreshBridgeLifts_ReplacesCachedCopy(t *testing.T) {
+	cache := newFakeCache()
+	cache.store[keycache.KeyBridgeLifts()] = []models.Event{{VesselName: "Old"}}
+	svc := service.NewService(cache, &fakeBridgeScraper{result: []models.Event{{VesselName: "New"}}}, &fakeVesselScraper{})
+	assert.NoError(t, svc.RefreshBridgeLifts(ctx))
+	events, err := svc.GetBridgeLifts(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "New", events[0].VesselName)
+}
+
+func TestRefreshVessels_PopulatesEveryCategory(t *testing.T) {
+	cache := newFakeCache()
+	all := []models.Event{
+		{VesselName: "A", Category: "inport"},
+		{VesselName: "B", Category: "arrivals"},
+		{VesselName: "C", Category: "arrivals"},
+	}
+	svc := service.NewService(cache, &fakeBridgeScraper{}, &fakeVesselScraper{result: map[string][]models.Event{"all": all}})
+	assert.NoError(t, svc.RefreshVessels(ctx))
+
+	// served from cache: the fake scraper only knows "all"
+	arrivals, err := svc.GetVessels(ctx, "arrivals")
+	assert.NoError(t, err)
+	assert.Len(t, arrivals, 2)
+	forecast, err := svc.GetVessels(ctx, "forecast")
+	assert.NoError(t, err)
+	assert.Empty(t, forecast)
+	everything, err := svc.GetVessels(ctx, "all")
+	assert.NoError(t, err)
+	assert.Len(t, everything, 3)
+}
+
+func TestRefreshVessels_ScraperError(t *testing.T) {
+	svc := service.NewService(newFakeCache(), &fakeBridgeScraper{}, &fakeVesselScraper{err: errors.New("fail")})
+	assert.Error(t, svc.RefreshVessels(ctx))
+}
+
+// blockingBridgeScraper counts calls and blocks until release is closed.
+type blockingBridgeScraper struct {
+	calls   int32
+	release chan struct{}
+	err     error
+}
+
+func (b *blockingBridgeScraper) ScrapeBridgeLifts(context.Context) ([]models.Event, error) {
+	atomic.AddInt32(&b.calls, 1)
+	<-b.release
+	if b.err != nil {
+		return nil, b.err
+	}
+	return []models.Event{{VesselName: "Coalesced", Category: "bridge"}}, nil
+}
+
+func TestGetBridgeLifts_CoalescesConcurrentMisses(t *testing.T) {
+	for _, scrapeErr := range []error{nil, errors.New("upstream down")} {
+		scraper := &blockingBridgeScraper{release: make(chan struct{}), err: scrapeErr}
+		svc := service.NewService(newFakeCache(), scraper, &fakeVesselScraper{})
+
+		const callers = 10
+		var wg sync.WaitGroup
+		results := make([][]models.Event, callers)
+		errs := make([]error, callers)
+		for i := 0; i < callers; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				results[i], errs[i] = svc.GetBridgeLifts(ctx)
+			}(i)
+		}
+		// let every caller reach the in-flight scrape before it finishes
+		assert.Eventually(t, func() bool { return atomic.LoadInt32(&scraper.calls) == 1 }, time.Second, time.Millisecond)
+		time.Sleep(20 * time.Millisecond)
+		close(scraper.release)
+		wg.Wait()
+
+		assert.Equal(t, int32(1), atomic.LoadInt32(&scraper.calls))
+		for i := 0; i < callers; i++ {
+			if scrapeErr != nil {
+				assert.EqualError(t, errs[i], scrapeErr.Error())
+				continue
+			}
+			assert.NoError(t, errs[i])
+			assert.Equal(t, "Coalesced", results[i][0].VesselName)
+		}
+	}
+}
+
+func TestGetVessels_ServesStaleOnScrapeError(t *testing.T) {
+	cache := newFakeCache()
+	scraper := &fakeVesselScraper{result: map[string][]models.Event{"inport": {{VesselName: "A", Category: "inport"}}}}
+	svc := service.NewService(cache, &fakeBridgeScraper{}, scraper)
+	_, err := svc.GetVessels(ctx, "inport")
+	assert.NoError(t, err)
+	assert.False(t, svc.DataFreshness("inport").Stale)
+
+	// the TTL'd entry expires and the upstream goes down
+	delete(cache.store, keycache.KeyVessels("inport"))
+	scraper.err = errors.New("pla down")
+	events, err := svc.GetVessels(ctx, "inport")
+	assert.NoError(t, err)
+	assert.Len(t, events, 1)
+	assert.True(t, events[0].Stale)
+	f := svc.DataFreshness("inport")
+	assert.True(t, f.Stale)
+	assert.False(t, f.UpdatedAt.IsZero())
+
+	// recovery clears the stale flag
+	scraper.err = nil
+	events, err = svc.GetVessels(ctx, "inport")
+	assert.NoError(t, err)
+	assert.False(t, events[0].Stale)
+	assert.False(t, svc.DataFreshness("inport").Stale)
+}
+
+func TestGetBridgeLifts_ErrorWithoutLastGood(t *testing.T) {
+	svc := service.NewService(newFakeCache(), &fakeBridgeScraper{err: errors.New("down")}, &fakeVesselScraper{})
+	_, err := svc.GetBridgeLifts(ctx)
+	assert.EqualError(t, err, "down")
+}
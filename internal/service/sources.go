@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	keycache "github.com/Takenobou/thamestracker/internal/cache"
+	"github.com/Takenobou/thamestracker/internal/helpers/logger"
+	"github.com/Takenobou/thamestracker/internal/helpers/metrics"
+	"github.com/Takenobou/thamestracker/internal/models"
+	"github.com/Takenobou/thamestracker/internal/sources"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ListSources describes the configured sources, sorted by name.
+func (s *Service) ListSources() []sources.Info {
+	if s.Sources == nil {
+		return []sources.Info{}
+	}
+	return s.Sources.List()
+}
+
+// GetSourceEvents returns the events of the named source, or of every
+// configured source when name is empty. If a scrape fails, the last known
+// good copy is returned with each event marked stale. Across every source, one
+// with neither is logged and left out; an error is returned only when all fail.
+func (s *Service) GetSourceEvents(ctx context.Context, name string) ([]models.Event, error) {
+	if name == "" {
+		var all []models.Event
+		var firstErr error
+		failed := 0
+		infos := s.ListSources()
+		for _, info := range infos {
+			events, err := s.GetSourceEvents(ctx, info.Name)
+			if err != nil {
+				logger.FromContext(ctx).Warnf("Leaving out source %s: %v", info.Name, err)
+				if firstErr == nil {
+					firstErr = fmt.Errorf("source %s: %w", info.Name, err)
+				}
+				failed++
+				continue
+			}
+			all = append(all, events...)
+		}
+		if failed > 0 && failed == len(infos) {
+			return nil, firstErr
+		}
+		return all, nil
+	}
+	entry, err := s.source(name)
+	if err != nil {
+		return nil, err
+	}
+	var events []models.Event
+	key := keycache.KeySource(name)
+	if err := s.Cache.Get(ctx, key, &events); err == nil {
+		metrics.CacheHits.Inc()
+		s.revalidate(ctx, key, entry.TTL(), func() { _, _ = s.scrapeSource(context.WithoutCancel(ctx), entry) })
+		return events, nil
+	}
+	metrics.CacheMisses.Inc()
+	events, err = s.scrapeSource(ctx, entry)
+	if err != nil {
+		return s.serveStale(ctx, key, err)
+	}
+	return events, nil
+}
+
+// RefreshSource scrapes the named source and replaces its cached copy,
+// regardless of whether it has expired.
+func (s *Service) RefreshSource(ctx context.Context, name string) error {
+	entry, err := s.source(name)
+	if err != nil {
+		return err
+	}
+	_, err = s.scrapeSource(ctx, entry)
+	return err
+}
+
+func (s *Service) source(name string) (*sources.Entry, error) {
+	if s.Sources != nil {
+		if entry, ok := s.Sources.Get(name); ok {
+			return entry, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", sources.ErrUnknownSource, name)
+}
+
+// scrapeSource scrapes and caches one source; concurrent callers share one scrape.
+func (s *Service) scrapeSource(ctx context.Context, entry *sources.Entry) ([]models.Event, error) {
+	key := keycache.KeySource(entry.Name)
+	events, err, shared := s.flights.Do(ctx, s.Lifetime, key, func(ctx context.Context) ([]models.Event, error) {
+		ctx, cancel := withTimeout(ctx, entry.Timeout())
+		defer cancel()
+		timer := prometheus.NewTimer(metrics.ScrapeDuration.WithLabelValues(entry.Name))
+		metrics.ScrapeCounter.WithLabelValues(entry.Name).Inc()
+		events, err := entry.Fetch(ctx)
+		timer.ObserveDuration()
+		if err != nil {
+			return nil, err
+		}
+		s.recordHistory(ctx, events)
+		s.recordScrape(entry.Name, []string{entry.Category}, events)
+		s.diffSnapshots(ctx, []snapshot{{key: keycache.KeySnapshot("source_" + entry.Name), label: entry.Name, events: events}})
+		s.storeFresh(ctx, key, events, entry.TTL())
+		return events, nil
+	})
+	if shared {
+		metrics.ScrapesCoalescedTotal.WithLabelValues(entry.Name).Inc()
+	}
+	return events, err
+}
@@ -0,0 +1,119 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	keycache "github.com/Takenobou/thamestracker/internal/cache"
+	"github.com/Takenobou/thamestracker/internal/changes"
+	"github.com/Takenobou/thamestracker/internal/models"
+	service "github.com/Takenobou/thamestracker/internal/service"
+	"github.com/Takenobou/thamestracker/internal/sources"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeSource returns result, or err when set, and counts its fetches.
+type fakeSource struct {
+	result []models.Event
+	err    error
+	calls  int
+}
+
+func (f *fakeSource) Fetch(context.Context) ([]models.Event, error) {
+	f.calls++
+	if f.err != nil {
+		return nil, f.err
+	}
+	return append([]models.Event(nil), f.result...), nil
+}
+
+func newSourceService(t *testing.T, src sources.Source) (*service.Service, *fakeCache) {
+	t.Helper()
+	cache := newFakeCache()
+	reg := sources.NewRegistry(sources.Options{})
+	assert.NoError(t, reg.Register(src, sources.Info{Name: "thames-barrier", Category: "barrier", TTLSeconds: 3600}))
+	svc := service.NewService(cache, &fakeBridgeScraper{}, &fakeVesselScraper{})
+	svc.Sources = reg
+	return svc, cache
+}
+
+func TestGetSourceEvents_CachesUnderSourceKey(t *testing.T) {
+	src := &fakeSource{result: []models.Event{{Timestamp: time.Now(), VesselName: "Barrier closed"}}}
+	svc, cache := newSourceService(t, src)
+
+	events, err := svc.GetSourceEvents(ctx, "thames-barrier")
+	assert.NoError(t, err)
+	assert.Len(t, events, 1)
+	assert.Equal(t, "thames-barrier", events[0].Source)
+	assert.Equal(t, "barrier", events[0].Category)
+	assert.Contains(t, cache.store, keycache.KeySource("thames-barrier"))
+	assert.NotContains(t, cache.store, keycache.KeyBridgeLifts())
+
+	_, err = svc.GetSourceEvents(ctx, "thames-barrier")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, src.calls, "second call is served from cache")
+	assert.False(t, svc.DataFreshness("thames-barrier").UpdatedAt.IsZero())
+
+	all, err := svc.GetSourceEvents(ctx, "")
+	assert.NoError(t, err)
+	assert.Len(t, all, 1)
+}
+
+func TestGetSourceEvents_Unknown(t *testing.T) {
+	svc, _ := newSourceService(t, &fakeSource{})
+	_, err := svc.GetSourceEvents(ctx, "nope")
+	assert.ErrorIs(t, err, sources.ErrUnknownSource)
+	assert.ErrorIs(t, svc.RefreshSource(ctx, "nope"), sources.ErrUnknownSource)
+
+	plain := service.NewService(newFakeCache(), &fakeBridgeScraper{}, &fakeVesselScraper{})
+	assert.Empty(t, plain.ListSources())
+	events, err := plain.GetSourceEvents(ctx, "")
+	assert.NoError(t, err)
+	assert.Empty(t, events)
+}
+
+func TestGetSourceEvents_ServesStaleAndFeedsChanges(t *testing.T) {
+	future := time.Now().Add(time.Hour)
+	src := &fakeSource{result: []models.Event{{Timestamp: future, VesselName: "Barrier closed"}}}
+	svc, cache := newSourceService(t, src)
+	svc.Changes = changes.NewFeed(10)
+
+	assert.NoError(t, svc.RefreshSource(ctx, "thames-barrier"))
+	src.result = append(src.result, models.Event{Timestamp: future.Add(time.Hour), VesselName: "Barrier open"})
+	assert.NoError(t, svc.RefreshSource(ctx, "thames-barrier"))
+	page := svc.GetChanges(0, 10)
+	assert.Len(t, page.Changes, 1)
+	assert.Equal(t, changes.Added, page.Changes[0].Type)
+	assert.Equal(t, "thames-barrier", page.Changes[0].Event.Source)
+
+	delete(cache.store, keycache.KeySource("thames-barrier"))
+	src.err = errors.New("down")
+	events, err := svc.GetSourceEvents(ctx, "thames-barrier")
+	assert.NoError(t, err)
+	assert.Len(t, events, 2)
+	assert.True(t, events[0].Stale)
+	assert.True(t, svc.DataFreshness("thames-barrier").Stale)
+}
+
+func TestGetSourceEvents_AllSkipsFailingSource(t *testing.T) {
+	ok := &fakeSource{result: []models.Event{{Timestamp: time.Now(), VesselName: "Barrier closed"}}}
+	svc, cache := newSourceService(t, ok)
+	down := &fakeSource{err: errors.New("down")}
+	assert.NoError(t, svc.Sources.Register(down, sources.Info{Name: "woolwich-ferry", Category: "ferry", TTLSeconds: 3600}))
+
+	all, err := svc.GetSourceEvents(ctx, "")
+	assert.NoError(t, err)
+	assert.Len(t, all, 1)
+	assert.Equal(t, "thames-barrier", all[0].Source)
+
+	_, err = svc.GetSourceEvents(ctx, "woolwich-ferry")
+	assert.Error(t, err, "the failing source on its own still errors")
+
+	// with every source failing there is nothing to serve
+	clear(cache.store)
+	ok.err = errors.New("down too")
+	_, err = svc.GetSourceEvents(ctx, "")
+	assert.Error(t, err)
+}
@@ -0,0 +1,135 @@
+package sources
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/Takenobou/thamestracker/internal/helpers/httpclient"
+	"github.com/Takenobou/thamestracker/internal/scraper/selectors"
+)
+
+// Definition configures one source in the sources file.
+type Definition struct {
+	Name           string `json:"name"`
+	Type           string `json:"type"` // "json" or "html"
+	Category       string `json:"category"`
+	Location       string `json:"location"`
+	TTLSeconds     int    `json:"ttl_seconds"`
+	RefreshSeconds int    `json:"refresh_seconds"`
+	TimeoutSeconds int    `json:"timeout_seconds"`
+	MaxAgeSeconds  int    `json:"max_age_seconds"`
+	Breaker        struct {
+		MaxFailures    int `json:"max_failures"`
+		CoolOffSeconds int `json:"cool_off_seconds"`
+	} `json:"breaker"`
+
+	// json sources
+	URL        string            `json:"url"`
+	File       string            `json:"file"`
+	Items      string            `json:"items"`
+	Fields     map[string]string `json:"fields"`
+	TimeFormat string            `json:"time_format"`
+
+	// html sources: an inline selector definition, or a YAML/JSON file holding one
+	Scraper        *selectors.Definition `json:"scraper"`
+	DefinitionFile string                `json:"definition_file"`
+}
+
+// File is the layout of the sources file.
+type File struct {
+	Sources []Definition `json:"sources"`
+}
+
+// Load reads the sources file at path and registers every source it defines,
+// fetching URLs with client.
+func Load(path string, client httpclient.Client, opts Options) (*Registry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading sources file: %w", err)
+	}
+	var f File
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("parsing sources file: %w", err)
+	}
+	r := NewRegistry(opts)
+	for _, def := range f.Sources {
+		src, err := build(def, client)
+		if err != nil {
+			return nil, fmt.Errorf("source %q: %w", def.Name, err)
+		}
+		if err := r.Register(src, def.info()); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// build creates the Source described by def.
+func build(def Definition, client httpclient.Client) (Source, error) {
+	switch def.Type {
+	case "json", "":
+		if (def.URL == "") == (def.File == "") {
+			return nil, fmt.Errorf("exactly one of url and file is required")
+		}
+		return &JSONSource{
+			URL:        def.URL,
+			File:       def.File,
+			Items:      def.Items,
+			Fields:     def.Fields,
+			TimeFormat: def.TimeFormat,
+			Client:     client,
+		}, nil
+	case "html":
+		var sd selectors.Definition
+		switch {
+		case def.Scraper != nil && def.DefinitionFile != "":
+			return nil, fmt.Errorf("only one of scraper and definition_file may be set")
+		case def.Scraper != nil:
+			sd = *def.Scraper
+		case def.DefinitionFile != "":
+			loaded, err := selectors.Load(def.DefinitionFile)
+			if err != nil {
+				return nil, err
+			}
+			sd = loaded
+		default:
+			return nil, fmt.Errorf("scraper or definition_file is required")
+		}
+		if def.URL != "" {
+			sd.URL = def.URL
+		}
+		if sd.URL == "" {
+			return nil, fmt.Errorf("url is required")
+		}
+		if err := sd.Validate(); err != nil {
+			return nil, err
+		}
+		src := &HTMLSource{Definition: sd}
+		if client != nil {
+			src.Transport = httpclient.AsTransport(client)
+		}
+		return src, nil
+	default:
+		return nil, fmt.Errorf("unknown type %q", def.Type)
+	}
+}
+
+func (def Definition) info() Info {
+	typ := def.Type
+	if typ == "" {
+		typ = "json"
+	}
+	return Info{
+		Name:           def.Name,
+		Type:           typ,
+		Category:       def.Category,
+		Location:       def.Location,
+		TTLSeconds:     def.TTLSeconds,
+		RefreshSeconds: def.RefreshSeconds,
+		TimeoutSeconds: def.TimeoutSeconds,
+		MaxAgeSeconds:  def.MaxAgeSeconds,
+		MaxFailures:    def.Breaker.MaxFailures,
+		CoolOffSeconds: def.Breaker.CoolOffSeconds,
+	}
+}
@@ -0,0 +1,27 @@
+package sources
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/Takenobou/thamestracker/internal/models"
+	"github.com/Takenobou/thamestracker/internal/scraper/selectors"
+	"github.com/Takenobou/thamestracker/internal/validation"
+)
+
+// HTMLSource scrapes events from an HTML page described by a selector
+// definition, such as another bridge operator's lift schedule.
+type HTMLSource struct {
+	Definition selectors.Definition
+	Transport  http.RoundTripper // nil uses http.DefaultTransport
+}
+
+// Fetch scrapes the page and any following pages.
+func (s *HTMLSource) Fetch(ctx context.Context) ([]models.Event, error) {
+	return selectors.Scrape(ctx, s.Definition, s.Transport)
+}
+
+// FetchWithStats is Fetch, also reporting the rows seen and skipped.
+func (s *HTMLSource) FetchWithStats(ctx context.Context) ([]models.Event, validation.Stats, error) {
+	return selectors.ScrapeWithStats(ctx, s.Definition, s.Transport)
+}
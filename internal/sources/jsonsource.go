@@ -0,0 +1,162 @@
+package sources
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Takenobou/thamestracker/internal/helpers/httpclient"
+	"github.com/Takenobou/thamestracker/internal/helpers/logger"
+	"github.com/Takenobou/thamestracker/internal/models"
+	"github.com/Takenobou/thamestracker/internal/validation"
+)
+
+// maxBodyBytes bounds how much of an upstream document is read.
+const maxBodyBytes = 10 << 20
+
+// eventFields are the Event fields a JSON item can populate, by JSON name.
+var eventFields = []string{"timestamp", "vessel_name", "category", "voyage_number",
+	"nationality", "direction", "from", "to", "location"}
+
+// JSONSource reads events from a JSON document served from a URL or a local
+// file. The document is either an array of items or an object holding the
+// array under Items; item keys map to Event fields through Fields.
+type JSONSource struct {
+	URL    string
+	File   string
+	Items  string            // dot-separated path to the item array; empty for a top-level array
+	Fields map[string]string // Event JSON field -> item key; unmapped fields use their own name
+	// TimeFormat is the Go layout of the timestamp field, parsed in Europe/London;
+	// empty means RFC3339.
+	TimeFormat string
+	Client     httpclient.Client
+}
+
+// Fetch reads and decodes the document. Items without a name or a parseable
+// timestamp are skipped.
+func (s *JSONSource) Fetch(ctx context.Context) ([]models.Event, error) {
+	events, _, err := s.FetchWithStats(ctx)
+	return events, err
+}
+
+// FetchWithStats is Fetch, also reporting the items seen and skipped.
+func (s *JSONSource) FetchWithStats(ctx context.Context) ([]models.Event, validation.Stats, error) {
+	var stats validation.Stats
+	data, err := s.read(ctx)
+	if err != nil {
+		return nil, stats, err
+	}
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, stats, fmt.Errorf("decoding document: %w", err)
+	}
+	if s.Items != "" {
+		for _, part := range strings.Split(s.Items, ".") {
+			obj, ok := doc.(map[string]interface{})
+			if !ok {
+				return nil, stats, fmt.Errorf("items path %q: %q is not an object", s.Items, part)
+			}
+			doc = obj[part]
+		}
+	}
+	items, ok := doc.([]interface{})
+	if !ok {
+		return nil, stats, fmt.Errorf("expected an array of items")
+	}
+
+	loc, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		return nil, stats, fmt.Errorf("loading timezone: %w", err)
+	}
+	events := make([]models.Event, 0, len(items))
+	for _, item := range items {
+		stats.RowsSeen++
+		obj, ok := item.(map[string]interface{})
+		if !ok {
+			stats.Skip("not_an_object")
+			continue
+		}
+		e, reason := s.decode(obj, loc)
+		if reason != "" {
+			stats.Skip(reason)
+			continue
+		}
+		events = append(events, e)
+	}
+	if skipped := stats.SkippedTotal(); skipped > 0 {
+		logger.FromContext(ctx).Warnf("Skipped %d of %d items without a name or valid timestamp", skipped, len(items))
+	}
+	stats.Events = len(events)
+	return events, stats, nil
+}
+
+func (s *JSONSource) read(ctx context.Context) ([]byte, error) {
+	if s.File != "" {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		return os.ReadFile(s.File)
+	}
+	client := s.Client
+	if client == nil {
+		client = httpclient.DefaultClient
+	}
+	resp, err := httpclient.Get(ctx, client, s.URL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxBodyBytes))
+}
+
+// decode maps one item onto an Event, or reports why it was skipped.
+func (s *JSONSource) decode(obj map[string]interface{}, loc *time.Location) (models.Event, string) {
+	values := make(map[string]string, len(eventFields))
+	for _, field := range eventFields {
+		key := field
+		if k, ok := s.Fields[field]; ok {
+			key = k
+		}
+		switch v := obj[key].(type) {
+		case string:
+			values[field] = strings.TrimSpace(v)
+		case float64, bool:
+			values[field] = fmt.Sprint(v)
+		}
+	}
+	if values["vessel_name"] == "" {
+		return models.Event{}, "missing_name"
+	}
+	if values["timestamp"] == "" {
+		return models.Event{}, "missing_timestamp"
+	}
+	var ts time.Time
+	var err error
+	if s.TimeFormat == "" {
+		ts, err = time.Parse(time.RFC3339, values["timestamp"])
+	} else {
+		ts, err = time.ParseInLocation(s.TimeFormat, values["timestamp"], loc)
+	}
+	if err != nil {
+		return models.Event{}, "invalid_timestamp"
+	}
+	return models.Event{
+		Timestamp:   ts,
+		VesselName:  values["vessel_name"],
+		Category:    values["category"],
+		VoyageNo:    values["voyage_number"],
+		Nationality: values["nationality"],
+		Direction:   values["direction"],
+		From:        values["from"],
+		To:          values["to"],
+		Location:    values["location"],
+	}, ""
+}
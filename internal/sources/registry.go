@@ -0,0 +1,257 @@
+package sources
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Takenobou/thamestracker/internal/helpers/httpclient"
+	"github.com/Takenobou/thamestracker/internal/models"
+	"github.com/Takenobou/thamestracker/internal/validation"
+)
+
+// Source is a feed of events beyond the built-in Tower Bridge and PLA scrapers,
+// such as another bridge's lift times or Thames Barrier closures.
+type Source interface {
+	// Fetch scrapes the upstream once and returns its current events.
+	Fetch(ctx context.Context) ([]models.Event, error)
+}
+
+// StatsSource is a Source that also reports the shape of what it scraped.
+type StatsSource interface {
+	Source
+	FetchWithStats(ctx context.Context) ([]models.Event, validation.Stats, error)
+}
+
+// SourceFunc adapts an ordinary function to Source.
+type SourceFunc func(ctx context.Context) ([]models.Event, error)
+
+// Fetch calls f(ctx).
+func (f SourceFunc) Fetch(ctx context.Context) ([]models.Event, error) {
+	return f(ctx)
+}
+
+// Info describes a registered source. The name namespaces its cache keys and
+// labels its metrics.
+type Info struct {
+	Name           string `json:"name"`
+	Type           string `json:"type"`
+	Category       string `json:"category"`
+	Location       string `json:"location,omitempty"`
+	TTLSeconds     int    `json:"ttl_seconds"`
+	RefreshSeconds int    `json:"refresh_seconds"` // negative disables background refresh
+	TimeoutSeconds int    `json:"timeout_seconds"` // 0 means no deadline
+	MaxAgeSeconds  int    `json:"max_age_seconds"` // 0 disables the freshness check
+	MaxFailures    int    `json:"-"`
+	CoolOffSeconds int    `json:"-"`
+}
+
+// TTL is how long a scrape of the source stays cached.
+func (i Info) TTL() time.Duration { return time.Duration(i.TTLSeconds) * time.Second }
+
+// RefreshInterval is how often the source is refreshed in the background; zero disables it.
+func (i Info) RefreshInterval() time.Duration {
+	if i.RefreshSeconds < 0 {
+		return 0
+	}
+	return time.Duration(i.RefreshSeconds) * time.Second
+}
+
+// Timeout bounds a single scrape; zero means no deadline.
+func (i Info) Timeout() time.Duration { return time.Duration(i.TimeoutSeconds) * time.Second }
+
+// MaxAge is how old the source's data may get before it is reported degraded; zero means no limit.
+func (i Info) MaxAge() time.Duration { return time.Duration(i.MaxAgeSeconds) * time.Second }
+
+// Entry is a registered source guarded by its own circuit breaker.
+type Entry struct {
+	Info
+	source  Source
+	breaker *httpclient.ReloadableBreaker
+	tracker *validation.Tracker
+	// breaker settings given for the source itself; zero takes the default
+	ownMaxFailures, ownCoolOffSeconds int
+}
+
+// Fetch scrapes the source through its circuit breaker, returning
+// gobreaker.ErrOpenState while the breaker is open. Every returned event is
+// tagged with the source name and given the source's default category and
+// location when it has none of its own.
+func (e *Entry) Fetch(ctx context.Context) ([]models.Event, error) {
+	result, err := e.breaker.Execute(func() (interface{}, error) {
+		if ss, ok := e.source.(StatsSource); ok {
+			events, stats, err := ss.FetchWithStats(ctx)
+			if err == nil {
+				e.tracker.Record(e.Name, stats)
+			}
+			return events, err
+		}
+		return e.source.Fetch(ctx)
+	})
+	if err != nil {
+		return nil, err
+	}
+	events, _ := result.([]models.Event)
+	for i := range events {
+		events[i].Source = e.Name
+		if events[i].Category == "" {
+			events[i].Category = e.Category
+		}
+		if events[i].Location == "" {
+			events[i].Location = e.Location
+		}
+	}
+	return events, nil
+}
+
+// Options holds the defaults applied to sources that do not set their own.
+type Options struct {
+	TTLSeconds     int
+	TimeoutSeconds int
+	MaxFailures    int
+	CoolOffSeconds int
+	// Tracker, when set, receives the statistics of sources that report them.
+	Tracker *validation.Tracker
+}
+
+// ErrUnknownSource is returned when no source is registered under a name.
+var ErrUnknownSource = errors.New("unknown source")
+
+// reserved names are used by the built-in scrapers for cache keys and metric labels.
+var reserved = map[string]bool{
+	"bridge": true, "vessels": true, "all": true,
+	"inport": true, "arrivals": true, "departures": true, "forecast": true,
+}
+
+var validName = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// Registry holds the configured sources by name. It is safe for concurrent use.
+type Registry struct {
+	opts    Options
+	mu      sync.RWMutex
+	entries map[string]*Entry
+}
+
+// NewRegistry creates an empty registry whose sources fall back to opts.
+func NewRegistry(opts Options) *Registry {
+	if opts.TTLSeconds <= 0 {
+		opts.TTLSeconds = 900
+	}
+	if opts.MaxFailures <= 0 {
+		opts.MaxFailures = 5
+	}
+	if opts.CoolOffSeconds <= 0 {
+		opts.CoolOffSeconds = 60
+	}
+	return &Registry{opts: opts, entries: make(map[string]*Entry)}
+}
+
+// Register adds src under info.Name. Unset TTL, timeout and breaker settings
+// take the registry defaults, and an unset refresh interval defaults to two
+// thirds of the TTL so the cache is refreshed before it expires.
+func (r *Registry) Register(src Source, info Info) error {
+	if !validName.MatchString(info.Name) {
+		return fmt.Errorf("invalid source name %q: use lowercase letters, digits and dashes", info.Name)
+	}
+	if reserved[info.Name] {
+		return fmt.Errorf("source name %q is reserved", info.Name)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.entries[info.Name]; ok {
+		return fmt.Errorf("duplicate source %q", info.Name)
+	}
+	e := &Entry{
+		source:            src,
+		tracker:           r.opts.Tracker,
+		ownMaxFailures:    info.MaxFailures,
+		ownCoolOffSeconds: info.CoolOffSeconds,
+	}
+	if info.Category == "" {
+		info.Category = info.Name
+	}
+	if info.TTLSeconds <= 0 {
+		info.TTLSeconds = r.opts.TTLSeconds
+	}
+	if info.RefreshSeconds == 0 {
+		info.RefreshSeconds = info.TTLSeconds * 2 / 3
+	}
+	if info.TimeoutSeconds == 0 {
+		info.TimeoutSeconds = r.opts.TimeoutSeconds
+	}
+	info.MaxFailures, info.CoolOffSeconds = r.breakerSettings(e)
+	e.Info = info
+	// the breaker is created only now, as NewBreaker replaces any breaker of
+	// the same name in httpclient.Breakers
+	e.breaker = httpclient.NewReloadableBreaker(info.Name, info.MaxFailures, info.CoolOffSeconds)
+	r.entries[info.Name] = e
+	return nil
+}
+
+// Reconfigure changes the default circuit breaker thresholds, and so those of
+// every source that does not set its own. A tripped breaker takes them once
+// it closes (see httpclient.ReloadableBreaker.Reconfigure).
+func (r *Registry) Reconfigure(maxFailures int, coolOffSeconds int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if maxFailures > 0 {
+		r.opts.MaxFailures = maxFailures
+	}
+	if coolOffSeconds > 0 {
+		r.opts.CoolOffSeconds = coolOffSeconds
+	}
+	for _, e := range r.entries {
+		e.MaxFailures, e.CoolOffSeconds = r.breakerSettings(e)
+		e.breaker.Reconfigure(e.MaxFailures, e.CoolOffSeconds)
+	}
+}
+
+// breakerSettings returns e's own breaker settings, or the defaults for those
+// it leaves unset. r.mu must be held.
+func (r *Registry) breakerSettings(e *Entry) (maxFailures, coolOffSeconds int) {
+	maxFailures, coolOffSeconds = e.ownMaxFailures, e.ownCoolOffSeconds
+	if maxFailures <= 0 {
+		maxFailures = r.opts.MaxFailures
+	}
+	if coolOffSeconds <= 0 {
+		coolOffSeconds = r.opts.CoolOffSeconds
+	}
+	return maxFailures, coolOffSeconds
+}
+
+// Get returns the source registered under name.
+func (r *Registry) Get(name string) (*Entry, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	e, ok := r.entries[name]
+	return e, ok
+}
+
+// Entries returns every registered source, sorted by name.
+func (r *Registry) Entries() []*Entry {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]*Entry, 0, len(r.entries))
+	for _, e := range r.entries {
+		out = append(out, e)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// List describes every registered source, sorted by name.
+func (r *Registry) List() []Info {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]Info, 0, len(r.entries))
+	for _, e := range r.entries {
+		out = append(out, e.Info)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
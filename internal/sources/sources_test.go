@@ -0,0 +1,193 @@
+package sources
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Takenobou/thamestracker/internal/helpers/httpclient"
+	"github.com/Takenobou/thamestracker/internal/helpers/logger"
+	"github.com/Takenobou/thamestracker/internal/models"
+	"github.com/sony/gobreaker"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	logger.InitLogger()
+	os.Exit(m.Run())
+}
+
+func TestRegister_Validation(t *testing.T) {
+	r := NewRegistry(Options{})
+	noop := SourceFunc(func(context.Context) ([]models.Event, error) { return nil, nil })
+	assert.Error(t, r.Register(noop, Info{Name: "Thames Barrier"}))
+	assert.Error(t, r.Register(noop, Info{Name: "bridge"}), "built-in names are reserved")
+	assert.NoError(t, r.Register(noop, Info{Name: "thames-barrier"}))
+	assert.Error(t, r.Register(noop, Info{Name: "thames-barrier"}), "duplicate")
+
+	e, ok := r.Get("thames-barrier")
+	assert.True(t, ok)
+	assert.Equal(t, "thames-barrier", e.Category)
+	assert.Equal(t, 900, e.TTLSeconds)
+	assert.Equal(t, 10*time.Minute, e.RefreshInterval())
+}
+
+func TestEntry_BreakerOpensPerSource(t *testing.T) {
+	r := NewRegistry(Options{MaxFailures: 2, CoolOffSeconds: 60})
+	failing := SourceFunc(func(context.Context) ([]models.Event, error) { return nil, errors.New("down") })
+	ok := SourceFunc(func(context.Context) ([]models.Event, error) { return []models.Event{{VesselName: "x"}}, nil })
+	assert.NoError(t, r.Register(failing, Info{Name: "broken"}))
+	assert.NoError(t, r.Register(ok, Info{Name: "healthy"}))
+
+	broken, _ := r.Get("broken")
+	for i := 0; i < 2; i++ {
+		_, err := broken.Fetch(context.Background())
+		assert.EqualError(t, err, "down")
+	}
+	_, err := broken.Fetch(context.Background())
+	assert.ErrorIs(t, err, gobreaker.ErrOpenState)
+
+	// a rejected duplicate leaves the reported breaker alone
+	assert.Error(t, r.Register(ok, Info{Name: "broken"}))
+	assert.Contains(t, httpclient.Breakers.Status(), httpclient.BreakerStatus{Name: "broken", State: "open"})
+
+	healthy, _ := r.Get("healthy")
+	events, err := healthy.Fetch(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "healthy", events[0].Source)
+}
+
+func TestRegistry_Reconfigure(t *testing.T) {
+	r := NewRegistry(Options{MaxFailures: 3, CoolOffSeconds: 60})
+	failing := SourceFunc(func(context.Context) ([]models.Event, error) { return nil, errors.New("down") })
+	assert.NoError(t, r.Register(failing, Info{Name: "defaulted"}))
+	assert.NoError(t, r.Register(failing, Info{Name: "tuned", MaxFailures: 4}))
+
+	r.Reconfigure(1, 30)
+	infos := r.List()
+	assert.Equal(t, 1, infos[0].MaxFailures)
+	assert.Equal(t, 30, infos[0].CoolOffSeconds)
+	assert.Equal(t, 4, infos[1].MaxFailures, "a source's own setting is kept")
+	assert.Equal(t, 30, infos[1].CoolOffSeconds)
+
+	defaulted, _ := r.Get("defaulted")
+	_, err := defaulted.Fetch(context.Background())
+	assert.EqualError(t, err, "down")
+	_, err = defaulted.Fetch(context.Background())
+	assert.ErrorIs(t, err, gobreaker.ErrOpenState, "the new threshold applies")
+}
+
+func TestLoad_JSONFileSource(t *testing.T) {
+	dir := t.TempDir()
+	data := `{"closures": [
+		{"start": "06/04/2025 09:00", "title": "Barrier closed", "reason": "tidal test"},
+		{"start": "not a time", "title": "Broken row"},
+		{"start": "07/04/2025 10:30"}
+	]}`
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "barrier.json"), []byte(data), 0o644))
+	cfg := `{"sources": [{
+		"name": "thames-barrier",
+		"category": "barrier",
+		"location": "Thames Barrier",
+		"file": "` + filepath.Join(dir, "barrier.json") + `",
+		"items": "closures",
+		"fields": {"timestamp": "start", "vessel_name": "title"},
+		"time_format": "02/01/2006 15:04",
+		"ttl_seconds": 3600
+	}]}`
+	path := filepath.Join(dir, "sources.json")
+	assert.NoError(t, os.WriteFile(path, []byte(cfg), 0o644))
+
+	r, err := Load(path, nil, Options{})
+	assert.NoError(t, err)
+	infos := r.List()
+	assert.Len(t, infos, 1)
+	assert.Equal(t, "json", infos[0].Type)
+	assert.Equal(t, 2400, infos[0].RefreshSeconds)
+
+	e, _ := r.Get("thames-barrier")
+	events, err := e.Fetch(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, events, 1)
+	assert.Equal(t, "Barrier closed", events[0].VesselName)
+	assert.Equal(t, "barrier", events[0].Category)
+	assert.Equal(t, "Thames Barrier", events[0].Location)
+	// BST: 09:00 London is 08:00 UTC
+	assert.Equal(t, time.Date(2025, 4, 6, 8, 0, 0, 0, time.UTC), events[0].Timestamp.UTC())
+}
+
+func TestJSONSource_URL(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`[{"timestamp": "2025-04-06T09:00:00Z", "vessel_name": "Closure", "direction": "Both"}]`))
+	}))
+	defer srv.Close()
+	src := &JSONSource{URL: srv.URL, Client: srv.Client()}
+	events, err := src.Fetch(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, events, 1)
+	assert.Equal(t, "Both", events[0].Direction)
+
+	src.URL = srv.URL + "/missing"
+	_, err = src.Fetch(context.Background())
+	assert.Error(t, err)
+}
+
+func TestLoad_Errors(t *testing.T) {
+	dir := t.TempDir()
+	_, err := Load(filepath.Join(dir, "missing.json"), nil, Options{})
+	assert.Error(t, err)
+
+	path := filepath.Join(dir, "sources.json")
+	assert.NoError(t, os.WriteFile(path, []byte(`{"sources": [{"name": "x", "type": "ftp"}]}`), 0o644))
+	_, err = Load(path, nil, Options{})
+	assert.ErrorContains(t, err, "unknown type")
+
+	assert.NoError(t, os.WriteFile(path, []byte(`{"sources": [{"name": "x"}]}`), 0o644))
+	_, err = Load(path, nil, Options{})
+	assert.ErrorContains(t, err, "url and file")
+}
+
+func TestLoad_HTMLSource(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<table><tr><td>2025-04-05T17:45:00Z</td><td>Silver Sturgeon</td></tr></table>`))
+	}))
+	defer srv.Close()
+	cfg := `{"sources": [{
+		"name": "albert-bridge",
+		"type": "html",
+		"category": "bridge",
+		"location": "Albert Bridge",
+		"url": "` + srv.URL + `",
+		"scraper": {
+			"rows": "tr",
+			"fields": {"timestamp": {"selector": "td:nth-child(1)"}, "vessel_name": {"selector": "td:nth-child(2)"}}
+		}
+	}]}`
+	path := filepath.Join(t.TempDir(), "sources.json")
+	assert.NoError(t, os.WriteFile(path, []byte(cfg), 0o644))
+
+	r, err := Load(path, srv.Client(), Options{})
+	assert.NoError(t, err)
+	e, _ := r.Get("albert-bridge")
+	assert.Equal(t, "html", e.Type)
+	events, err := e.Fetch(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, events, 1)
+	assert.Equal(t, "Silver Sturgeon", events[0].VesselName)
+	assert.Equal(t, "bridge", events[0].Category)
+	assert.Equal(t, "Albert Bridge", events[0].Location)
+	assert.Equal(t, "albert-bridge", events[0].Source)
+
+	assert.NoError(t, os.WriteFile(path, []byte(`{"sources": [{"name": "x", "type": "html", "url": "http://example.com"}]}`), 0o644))
+	_, err = Load(path, nil, Options{})
+	assert.ErrorContains(t, err, "definition_file")
+}
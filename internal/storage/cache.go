@@ -1,20 +1,21 @@
 package storage
 
 import (
+	"context"
 	"time"
 
 	"github.com/Takenobou/thamestracker/internal/config"
 	"github.com/Takenobou/thamestracker/internal/helpers/cache"
 )
 
-var CacheClient = cache.NewRedisCache(config.AppConfig.Redis.Address)
+var CacheClient = cache.NewRedisCache(config.Get().Redis.Address)
 
 const DefaultTTL = time.Hour
 
-func SetCache(key string, value interface{}, ttl time.Duration) error {
-	return CacheClient.Set(key, value, ttl)
+func SetCache(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	return CacheClient.Set(ctx, key, value, ttl)
 }
 
-func GetCache(key string, dest interface{}) error {
-	return CacheClient.Get(key, dest)
+func GetCache(ctx context.Context, key string, dest interface{}) error {
+	return CacheClient.Get(ctx, key, dest)
 }
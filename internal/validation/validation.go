@@ -0,0 +1,220 @@
+// Package validation tracks the shape of each upstream scrape against a
+// rolling baseline, so that markup or API changes are noticed even when the
+// scrapers keep returning (fewer) events rather than failing outright.
+package validation
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Takenobou/thamestracker/internal/helpers/logger"
+	"github.com/Takenobou/thamestracker/internal/helpers/metrics"
+)
+
+// Stats summarises one scrape of a source.
+type Stats struct {
+	RowsSeen int `json:"rows_seen"`
+	Events   int `json:"events"`
+	// Skipped counts rows dropped, by reason (e.g. "missing_name").
+	Skipped map[string]int `json:"skipped,omitempty"`
+	// UnknownFields lists upstream fields the scraper does not understand.
+	UnknownFields []string `json:"unknown_fields,omitempty"`
+	// Missing lists expected page structure that was not found (e.g. "pager").
+	Missing []string `json:"missing,omitempty"`
+}
+
+// Skip counts a row dropped for reason.
+func (s *Stats) Skip(reason string) {
+	if s.Skipped == nil {
+		s.Skipped = make(map[string]int)
+	}
+	s.Skipped[reason]++
+}
+
+// SkippedTotal is the number of rows dropped for any reason.
+func (s Stats) SkippedTotal() int {
+	n := 0
+	for _, c := range s.Skipped {
+		n += c
+	}
+	return n
+}
+
+func (s Stats) skipRatio() float64 {
+	if s.RowsSeen == 0 {
+		return 0
+	}
+	return float64(s.SkippedTotal()) / float64(s.RowsSeen)
+}
+
+// Baseline averages the scrapes preceding the latest one.
+type Baseline struct {
+	Scrapes   int     `json:"scrapes"`
+	RowsSeen  float64 `json:"rows_seen"`
+	Events    float64 `json:"events"`
+	SkipRatio float64 `json:"skip_ratio"`
+}
+
+// Status is the validation state of one source.
+type Status struct {
+	Source     string    `json:"source"`
+	Degraded   bool      `json:"degraded"`
+	Reasons    []string  `json:"reasons,omitempty"`
+	Scrapes    int       `json:"scrapes"`
+	LastScrape time.Time `json:"last_scrape"`
+	Last       Stats     `json:"last"`
+	Baseline   Baseline  `json:"baseline"`
+}
+
+// minBaseline is how many scrapes are needed before ratios are compared.
+const minBaseline = 3
+
+type sourceState struct {
+	history    []Stats // most recent last, at most window entries
+	scrapes    int
+	firstSeen  map[string]int // unknown field -> scrape number it first appeared in
+	status     Status
+	lastLogged bool
+}
+
+// Tracker records scrape statistics per source. It is safe for concurrent use;
+// a nil *Tracker ignores every call.
+type Tracker struct {
+	window  int
+	mu      sync.Mutex
+	sources map[string]*sourceState
+}
+
+// NewTracker creates a tracker whose baselines cover the last window scrapes
+// of each source (10 if window <= 0).
+func NewTracker(window int) *Tracker {
+	if window <= 0 {
+		window = 10
+	}
+	return &Tracker{window: window, sources: make(map[string]*sourceState)}
+}
+
+// Record compares a scrape of source with its baseline, updates the
+// source's status and metrics, and adds the scrape to the baseline.
+func (t *Tracker) Record(source string, s Stats) {
+	if t == nil {
+		return
+	}
+	sort.Strings(s.UnknownFields)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	st, ok := t.sources[source]
+	if !ok {
+		st = &sourceState{firstSeen: make(map[string]int)}
+		t.sources[source] = st
+	}
+	st.scrapes++
+	for _, f := range s.UnknownFields {
+		if _, ok := st.firstSeen[f]; !ok {
+			st.firstSeen[f] = st.scrapes
+		}
+	}
+
+	base := baseline(st.history)
+	reasons := t.compare(st, s, base)
+	st.status = Status{
+		Source:     source,
+		Degraded:   len(reasons) > 0,
+		Reasons:    reasons,
+		Scrapes:    st.scrapes,
+		LastScrape: time.Now(),
+		Last:       s,
+		Baseline:   base,
+	}
+	st.history = append(st.history, s)
+	if len(st.history) > t.window {
+		st.history = st.history[len(st.history)-t.window:]
+	}
+
+	metrics.ScrapeRowsSeen.WithLabelValues(source).Set(float64(s.RowsSeen))
+	for reason, n := range s.Skipped {
+		metrics.ScrapeRowsSkippedTotal.WithLabelValues(source, reason).Add(float64(n))
+	}
+	metrics.ScrapeUnknownFields.WithLabelValues(source).Set(float64(len(s.UnknownFields)))
+	if s.Events == 0 {
+		metrics.ScrapeZeroResultsTotal.WithLabelValues(source).Inc()
+	}
+	if st.status.Degraded {
+		metrics.SourceDegraded.WithLabelValues(source).Set(1)
+		if !st.lastLogged {
+			logger.Logger.Warnf("Source %s degraded: %s", source, strings.Join(reasons, "; "))
+		}
+	} else {
+		metrics.SourceDegraded.WithLabelValues(source).Set(0)
+		if st.lastLogged {
+			logger.Logger.Infof("Source %s recovered", source)
+		}
+	}
+	st.lastLogged = st.status.Degraded
+}
+
+// compare explains how s departs from the expected shape of source's data.
+func (t *Tracker) compare(st *sourceState, s Stats, base Baseline) []string {
+	var reasons []string
+	if len(s.Missing) > 0 {
+		reasons = append(reasons, fmt.Sprintf("missing page structure: %s", strings.Join(s.Missing, ", ")))
+	}
+	var fresh []string
+	for _, f := range s.UnknownFields {
+		// a new field is reported until it has been part of a full baseline window
+		if st.scrapes-st.firstSeen[f] < t.window {
+			fresh = append(fresh, f)
+		}
+	}
+	if len(fresh) > 0 {
+		reasons = append(reasons, fmt.Sprintf("new upstream fields: %s", strings.Join(fresh, ", ")))
+	}
+	if base.Scrapes > 0 && s.Events == 0 && base.Events > 0 {
+		reasons = append(reasons, fmt.Sprintf("zero results (baseline %.0f)", base.Events))
+	}
+	if base.Scrapes >= minBaseline {
+		if s.RowsSeen > 0 && float64(s.RowsSeen) < base.RowsSeen/2 {
+			reasons = append(reasons, fmt.Sprintf("rows seen dropped to %d (baseline %.0f)", s.RowsSeen, base.RowsSeen))
+		}
+		if ratio := s.skipRatio(); ratio > base.SkipRatio+0.25 {
+			reasons = append(reasons, fmt.Sprintf("skipped %.0f%% of rows (baseline %.0f%%)", ratio*100, base.SkipRatio*100))
+		}
+	}
+	return reasons
+}
+
+func baseline(history []Stats) Baseline {
+	b := Baseline{Scrapes: len(history)}
+	if len(history) == 0 {
+		return b
+	}
+	for _, s := range history {
+		b.RowsSeen += float64(s.RowsSeen)
+		b.Events += float64(s.Events)
+		b.SkipRatio += s.skipRatio()
+	}
+	n := float64(len(history))
+	b.RowsSeen /= n
+	b.Events /= n
+	b.SkipRatio /= n
+	return b
+}
+
+// Status lists the validation state of every source that has been scraped,
+// sorted by source name.
+func (t *Tracker) Status() []Status {
+	if t == nil {
+		return []Status{}
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make([]Status, 0, len(t.sources))
+	for _, st := range t.sources {
+		out = append(out, st.status)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Source < out[j].Source })
+	return out
+}
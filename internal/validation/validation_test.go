@@ -0,0 +1,106 @@
+package validation
+
+import (
+	"os"
+	"testing"
+
+	"github.com/Takenobou/thamestracker/internal/helpers/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	logger.InitLogger()
+	os.Exit(m.Run())
+}
+
+func healthy() Stats {
+	return Stats{RowsSeen: 20, Events: 19, Skipped: map[string]int{"missing_name": 1}}
+}
+
+func statusOf(t *testing.T, tr *Tracker, source string) Status {
+	t.Helper()
+	for _, s := range tr.Status() {
+		if s.Source == source {
+			return s
+		}
+	}
+	t.Fatalf("no status for %s", source)
+	return Status{}
+}
+
+func TestTracker_HealthyBaseline(t *testing.T) {
+	tr := NewTracker(5)
+	for i := 0; i < 4; i++ {
+		tr.Record("bridge", healthy())
+	}
+	s := statusOf(t, tr, "bridge")
+	assert.False(t, s.Degraded)
+	assert.Equal(t, 4, s.Scrapes)
+	assert.Equal(t, 3, s.Baseline.Scrapes, "baseline excludes the latest scrape")
+	assert.Equal(t, 20.0, s.Baseline.RowsSeen)
+	assert.InDelta(t, 0.05, s.Baseline.SkipRatio, 0.001)
+}
+
+func TestTracker_ZeroResults(t *testing.T) {
+	tr := NewTracker(5)
+	tr.Record("bridge", healthy())
+	tr.Record("bridge", Stats{})
+	s := statusOf(t, tr, "bridge")
+	assert.True(t, s.Degraded)
+	assert.Contains(t, s.Reasons[0], "zero results")
+
+	// a source that has always been empty is not degraded
+	tr.Record("barrier", Stats{})
+	tr.Record("barrier", Stats{})
+	assert.False(t, statusOf(t, tr, "barrier").Degraded)
+}
+
+func TestTracker_SkipRatioAndRowDrop(t *testing.T) {
+	tr := NewTracker(5)
+	for i := 0; i < 3; i++ {
+		tr.Record("vessels", healthy())
+	}
+	tr.Record("vessels", Stats{RowsSeen: 20, Events: 8, Skipped: map[string]int{"invalid_timestamp": 12}})
+	s := statusOf(t, tr, "vessels")
+	assert.True(t, s.Degraded)
+	assert.Contains(t, s.Reasons[0], "skipped 60% of rows")
+
+	tr.Record("vessels", Stats{RowsSeen: 5, Events: 5})
+	s = statusOf(t, tr, "vessels")
+	assert.True(t, s.Degraded)
+	assert.Contains(t, s.Reasons[0], "rows seen dropped to 5")
+}
+
+func TestTracker_NewFieldsClearAfterWindow(t *testing.T) {
+	tr := NewTracker(3)
+	tr.Record("vessels", healthy())
+	withField := healthy()
+	withField.UnknownFields = []string{"inport.berth_code"}
+	for i := 0; i < 3; i++ {
+		tr.Record("vessels", withField)
+		s := statusOf(t, tr, "vessels")
+		assert.True(t, s.Degraded, "scrape %d", i)
+		assert.Equal(t, "new upstream fields: inport.berth_code", s.Reasons[0])
+	}
+	tr.Record("vessels", withField)
+	assert.False(t, statusOf(t, tr, "vessels").Degraded, "the field is part of the baseline now")
+}
+
+func TestTracker_MissingStructure(t *testing.T) {
+	tr := NewTracker(3)
+	s := healthy()
+	s.Missing = []string{"pager"}
+	tr.Record("bridge", s)
+	st := statusOf(t, tr, "bridge")
+	assert.True(t, st.Degraded)
+	assert.Equal(t, "missing page structure: pager", st.Reasons[0])
+
+	tr.Record("bridge", healthy())
+	assert.False(t, statusOf(t, tr, "bridge").Degraded)
+}
+
+func TestTracker_Nil(t *testing.T) {
+	var tr *Tracker
+	tr.Record("bridge", healthy())
+	assert.Empty(t, tr.Status())
+}
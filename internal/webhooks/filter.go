@@ -0,0 +1,91 @@
+package webhooks
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/Takenobou/thamestracker/internal/models"
+)
+
+// Filter is a compiled webhook filter expression.
+//
+// An expression is one or more clauses joined by "and" (or "&&"). Each clause
+// compares an event field with a value using "=" (equals), "!=" (not equals)
+// or "~" (contains). Comparisons are case-insensitive and values may be
+// double-quoted, e.g.
+//
+//	category=bridge and vessel_name~"dixie queen"
+type Filter struct {
+	clauses []clause
+}
+
+type clause struct {
+	field string
+	op    string
+	value string
+}
+
+var (
+	clauseRe = regexp.MustCompile(`^\s*([a-z_]+)\s*(!=|=|~)\s*(.+?)\s*$`)
+	andRe    = regexp.MustCompile(`(?i)\s+and\s+|\s*&&\s*`)
+)
+
+// eventFields maps filter field names to event accessors.
+var eventFields = map[string]func(models.Event) string{
+	"vessel_name":   func(e models.Event) string { return e.VesselName },
+	"name":          func(e models.Event) string { return e.VesselName },
+	"category":      func(e models.Event) string { return e.Category },
+	"voyage_number": func(e models.Event) string { return e.VoyageNo },
+	"nationality":   func(e models.Event) string { return e.Nationality },
+	"direction":     func(e models.Event) string { return e.Direction },
+	"from":          func(e models.Event) string { return e.From },
+	"to":            func(e models.Event) string { return e.To },
+	"location":      func(e models.Event) string { return e.Location },
+}
+
+// ParseFilter compiles a filter expression. An empty expression matches every event.
+func ParseFilter(expr string) (Filter, error) {
+	var f Filter
+	if strings.TrimSpace(expr) == "" {
+		return f, nil
+	}
+	for _, part := range andRe.Split(strings.TrimSpace(expr), -1) {
+		m := clauseRe.FindStringSubmatch(part)
+		if m == nil {
+			return Filter{}, fmt.Errorf("invalid filter clause %q", part)
+		}
+		field := strings.ToLower(m[1])
+		if _, ok := eventFields[field]; !ok {
+			return Filter{}, fmt.Errorf("unknown filter field %q", m[1])
+		}
+		value := m[3]
+		if len(value) >= 2 && strings.HasPrefix(value, `"`) && strings.HasSuffix(value, `"`) {
+			value = value[1 : len(value)-1]
+		}
+		f.clauses = append(f.clauses, clause{field: field, op: m[2], value: strings.ToLower(value)})
+	}
+	return f, nil
+}
+
+// Match reports whether the event satisfies every clause.
+func (f Filter) Match(e models.Event) bool {
+	for _, c := range f.clauses {
+		got := strings.ToLower(eventFields[c.field](e))
+		switch c.op {
+		case "=":
+			if got != c.value {
+				return false
+			}
+		case "!=":
+			if got == c.value {
+				return false
+			}
+		case "~":
+			if !strings.Contains(got, c.value) {
+				return false
+			}
+		}
+	}
+	return true
+}
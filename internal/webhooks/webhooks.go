@@ -0,0 +1,507 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Takenobou/thamestracker/internal/changes"
+	"github.com/Takenobou/thamestracker/internal/helpers/logger"
+	"github.com/Takenobou/thamestracker/internal/helpers/metrics"
+	"github.com/Takenobou/thamestracker/internal/helpers/utils"
+	"github.com/Takenobou/thamestracker/internal/models"
+	"github.com/google/uuid"
+)
+
+// Upcoming is the event type sent LeadMinutes before a bridge lift.
+const Upcoming = "upcoming"
+
+var validEventTypes = map[string]bool{
+	string(changes.Added):       true,
+	string(changes.Removed):     true,
+	string(changes.Rescheduled): true,
+	Upcoming:                    true,
+}
+
+// ErrNotFound is returned when a webhook or dead letter does not exist.
+var ErrNotFound = errors.New("webhook not found")
+
+// Hook is a registered webhook.
+type Hook struct {
+	ID     string `json:"id"`
+	URL    string `json:"url"`
+	Secret string `json:"secret,omitempty"` // HMAC-SHA256 signing key
+	Filter string `json:"filter,omitempty"` // see ParseFilter
+	// Events selects which of added, removed, rescheduled and upcoming are
+	// sent; empty means added, removed and rescheduled, plus upcoming when
+	// LeadMinutes is set.
+	Events      []string  `json:"events,omitempty"`
+	LeadMinutes int       `json:"lead_minutes,omitempty"` // minutes before a lift to send "upcoming"
+	CreatedAt   time.Time `json:"created_at"`
+
+	filter Filter
+}
+
+// Redacted returns a copy safe to show through the admin API.
+func (h Hook) Redacted() Hook {
+	if h.Secret != "" {
+		h.Secret = "********"
+	}
+	return h
+}
+
+func (h Hook) wants(eventType string) bool {
+	if len(h.Events) == 0 {
+		// a lead time opts in to upcoming reminders
+		return eventType != Upcoming || h.LeadMinutes > 0
+	}
+	for _, t := range h.Events {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Payload is the JSON body POSTed to webhook URLs.
+type Payload struct {
+	DeliveryID string        `json:"delivery_id"`
+	HookID     string        `json:"hook_id"`
+	Type       string        `json:"type"`
+	ChangeID   uint64        `json:"change_id,omitempty"`
+	Event      models.Event  `json:"event"`
+	Previous   *models.Event `json:"previous,omitempty"`
+	SentAt     time.Time     `json:"sent_at"`
+}
+
+// Attempt records a single delivery attempt.
+type Attempt struct {
+	At         time.Time `json:"at"`
+	StatusCode int       `json:"status_code,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	DurationMs int64     `json:"duration_ms"`
+}
+
+// Delivery records the attempts made to deliver one payload to one hook.
+type Delivery struct {
+	ID       string    `json:"id"`
+	HookID   string    `json:"hook_id"`
+	URL      string    `json:"url"`
+	Type     string    `json:"type"`
+	Status   string    `json:"status"` // "pending", "delivered", "aborted" or "dead"
+	Payload  Payload   `json:"payload"`
+	Attempts []Attempt `json:"attempts"`
+}
+
+// state is the persisted form of the manager.
+type state struct {
+	Hooks       []Hook     `json:"hooks"`
+	DeadLetters []Delivery `json:"dead_letters"`
+}
+
+// Options configure delivery behaviour.
+type Options struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	Client         *http.Client
+	MaxDeadLetters int
+	MaxDeliveries  int // recent deliveries kept in memory
+}
+
+// Manager stores webhooks in a JSON file and delivers matching events.
+type Manager struct {
+	path string
+	opts Options
+
+	mu          sync.Mutex
+	hooks       []Hook
+	deadLetters []Delivery
+	deliveries  []Delivery
+	reminded    map[string]time.Time // hookID|event key -> lift time
+
+	sem chan struct{}
+}
+
+// NewManager loads webhooks from path (created on first save if missing).
+func NewManager(path string, opts Options) (*Manager, error) {
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 5
+	}
+	if opts.InitialBackoff <= 0 {
+		opts.InitialBackoff = time.Second
+	}
+	if opts.Client == nil {
+		opts.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	if opts.MaxDeadLetters <= 0 {
+		opts.MaxDeadLetters = 1000
+	}
+	if opts.MaxDeliveries <= 0 {
+		opts.MaxDeliveries = 500
+	}
+	m := &Manager{path: path, opts: opts, reminded: make(map[string]time.Time), sem: make(chan struct{}, 8)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading webhooks file: %w", err)
+	}
+	var st state
+	if err := json.Unmarshal(data, &st); err != nil {
+		return nil, fmt.Errorf("parsing webhooks file: %w", err)
+	}
+	for _, h := range st.Hooks {
+		f, err := ParseFilter(h.Filter)
+		if err != nil {
+			return nil, fmt.Errorf("webhook %s: %w", h.ID, err)
+		}
+		h.filter = f
+		m.hooks = append(m.hooks, h)
+	}
+	m.deadLetters = st.DeadLetters
+	return m, nil
+}
+
+// save persists hooks and dead letters; callers must hold m.mu.
+func (m *Manager) save() error {
+	data, err := json.MarshalIndent(state{Hooks: m.hooks, DeadLetters: m.deadLetters}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(m.path), 0o755); err != nil {
+		return err
+	}
+	tmp := m.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, m.path)
+}
+
+// List returns the registered webhooks with secrets redacted.
+func (m *Manager) List() []Hook {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]Hook, 0, len(m.hooks))
+	for _, h := range m.hooks {
+		out = append(out, h.Redacted())
+	}
+	return out
+}
+
+// Create validates and registers a webhook.
+func (m *Manager) Create(h Hook) (Hook, error) {
+	u, err := url.Parse(h.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return Hook{}, fmt.Errorf("invalid url: %q", h.URL)
+	}
+	f, err := ParseFilter(h.Filter)
+	if err != nil {
+		return Hook{}, err
+	}
+	for i, t := range h.Events {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if !validEventTypes[t] {
+			return Hook{}, fmt.Errorf("invalid event type: %q", t)
+		}
+		h.Events[i] = t
+	}
+	if h.LeadMinutes < 0 {
+		return Hook{}, fmt.Errorf("lead_minutes must not be negative")
+	}
+	if h.LeadMinutes > 0 && !h.wants(Upcoming) {
+		h.Events = append(h.Events, Upcoming)
+	}
+	h.ID = uuid.New().String()
+	h.CreatedAt = time.Now().UTC()
+	h.filter = f
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.hooks = append(m.hooks, h)
+	if err := m.save(); err != nil {
+		m.hooks = m.hooks[:len(m.hooks)-1]
+		return Hook{}, fmt.Errorf("saving webhooks: %w", err)
+	}
+	return h.Redacted(), nil
+}
+
+// Delete removes a webhook.
+func (m *Manager) Delete(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, h := range m.hooks {
+		if h.ID == id {
+			m.hooks = append(m.hooks[:i:i], m.hooks[i+1:]...)
+			return m.save()
+		}
+	}
+	return ErrNotFound
+}
+
+// Deliveries returns recent deliveries, newest first.
+func (m *Manager) Deliveries() []Delivery {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]Delivery, 0, len(m.deliveries))
+	for i := len(m.deliveries) - 1; i >= 0; i-- {
+		out = append(out, m.deliveries[i])
+	}
+	return out
+}
+
+// DeadLetters returns deliveries that exhausted their retries.
+func (m *Manager) DeadLetters() []Delivery {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Delivery{}, m.deadLetters...)
+}
+
+// RetryDeadLetter removes a dead letter and attempts delivery again.
+func (m *Manager) RetryDeadLetter(ctx context.Context, id string) error {
+	m.mu.Lock()
+	var d Delivery
+	found := false
+	for i, dl := range m.deadLetters {
+		if dl.ID == id {
+			d = dl
+			found = true
+			m.deadLetters = append(m.deadLetters[:i:i], m.deadLetters[i+1:]...)
+			break
+		}
+	}
+	var hook Hook
+	hookFound := false
+	for _, h := range m.hooks {
+		if h.ID == d.HookID {
+			hook = h
+			hookFound = true
+		}
+	}
+	if found {
+		if err := m.save(); err != nil {
+			logger.Logger.Errorf("Failed to save webhooks: %v", err)
+		}
+	}
+	m.mu.Unlock()
+	if !found || !hookFound {
+		return ErrNotFound
+	}
+	m.enqueue(ctx, hook, d.Payload)
+	return nil
+}
+
+// followBatch is how many changes Run reads from the feed at a time.
+const followBatch = 100
+
+// Run delivers the changes read by follow until ctx is cancelled or follow is
+// closed, and checks for upcoming bridge lifts every minute.
+func (m *Manager) Run(ctx context.Context, follow *changes.Follower, upcoming func(ctx context.Context) ([]models.Event, error)) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case _, ok := <-follow.Wake():
+			if !ok {
+				return
+			}
+			m.deliverPending(ctx, follow)
+		case now := <-ticker.C:
+			if upcoming == nil {
+				continue
+			}
+			events, err := upcoming(ctx)
+			if err != nil {
+				logger.Logger.Warnf("Webhook upcoming check failed: %v", err)
+				continue
+			}
+			m.CheckUpcoming(ctx, events, now)
+		}
+	}
+}
+
+// deliverPending handles every change follow has not yet returned.
+func (m *Manager) deliverPending(ctx context.Context, follow *changes.Follower) {
+	for ctx.Err() == nil {
+		page := follow.Next(followBatch)
+		if page.Reset {
+			logger.Logger.Errorf("Webhook delivery fell behind the change feed; older changes were discarded before delivery")
+		}
+		for _, c := range page.Changes {
+			m.HandleChange(ctx, c)
+		}
+		if len(page.Changes) < followBatch {
+			return
+		}
+	}
+}
+
+// HandleChange queues deliveries of a change to every matching webhook.
+func (m *Manager) HandleChange(ctx context.Context, c changes.Change) {
+	for _, h := range m.matching(string(c.Type), c.Event) {
+		m.enqueue(ctx, h, Payload{HookID: h.ID, Type: string(c.Type), ChangeID: c.ID, Event: c.Event, Previous: c.Previous})
+	}
+}
+
+// CheckUpcoming sends "upcoming" notifications for bridge lifts starting
+// within each webhook's lead time. Each lift is notified at most once per hook.
+func (m *Manager) CheckUpcoming(ctx context.Context, events []models.Event, now time.Time) {
+	m.mu.Lock()
+	for k, at := range m.reminded {
+		if at.Before(now) {
+			delete(m.reminded, k)
+		}
+	}
+	m.mu.Unlock()
+	for _, e := range events {
+		if e.Category != "bridge" || !e.Timestamp.After(now) {
+			continue
+		}
+		for _, h := range m.matching(Upcoming, e) {
+			if h.LeadMinutes <= 0 || e.Timestamp.Sub(now) > time.Duration(h.LeadMinutes)*time.Minute {
+				continue
+			}
+			key := h.ID + "|" + e.Key()
+			m.mu.Lock()
+			_, done := m.reminded[key]
+			m.reminded[key] = e.Timestamp
+			m.mu.Unlock()
+			if !done {
+				m.enqueue(ctx, h, Payload{HookID: h.ID, Type: Upcoming, Event: e})
+			}
+		}
+	}
+}
+
+func (m *Manager) matching(eventType string, e models.Event) []Hook {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []Hook
+	for _, h := range m.hooks {
+		if h.wants(eventType) && h.filter.Match(e) {
+			out = append(out, h)
+		}
+	}
+	return out
+}
+
+// enqueue delivers the payload in the background.
+func (m *Manager) enqueue(ctx context.Context, h Hook, p Payload) {
+	go func() {
+		select {
+		case m.sem <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+		defer func() { <-m.sem }()
+		m.deliver(ctx, h, p)
+	}()
+}
+
+// deliver POSTs a signed payload, retrying with exponential backoff and
+// dead-lettering the delivery once attempts are exhausted.
+func (m *Manager) deliver(ctx context.Context, h Hook, p Payload) {
+	if p.DeliveryID == "" {
+		p.DeliveryID = uuid.New().String()
+	}
+	d := Delivery{ID: p.DeliveryID, HookID: h.ID, URL: h.URL, Type: p.Type, Status: "pending", Payload: p}
+	err := utils.Retry(ctx, m.opts.MaxAttempts, m.opts.InitialBackoff, func(ctx context.Context) error {
+		p.SentAt = time.Now().UTC()
+		attempt, err := m.post(ctx, h, p)
+		d.Attempts = append(d.Attempts, attempt)
+		return err
+	})
+	switch {
+	case err == nil:
+		d.Status = "delivered"
+		metrics.WebhookDeliveriesTotal.WithLabelValues("delivered").Inc()
+	case ctx.Err() != nil:
+		// shutting down: not the receiver's fault, so don't dead-letter
+		d.Status = "aborted"
+		metrics.WebhookDeliveriesTotal.WithLabelValues("aborted").Inc()
+	default:
+		d.Status = "dead"
+		metrics.WebhookDeliveriesTotal.WithLabelValues("dead").Inc()
+		logger.Logger.Warnf("Webhook delivery dead-lettered, hook: %s, url: %s, attempts: %d, error: %v", h.ID, h.URL, len(d.Attempts), err)
+	}
+	m.record(d)
+}
+
+func (m *Manager) post(ctx context.Context, h Hook, p Payload) (Attempt, error) {
+	start := time.Now()
+	attempt := Attempt{At: start.UTC()}
+	body, err := json.Marshal(p)
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, bytes.NewReader(body))
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "ThamesTracker-Webhook/1")
+	req.Header.Set("X-ThamesTracker-Event", p.Type)
+	req.Header.Set("X-ThamesTracker-Delivery", p.DeliveryID)
+	if h.Secret != "" {
+		req.Header.Set("X-ThamesTracker-Signature", "sha256="+Sign(h.Secret, body))
+	}
+	resp, err := m.opts.Client.Do(req)
+	attempt.DurationMs = time.Since(start).Milliseconds()
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt, err
+	}
+	resp.Body.Close()
+	attempt.StatusCode = resp.StatusCode
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		err = fmt.Errorf("unexpected status %d", resp.StatusCode)
+		attempt.Error = err.Error()
+		return attempt, err
+	}
+	return attempt, nil
+}
+
+// record stores a finished delivery and dead-letters it if it failed.
+func (m *Manager) record(d Delivery) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.deliveries = append(m.deliveries, d)
+	if over := len(m.deliveries) - m.opts.MaxDeliveries; over > 0 {
+		m.deliveries = append([]Delivery(nil), m.deliveries[over:]...)
+	}
+	if d.Status != "dead" {
+		return
+	}
+	m.deadLetters = append(m.deadLetters, d)
+	if over := len(m.deadLetters) - m.opts.MaxDeadLetters; over > 0 {
+		m.deadLetters = append([]Delivery(nil), m.deadLetters[over:]...)
+	}
+	if err := m.save(); err != nil {
+		logger.Logger.Errorf("Failed to save webhook dead letters: %v", err)
+	}
+}
+
+// Sign returns the hex HMAC-SHA256 of body using secret; receivers compare it
+// with the X-ThamesTracker-Signature header (after the "sha256=" prefix).
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
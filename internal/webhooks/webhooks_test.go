@@ -0,0 +1,219 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Takenobou/thamestracker/internal/changes"
+	"github.com/Takenobou/thamestracker/internal/helpers/logger"
+	"github.com/Takenobou/thamestracker/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	logger.InitLogger()
+	os.Exit(m.Run())
+}
+
+func TestParseFilter(t *testing.T) {
+	f, err := ParseFilter(`category=bridge and vessel_name~"dixie queen" && direction!=down river`)
+	assert.NoError(t, err)
+	assert.True(t, f.Match(models.Event{Category: "bridge", VesselName: "Paddle Steamer Dixie Queen", Direction: "Up river"}))
+	assert.False(t, f.Match(models.Event{Category: "bridge", VesselName: "Paddle Steamer Dixie Queen", Direction: "Down river"}))
+	assert.False(t, f.Match(models.Event{Category: "arrivals", VesselName: "Dixie Queen"}))
+
+	empty, err := ParseFilter("")
+	assert.NoError(t, err)
+	assert.True(t, empty.Match(models.Event{}))
+
+	_, err = ParseFilter("colour=red")
+	assert.Error(t, err)
+	_, err = ParseFilter("category")
+	assert.Error(t, err)
+}
+
+func newTestManager(t *testing.T, client *http.Client) *Manager {
+	t.Helper()
+	m, err := NewManager(filepath.Join(t.TempDir(), "webhooks.json"), Options{
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+		Client:         client,
+	})
+	assert.NoError(t, err)
+	return m
+}
+
+func waitForDeliveries(t *testing.T, m *Manager, n int) []Delivery {
+	t.Helper()
+	assert.Eventually(t, func() bool { return len(m.Deliveries()) >= n }, 2*time.Second, 5*time.Millisecond)
+	return m.Deliveries()
+}
+
+func TestManager_DeliversSignedPayload(t *testing.T) {
+	var gotSig, gotBody string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		gotBody = string(body)
+		gotSig = r.Header.Get("X-ThamesTracker-Signature")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	m := newTestManager(t, srv.Client())
+	_, err := m.Create(Hook{URL: srv.URL, Secret: "s3cret", Filter: "category=bridge"})
+	assert.NoError(t, err)
+
+	m.HandleChange(context.Background(), changes.Change{ID: 7, Type: changes.Removed, Event: models.Event{VesselName: "Dixie Queen", Category: "bridge"}})
+	m.HandleChange(context.Background(), changes.Change{ID: 8, Type: changes.Added, Event: models.Event{VesselName: "Ignored", Category: "arrivals"}})
+
+	deliveries := waitForDeliveries(t, m, 1)
+	time.Sleep(20 * time.Millisecond)
+	assert.Len(t, m.Deliveries(), 1, "non-matching change must not be delivered")
+	assert.Equal(t, "delivered", deliveries[0].Status)
+	assert.Equal(t, "sha256="+Sign("s3cret", []byte(gotBody)), gotSig)
+
+	var p Payload
+	assert.NoError(t, json.Unmarshal([]byte(gotBody), &p))
+	assert.Equal(t, "removed", p.Type)
+	assert.Equal(t, uint64(7), p.ChangeID)
+}
+
+func TestManager_RunDeliversEveryChange(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	m := newTestManager(t, srv.Client())
+	_, err := m.Create(Hook{URL: srv.URL})
+	assert.NoError(t, err)
+	feed := changes.NewFeed(1000)
+	follow := feed.Follow()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		m.Run(ctx, follow, nil)
+		close(done)
+	}()
+
+	// far more than a live subscription buffers, appended in one go
+	batch := make([]changes.Change, 300)
+	for i := range batch {
+		batch[i] = changes.Change{Type: changes.Added, Event: models.Event{VesselName: "A", Category: "bridge"}}
+	}
+	feed.Append(batch)
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(&calls) == 300 }, 5*time.Second, 10*time.Millisecond)
+
+	follow.Close()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run did not return once the follower closed")
+	}
+	cancel()
+}
+
+func TestManager_RetriesThenDeadLetters(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	m := newTestManager(t, srv.Client())
+	hook, err := m.Create(Hook{URL: srv.URL})
+	assert.NoError(t, err)
+	m.HandleChange(context.Background(), changes.Change{ID: 1, Type: changes.Added, Event: models.Event{VesselName: "A", Category: "bridge"}})
+
+	deliveries := waitForDeliveries(t, m, 1)
+	assert.Equal(t, "dead", deliveries[0].Status)
+	assert.Len(t, deliveries[0].Attempts, 3)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+	assert.Equal(t, http.StatusBadGateway, deliveries[0].Attempts[0].StatusCode)
+
+	dead := m.DeadLetters()
+	assert.Len(t, dead, 1)
+	assert.Equal(t, hook.ID, dead[0].HookID)
+
+	// dead letters survive a restart
+	reloaded, err := NewManager(m.path, Options{})
+	assert.NoError(t, err)
+	assert.Len(t, reloaded.DeadLetters(), 1)
+	assert.Len(t, reloaded.List(), 1)
+}
+
+func TestManager_UpcomingSentOncePerLift(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+	}))
+	defer srv.Close()
+
+	m := newTestManager(t, srv.Client())
+	_, err := m.Create(Hook{URL: srv.URL, Events: []string{"upcoming"}, LeadMinutes: 30})
+	assert.NoError(t, err)
+
+	now := time.Date(2025, 4, 5, 17, 0, 0, 0, time.UTC)
+	lifts := []models.Event{
+		{Timestamp: now.Add(20 * time.Minute), VesselName: "Soon", Category: "bridge"},
+		{Timestamp: now.Add(2 * time.Hour), VesselName: "Later", Category: "bridge"},
+	}
+	m.CheckUpcoming(context.Background(), lifts, now)
+	m.CheckUpcoming(context.Background(), lifts, now.Add(time.Minute))
+
+	deliveries := waitForDeliveries(t, m, 1)
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	assert.Equal(t, "Soon", deliveries[0].Payload.Event.VesselName)
+	assert.Equal(t, Upcoming, deliveries[0].Type)
+}
+
+func TestManager_LeadMinutesWithoutEvents(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+	}))
+	defer srv.Close()
+
+	m := newTestManager(t, srv.Client())
+	h, err := m.Create(Hook{URL: srv.URL, LeadMinutes: 30})
+	assert.NoError(t, err)
+	assert.True(t, h.wants(Upcoming))
+	assert.True(t, h.wants(string(changes.Added)), "the default event types are still sent")
+
+	now := time.Date(2025, 4, 5, 17, 0, 0, 0, time.UTC)
+	m.CheckUpcoming(context.Background(), []models.Event{
+		{Timestamp: now.Add(20 * time.Minute), VesselName: "Soon", Category: "bridge"},
+	}, now)
+
+	deliveries := waitForDeliveries(t, m, 1)
+	assert.Equal(t, Upcoming, deliveries[0].Type)
+	assert.Equal(t, "Soon", deliveries[0].Payload.Event.VesselName)
+}
+
+func TestManager_CreateValidation(t *testing.T) {
+	m := newTestManager(t, nil)
+	_, err := m.Create(Hook{URL: "ftp://example.com"})
+	assert.Error(t, err)
+	_, err = m.Create(Hook{URL: "https://example.com", Events: []string{"exploded"}})
+	assert.Error(t, err)
+	_, err = m.Create(Hook{URL: "https://example.com", Filter: "bogus"})
+	assert.Error(t, err)
+
+	h, err := m.Create(Hook{URL: "https://example.com", Secret: "x"})
+	assert.NoError(t, err)
+	assert.Equal(t, "********", h.Secret)
+	assert.NoError(t, m.Delete(h.ID))
+	assert.ErrorIs(t, m.Delete(h.ID), ErrNotFound)
+}